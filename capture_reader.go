@@ -0,0 +1,243 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// CaptureConfig is config of CaptureReader.
+type CaptureConfig struct {
+	// Stream is used to open the input stream.
+	Stream InStreamConfig
+	// Format of PCM returned by Read.
+	// Defaults to the format of the stream.
+	Format Format
+	// Capacity of the buffer in frames.
+	// Defaults to one second.
+	Capacity int
+}
+
+// OverflowError is returned by CaptureReader.Read when captured audio was lost.
+// It is not sticky, so reading may continue after it.
+type OverflowError struct {
+	// Frames is the number of frames dropped because the buffer was full.
+	Frames int
+	// DeviceOverflows is the number of overflows reported by the device.
+	DeviceOverflows int
+}
+
+func (e *OverflowError) Error() string {
+	return fmt.Sprintf("soundio: capture overflow, %d frames dropped, %d device overflows", e.Frames, e.DeviceOverflows)
+}
+
+// CaptureReader reads interleaved PCM captured from an input device.
+type CaptureReader struct {
+	stream          *InStream
+	format          Format
+	codec           *sampleCodec
+	buffer          *ringBuffer
+	frame           []byte
+	silence         []byte
+	notify          chan struct{}
+	done            chan struct{}
+	doneOnce        sync.Once
+	closeOnce       sync.Once
+	droppedFrames   atomic.Int64
+	deviceOverflows atomic.Int64
+	err             atomic.Pointer[error]
+}
+
+// NewCaptureReader opens an input stream on device and starts capturing.
+// Call Close when done.
+func NewCaptureReader(device *Device, config *CaptureConfig) (*CaptureReader, error) {
+	stream, err := device.NewInStream(&config.Stream)
+	if err != nil {
+		return nil, err
+	}
+
+	format := config.Format
+	if format == FormatInvalid {
+		format = stream.Format()
+	}
+	codec := codecOf(format)
+	if codec.size == 0 {
+		_ = stream.Close()
+		return nil, ErrorInvalid
+	}
+	capacity := config.Capacity
+	if capacity <= 0 {
+		capacity = stream.SampleRate()
+	}
+	frameBytes := codec.size * stream.Layout().ChannelCount()
+
+	r := &CaptureReader{
+		stream:  stream,
+		format:  format,
+		codec:   codec,
+		buffer:  newRingBuffer(capacity * frameBytes),
+		frame:   make([]byte, frameBytes),
+		silence: make([]byte, frameBytes),
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	for offset := 0; offset < frameBytes; offset += codec.size {
+		codec.encodeFloat64(r.silence[offset:], 0)
+	}
+
+	stream.SetReadCallback(r.readCallback)
+	stream.SetOverflowCallback(func(*InStream) {
+		r.deviceOverflows.Add(1)
+	})
+	stream.SetErrorCallback(func(_ *InStream, err error) {
+		r.fail(err)
+	})
+
+	if err := stream.Start(); err != nil {
+		_ = stream.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Stream returns the input stream being captured.
+func (r *CaptureReader) Stream() *InStream {
+	return r.stream
+}
+
+// Format returns format of PCM returned by Read.
+func (r *CaptureReader) Format() Format {
+	return r.format
+}
+
+// Read reads interleaved PCM, blocking until some is available.
+// It returns an *OverflowError once audio has been lost since the previous
+// call, and io.EOF after Close when the buffer is drained.
+func (r *CaptureReader) Read(p []byte) (int, error) {
+	for {
+		if err := r.overflow(); err != nil {
+			return 0, err
+		}
+		if n := r.buffer.Read(p); n > 0 || len(p) == 0 {
+			return n, nil
+		}
+
+		select {
+		case <-r.notify:
+		case <-r.done:
+			if n := r.buffer.Read(p); n > 0 {
+				return n, nil
+			}
+			if err := r.err.Load(); err != nil {
+				return 0, *err
+			}
+			return 0, io.EOF
+		}
+	}
+}
+
+// Close stops capturing and releases the input stream.
+// Audio already buffered can still be read.
+func (r *CaptureReader) Close() error {
+	r.closeOnce.Do(func() {
+		_ = r.stream.Close()
+		r.closeDone()
+	})
+	return nil
+}
+
+func (r *CaptureReader) closeDone() {
+	r.doneOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// fail records the first stream error, and wakes up Read.
+func (r *CaptureReader) fail(err error) {
+	r.err.CompareAndSwap(nil, &err)
+	r.closeDone()
+}
+
+func (r *CaptureReader) overflow() error {
+	dropped := r.droppedFrames.Swap(0)
+	overflows := r.deviceOverflows.Swap(0)
+	if dropped == 0 && overflows == 0 {
+		return nil
+	}
+	return &OverflowError{
+		Frames:          int(dropped),
+		DeviceOverflows: int(overflows),
+	}
+}
+
+// readCallback moves every available frame into the buffer, dropping
+// the frames that do not fit.
+func (r *CaptureReader) readCallback(stream *InStream, _ int, frameCountMax int) {
+	sameFormat := stream.Format() == r.format
+	channelCount := stream.Layout().ChannelCount()
+	written := false
+
+	for frameLeft := frameCountMax; frameLeft > 0; {
+		frameCount := frameLeft
+		areas, err := stream.BeginRead(&frameCount)
+		if err != nil {
+			r.fail(err)
+			return
+		}
+		if frameCount <= 0 {
+			break
+		}
+
+		for frame := 0; frame < frameCount; frame++ {
+			if r.buffer.Writable() < len(r.frame) {
+				r.droppedFrames.Add(int64(frameCount - frame))
+				break
+			}
+			if areas == nil {
+				r.buffer.Write(r.silence)
+			} else {
+				r.encodeFrame(areas, frame, channelCount, sameFormat)
+				r.buffer.Write(r.frame)
+			}
+			written = true
+		}
+
+		if err := stream.EndRead(); err != nil {
+			r.fail(err)
+			return
+		}
+		frameLeft -= frameCount
+	}
+
+	if written {
+		select {
+		case r.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (r *CaptureReader) encodeFrame(areas *ChannelAreas, frame int, channelCount int, sameFormat bool) {
+	offset := 0
+	for ch := 0; ch < channelCount; ch++ {
+		sample := areas.Sample(ch, frame)
+		if sameFormat {
+			copy(r.frame[offset:], sample.Bytes())
+		} else {
+			convertSample(r.frame[offset:], r.codec, sample.Bytes(), sample.area.codec)
+		}
+		offset += r.codec.size
+	}
+}
+
+var _ io.ReadCloser = (*CaptureReader)(nil)
@@ -0,0 +1,81 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func TestCaptureReaderCopy(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.InputDevice(s.DefaultInputDeviceIndex())
+	defer device.Close()
+
+	r, err := NewCaptureReader(device, &CaptureConfig{Format: FormatS16LE})
+	if err != nil {
+		t.Fatalf("unable to open capture reader: %s", err)
+	}
+	time.AfterFunc(dummyRunDuration, func() {
+		_ = r.Close()
+	})
+
+	var w countingWriter
+	n, err := io.Copy(&w, r)
+	if err != nil {
+		t.Fatalf("io.Copy() = %d, %v", n, err)
+	}
+	frameBytes := int64(2 * r.Stream().Layout().ChannelCount())
+	if n == 0 || n%frameBytes != 0 {
+		t.Errorf("io.Copy() copied %d bytes, want whole frames of %d bytes", n, frameBytes)
+	}
+	if n, err := r.Read(make([]byte, 16)); n != 0 || err != io.EOF {
+		t.Errorf("Read() after Close = %d, %v, want io.EOF", n, err)
+	}
+}
+
+func TestCaptureReaderOverflow(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.InputDevice(s.DefaultInputDeviceIndex())
+	defer device.Close()
+
+	r, err := NewCaptureReader(device, &CaptureConfig{Capacity: 16})
+	if err != nil {
+		t.Fatalf("unable to open capture reader: %s", err)
+	}
+	defer r.Close()
+
+	// the reader stalls while the device keeps capturing
+	time.Sleep(dummyRunDuration)
+
+	p := make([]byte, 4096)
+	var overflow *OverflowError
+	if _, err := r.Read(p); !errors.As(err, &overflow) {
+		t.Fatalf("Read() after stalling = %v, want an OverflowError", err)
+	}
+	if overflow.Frames == 0 && overflow.DeviceOverflows == 0 {
+		t.Errorf("OverflowError reports nothing lost: %v", overflow)
+	}
+	if n, err := r.Read(p); n == 0 || err != nil {
+		t.Errorf("Read() after OverflowError = %d, %v, want buffered audio", n, err)
+	}
+}
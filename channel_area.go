@@ -7,32 +7,97 @@
 
 package soundio
 
-/*
-#include <soundio/soundio.h>
-*/
-import "C"
-import (
-	"reflect"
-	"unsafe"
-)
+import "math"
+
+const errAreaInvalidated = "soundio: ChannelArea used after EndRead or EndWrite"
 
 // ChannelArea contain sound data.
+// It points at memory owned by the device buffer, and becomes invalid
+// when EndRead or EndWrite is called. Any use after that panics.
 type ChannelArea struct {
 	buffer         []byte
 	step           int
 	bytesPerSample int
+	codec          *sampleCodec
+	valid          bool
+	// generation counts invalidations, so that a Sample taken before the
+	// area was reused by the next BeginRead or BeginWrite panics too.
+	generation int
+}
+
+// Sample is a view of a single sample in a ChannelArea.
+// It is only valid until EndRead or EndWrite is called. Any use after that panics.
+type Sample struct {
+	area       *ChannelArea
+	frame      int
+	generation int
 }
 
 // fields
 
 // Buffer returns buffer.
+// The buffer ends at the last byte of the last sample, so its length is
+// not a multiple of Step for interleaved channels.
+// It aliases the device buffer, and must not be used after EndRead or
+// EndWrite, which is not checked.
 func (a *ChannelArea) Buffer() []byte {
+	a.check()
 	return a.buffer
 }
 
+// Sample returns the sample at frame.
+func (a *ChannelArea) Sample(frame int) Sample {
+	a.bufferWithFrame(frame)
+	return Sample{
+		area:       a,
+		frame:      frame,
+		generation: a.generation,
+	}
+}
+
 func (a *ChannelArea) bufferWithFrame(frame int) []byte {
+	a.check()
 	offset := frame * a.step
-	return a.buffer[offset : offset+a.bytesPerSample]
+	end := offset + a.bytesPerSample
+	return a.buffer[offset:end:end]
+}
+
+// readFloat32 decodes frames samples into dst, advancing dst by stride per frame.
+func (a *ChannelArea) readFloat32(dst []float32, stride int, frames int) {
+	a.check()
+	c := a.codec
+	offset := 0
+	if c.float && c.size == 4 {
+		order := c.byteOrder()
+		for i := 0; i < frames; i++ {
+			dst[i*stride] = math.Float32frombits(order.Uint32(a.buffer[offset:]))
+			offset += a.step
+		}
+		return
+	}
+	for i := 0; i < frames; i++ {
+		dst[i*stride] = float32(c.decodeFloat64(a.buffer[offset:]))
+		offset += a.step
+	}
+}
+
+// writeFloat32 encodes frames samples from src, advancing src by stride per frame.
+func (a *ChannelArea) writeFloat32(src []float32, stride int, frames int) {
+	a.check()
+	c := a.codec
+	offset := 0
+	if c.float && c.size == 4 {
+		order := c.byteOrder()
+		for i := 0; i < frames; i++ {
+			order.PutUint32(a.buffer[offset:], math.Float32bits(src[i*stride]))
+			offset += a.step
+		}
+		return
+	}
+	for i := 0; i < frames; i++ {
+		c.encodeFloat64(a.buffer[offset:], float64(src[i*stride]))
+		offset += a.step
+	}
 }
 
 // Step returns ow many bytes it takes to get from the beginning of one sample to
@@ -41,23 +106,65 @@ func (a *ChannelArea) Step() int {
 	return a.step
 }
 
-func newChannelArea(ptr uintptr, format Format, channel int, frameCount int) *ChannelArea {
-	size := C.sizeof_struct_SoundIoChannelArea
-	areaPtr := ptr + uintptr(channel*size)
-	area := (*C.struct_SoundIoChannelArea)(unsafe.Pointer(areaPtr))
-	areaStep := int(area.step)
-	frameSize := frameCount * areaStep
-
-	sh := &reflect.SliceHeader{
-		Data: uintptr(unsafe.Pointer(area.ptr)),
-		Len:  frameSize,
-		Cap:  frameSize,
+func (a *ChannelArea) check() {
+	if !a.valid {
+		panic(errAreaInvalidated)
 	}
-	buffer := *(*[]byte)(unsafe.Pointer(sh))
+}
 
-	return &ChannelArea{
-		buffer:         buffer,
-		step:           areaStep,
-		bytesPerSample: BytesPerSample(format),
+// invalidate drops the reference to native sound data.
+func (a *ChannelArea) invalidate() {
+	a.buffer = nil
+	a.valid = false
+	a.generation++
+}
+
+// bytes returns the bytes of the sample, and panics when the area has been
+// invalidated since the sample was taken.
+func (s Sample) bytes() []byte {
+	if s.area.generation != s.generation {
+		panic(errAreaInvalidated)
 	}
+	return s.area.bufferWithFrame(s.frame)
+}
+
+// Bytes returns the raw bytes of the sample.
+// They alias the device buffer, and must not be used after EndRead or
+// EndWrite, which is not checked.
+func (s Sample) Bytes() []byte {
+	return s.bytes()
+}
+
+// Float32 returns the sample normalized to -1.0 to 1.0.
+func (s Sample) Float32() float32 {
+	return float32(s.area.codec.decodeFloat64(s.bytes()))
+}
+
+// SetFloat32 stores a sample in the range -1.0 to 1.0.
+// Values outside the range are clipped for integer formats.
+func (s Sample) SetFloat32(v float32) {
+	s.area.codec.encodeFloat64(s.bytes(), float64(v))
+}
+
+// Float64 returns the sample normalized to -1.0 to 1.0.
+func (s Sample) Float64() float64 {
+	return s.area.codec.decodeFloat64(s.bytes())
+}
+
+// SetFloat64 stores a sample in the range -1.0 to 1.0.
+// Values outside the range are clipped for integer formats.
+func (s Sample) SetFloat64(v float64) {
+	s.area.codec.encodeFloat64(s.bytes(), v)
+}
+
+// Int32 returns the sample scaled to the full int32 range.
+// Narrower integer samples are shifted into the most significant bits.
+func (s Sample) Int32() int32 {
+	return s.area.codec.decodeInt32(s.bytes())
+}
+
+// SetInt32 stores a full scale int32 sample.
+// Narrower integer formats keep the most significant bits.
+func (s Sample) SetInt32(v int32) {
+	s.area.codec.encodeInt32(s.bytes(), v)
 }
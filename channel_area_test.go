@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"testing"
+)
+
+func TestChannelAreaSampleBounds(t *testing.T) {
+	areas := NewChannelAreas(FormatS16LE, 2, 4)
+	sample := areas.Sample(1, 3)
+	if len(sample.Bytes()) != 2 || cap(sample.Bytes()) != 2 {
+		t.Fatalf("sample has len %d cap %d, want 2", len(sample.Bytes()), cap(sample.Bytes()))
+	}
+	sample.SetInt32(-1 << 31)
+	if got := areas.ReadFloat32(1, 3); got != -1 {
+		t.Errorf("ReadFloat32 = %f, want -1", got)
+	}
+	if got := areas.ReadFloat32(0, 3); got != 0 {
+		t.Errorf("neighbour sample = %f, want 0", got)
+	}
+}
+
+func TestChannelAreaInvalidate(t *testing.T) {
+	areas := NewChannelAreas(FormatFloat32LE, 2, 4)
+	area := areas.Area(0)
+	sample := areas.Sample(1, 2)
+	buffer := area.buffer
+	areas.invalidate()
+
+	for name, f := range map[string]func(){
+		"Buffer":           func() { area.Buffer() },
+		"Sample":           func() { areas.Sample(0, 0) },
+		"retained Sample":  func() { sample.SetFloat32(0) },
+		"WriteFloat32":     func() { areas.WriteFloat32(1, 0, 0) },
+		"ReadPlanar":       func() { areas.ReadPlanar([][]float32{make([]float32, 4)}) },
+		"WriteInterleaved": func() { areas.WriteInterleavedFloat32(make([]float32, 8)) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != errAreaInvalidated {
+					t.Errorf("%s: recovered %v, want %q", name, r, errAreaInvalidated)
+				}
+			}()
+			f()
+		}()
+	}
+
+	// the area is reused by the next BeginRead or BeginWrite
+	area.buffer, area.valid = buffer, true
+	defer func() {
+		if r := recover(); r != errAreaInvalidated {
+			t.Errorf("Sample of a reused area: recovered %v, want %q", r, errAreaInvalidated)
+		}
+	}()
+	sample.Float32()
+}
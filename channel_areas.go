@@ -7,21 +7,44 @@
 
 package soundio
 
-/*
-#include <soundio/soundio.h>
-*/
-import "C"
-import (
-	"unsafe"
-)
-
 // ChannelAreas contain channel datas.
+// A stream reuses its ChannelAreas for every BeginRead or BeginWrite,
+// so it must not be retained after EndRead or EndWrite.
 type ChannelAreas struct {
 	areas        []*ChannelArea
+	storage      [MaxChannels]ChannelArea
+	pointers     [MaxChannels]*ChannelArea
+	format       Format
 	channelCount int
 	frameCount   int
 }
 
+// NewChannelAreas returns ChannelAreas of interleaved frames in Go memory,
+// such as to process audio apart from a stream, or to fake a stream in tests.
+// It panics if channelCount is greater than MaxChannels.
+func NewChannelAreas(format Format, channelCount int, frameCount int) *ChannelAreas {
+	codec := codecOf(format)
+	step := codec.size * channelCount
+	buffer := make([]byte, step*frameCount)
+	a := &ChannelAreas{
+		format:       format,
+		channelCount: channelCount,
+		frameCount:   frameCount,
+	}
+	a.areas = a.pointers[:channelCount]
+	for ch := range a.areas {
+		a.storage[ch] = ChannelArea{
+			buffer:         buffer[min(ch*codec.size, len(buffer)):],
+			step:           step,
+			bytesPerSample: codec.size,
+			codec:          codec,
+			valid:          true,
+		}
+		a.areas[ch] = &a.storage[ch]
+	}
+	return a
+}
+
 // ChannelCount returns channel count.
 func (a *ChannelAreas) ChannelCount() int {
 	return a.channelCount
@@ -32,27 +55,126 @@ func (a *ChannelAreas) FrameCount() int {
 	return a.frameCount
 }
 
+// Format returns format of samples.
+func (a *ChannelAreas) Format() Format {
+	return a.format
+}
+
 // Area returns ChannelArea.
 func (a *ChannelAreas) Area(channel int) *ChannelArea {
 	return a.areas[channel]
 }
 
-// Buffer returns ChannelArea buffer.
+// Buffer returns the bytes of the sample at channel and frame.
+// They alias the device buffer, and must not be used after EndRead or
+// EndWrite, which is not checked.
 func (a *ChannelAreas) Buffer(channel int, frame int) []byte {
 	return a.areas[channel].bufferWithFrame(frame)
 }
 
-func newChannelAreas(ptr *C.struct_SoundIoChannelArea, format Format, chanelCount int, frameCount int) *ChannelAreas {
-	areasPtr := uintptr(unsafe.Pointer(ptr))
-	areas := make([]*ChannelArea, chanelCount)
+// Sample returns the sample at channel and frame.
+func (a *ChannelAreas) Sample(channel int, frame int) Sample {
+	return a.areas[channel].Sample(frame)
+}
+
+// ReadFloat32 returns the sample at channel and frame, normalized to -1.0 to 1.0.
+func (a *ChannelAreas) ReadFloat32(channel int, frame int) float32 {
+	return a.areas[channel].Sample(frame).Float32()
+}
+
+// WriteFloat32 stores a sample in the range -1.0 to 1.0 at channel and frame.
+// Values outside the range are clipped for integer formats.
+func (a *ChannelAreas) WriteFloat32(channel int, frame int, v float32) {
+	a.areas[channel].Sample(frame).SetFloat32(v)
+}
+
+// ReadFloat64 returns the sample at channel and frame, normalized to -1.0 to 1.0.
+func (a *ChannelAreas) ReadFloat64(channel int, frame int) float64 {
+	return a.areas[channel].Sample(frame).Float64()
+}
+
+// WriteFloat64 stores a sample in the range -1.0 to 1.0 at channel and frame.
+// Values outside the range are clipped for integer formats.
+func (a *ChannelAreas) WriteFloat64(channel int, frame int, v float64) {
+	a.areas[channel].Sample(frame).SetFloat64(v)
+}
+
+// ReadInt32 returns the sample at channel and frame, scaled to the full int32 range.
+// Narrower integer samples are shifted into the most significant bits.
+func (a *ChannelAreas) ReadInt32(channel int, frame int) int32 {
+	return a.areas[channel].Sample(frame).Int32()
+}
+
+// WriteInt32 stores a full scale int32 sample at channel and frame.
+// Narrower integer formats keep the most significant bits.
+func (a *ChannelAreas) WriteInt32(channel int, frame int, v int32) {
+	a.areas[channel].Sample(frame).SetInt32(v)
+}
+
+// ReadInterleavedFloat32 decodes samples into dst as interleaved frames
+// normalized to -1.0 to 1.0, and returns the number of frames copied.
+// It copies at most len(dst) / ChannelCount frames.
+func (a *ChannelAreas) ReadInterleavedFloat32(dst []float32) int {
+	if a.channelCount == 0 {
+		return 0
+	}
+	frames := min(a.frameCount, len(dst)/a.channelCount)
+	for ch, area := range a.areas {
+		area.readFloat32(dst[ch:], a.channelCount, frames)
+	}
+	return frames
+}
 
-	for ch := 0; ch < chanelCount; ch++ {
-		areas[ch] = newChannelArea(areasPtr, format, ch, frameCount)
+// WriteInterleavedFloat32 encodes interleaved frames from src, and returns
+// the number of frames copied.
+// It copies at most len(src) / ChannelCount frames.
+func (a *ChannelAreas) WriteInterleavedFloat32(src []float32) int {
+	if a.channelCount == 0 {
+		return 0
+	}
+	frames := min(a.frameCount, len(src)/a.channelCount)
+	for ch, area := range a.areas {
+		area.writeFloat32(src[ch:], a.channelCount, frames)
 	}
+	return frames
+}
 
-	return &ChannelAreas{
-		areas:        areas,
-		channelCount: chanelCount,
-		frameCount:   frameCount,
+// ReadPlanar decodes samples into one slice per channel normalized to -1.0 to 1.0,
+// and returns the number of frames copied.
+// It copies at most the length of the shortest slice, and only the first
+// len(dst) channels.
+func (a *ChannelAreas) ReadPlanar(dst [][]float32) int {
+	channels := min(a.channelCount, len(dst))
+	frames := planarFrames(a.frameCount, dst[:channels])
+	for ch := 0; ch < channels; ch++ {
+		a.areas[ch].readFloat32(dst[ch], 1, frames)
+	}
+	return frames
+}
+
+// WritePlanar encodes samples from one slice per channel, and returns the
+// number of frames copied.
+// It copies at most the length of the shortest slice, and only the first
+// len(src) channels.
+func (a *ChannelAreas) WritePlanar(src [][]float32) int {
+	channels := min(a.channelCount, len(src))
+	frames := planarFrames(a.frameCount, src[:channels])
+	for ch := 0; ch < channels; ch++ {
+		a.areas[ch].writeFloat32(src[ch], 1, frames)
+	}
+	return frames
+}
+
+func planarFrames(frames int, planes [][]float32) int {
+	for _, plane := range planes {
+		frames = min(frames, len(plane))
+	}
+	return frames
+}
+
+// invalidate makes every area panic on use until the next reset.
+func (a *ChannelAreas) invalidate() {
+	for _, area := range a.areas {
+		area.invalidate()
 	}
 }
@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"testing"
+)
+
+const (
+	benchChannels = 2
+	benchFrames   = 1024
+)
+
+func TestChannelAreasInterleavedRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatS8, FormatU16BE, FormatS24LE, FormatU32LE, FormatFloat32BE, FormatFloat64LE} {
+		areas := NewChannelAreas(format, 3, 16)
+		src := make([]float32, 3*16)
+		for i := range src {
+			src[i] = float32(i%7)/4 - 0.75
+		}
+		if n := areas.WriteInterleavedFloat32(src); n != 16 {
+			t.Fatalf("%s: wrote %d frames, want 16", format, n)
+		}
+
+		planar := [][]float32{make([]float32, 16), make([]float32, 16), make([]float32, 8)}
+		if n := areas.ReadPlanar(planar); n != 8 {
+			t.Fatalf("%s: read %d planar frames, want 8", format, n)
+		}
+
+		dst := make([]float32, len(src))
+		if n := areas.ReadInterleavedFloat32(dst); n != 16 {
+			t.Fatalf("%s: read %d frames, want 16", format, n)
+		}
+		for i := range src {
+			frame, ch := i/3, i%3
+			if d := dst[i] - src[i]; d > 0.01 || d < -0.01 {
+				t.Errorf("%s: sample %d = %f, want %f", format, i, dst[i], src[i])
+			}
+			if got := areas.ReadFloat32(ch, frame); got != dst[i] {
+				t.Errorf("%s: ReadFloat32(%d, %d) = %f, bulk read %f", format, ch, frame, got, dst[i])
+			}
+			if frame < 8 && planar[ch][frame] != dst[i] {
+				t.Errorf("%s: planar[%d][%d] = %f, want %f", format, ch, frame, planar[ch][frame], dst[i])
+			}
+		}
+	}
+}
+
+// BenchmarkChannelAreasBuffer copies samples like examples/sio_microphone does.
+func BenchmarkChannelAreasBuffer(b *testing.B) {
+	areas := NewChannelAreas(FormatFloat32LE, benchChannels, benchFrames)
+	dst := make([]byte, 0, benchChannels*benchFrames*4)
+	b.ReportAllocs()
+	for b.Loop() {
+		dst = dst[:0]
+		for frame := 0; frame < benchFrames; frame++ {
+			for ch := 0; ch < benchChannels; ch++ {
+				dst = append(dst, areas.Buffer(ch, frame)...)
+			}
+		}
+	}
+}
+
+func BenchmarkChannelAreasReadFloat32(b *testing.B) {
+	areas := NewChannelAreas(FormatFloat32LE, benchChannels, benchFrames)
+	dst := make([]float32, benchChannels*benchFrames)
+	b.ReportAllocs()
+	for b.Loop() {
+		for frame := 0; frame < benchFrames; frame++ {
+			for ch := 0; ch < benchChannels; ch++ {
+				dst[frame*benchChannels+ch] = areas.ReadFloat32(ch, frame)
+			}
+		}
+	}
+}
+
+func BenchmarkChannelAreasReadInterleavedFloat32(b *testing.B) {
+	areas := NewChannelAreas(FormatFloat32LE, benchChannels, benchFrames)
+	dst := make([]float32, benchChannels*benchFrames)
+	b.ReportAllocs()
+	for b.Loop() {
+		areas.ReadInterleavedFloat32(dst)
+	}
+}
+
+func BenchmarkChannelAreasReadPlanar(b *testing.B) {
+	areas := NewChannelAreas(FormatFloat32LE, benchChannels, benchFrames)
+	dst := [][]float32{make([]float32, benchFrames), make([]float32, benchFrames)}
+	b.ReportAllocs()
+	for b.Loop() {
+		areas.ReadPlanar(dst)
+	}
+}
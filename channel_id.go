@@ -7,106 +7,86 @@
 
 package soundio
 
-/*
-#include <soundio/soundio.h>
-#include <stdlib.h>
-*/
-import "C"
-import "unsafe"
-
 // ChannelID is channel id.
 type ChannelID uint32
 
 // ChannelID enumeration.
 const (
-	ChannelIDInvalid          = ChannelID(C.SoundIoChannelIdInvalid)
-	ChannelIDFrontLeft        = ChannelID(C.SoundIoChannelIdFrontLeft)
-	ChannelIDFrontRight       = ChannelID(C.SoundIoChannelIdFrontRight)
-	ChannelIDFrontCenter      = ChannelID(C.SoundIoChannelIdFrontCenter)
-	ChannelIDLfe              = ChannelID(C.SoundIoChannelIdLfe)
-	ChannelIDBackLeft         = ChannelID(C.SoundIoChannelIdBackLeft)
-	ChannelIDBackRight        = ChannelID(C.SoundIoChannelIdBackRight)
-	ChannelIDFrontLeftCenter  = ChannelID(C.SoundIoChannelIdFrontLeftCenter)
-	ChannelIDFrontRightCenter = ChannelID(C.SoundIoChannelIdFrontRightCenter)
-	ChannelIDBackCenter       = ChannelID(C.SoundIoChannelIdBackCenter)
-	ChannelIDSideLeft         = ChannelID(C.SoundIoChannelIdSideLeft)
-	ChannelIDSideRight        = ChannelID(C.SoundIoChannelIdSideRight)
-	ChannelIDTopCenter        = ChannelID(C.SoundIoChannelIdTopCenter)
-	ChannelIDTopFrontLeft     = ChannelID(C.SoundIoChannelIdTopFrontLeft)
-	ChannelIDTopFrontCenter   = ChannelID(C.SoundIoChannelIdTopFrontCenter)
-	ChannelIDTopFrontRight    = ChannelID(C.SoundIoChannelIdTopFrontRight)
-	ChannelIDTopBackLeft      = ChannelID(C.SoundIoChannelIdTopBackLeft)
-	ChannelIDTopBackCenter    = ChannelID(C.SoundIoChannelIdTopBackCenter)
-	ChannelIDTopBackRight     = ChannelID(C.SoundIoChannelIdTopBackRight)
+	ChannelIDInvalid ChannelID = iota
+	ChannelIDFrontLeft
+	ChannelIDFrontRight
+	ChannelIDFrontCenter
+	ChannelIDLfe
+	ChannelIDBackLeft
+	ChannelIDBackRight
+	ChannelIDFrontLeftCenter
+	ChannelIDFrontRightCenter
+	ChannelIDBackCenter
+	ChannelIDSideLeft
+	ChannelIDSideRight
+	ChannelIDTopCenter
+	ChannelIDTopFrontLeft
+	ChannelIDTopFrontCenter
+	ChannelIDTopFrontRight
+	ChannelIDTopBackLeft
+	ChannelIDTopBackCenter
+	ChannelIDTopBackRight
 
-	ChannelIDBackLeftCenter      = ChannelID(C.SoundIoChannelIdBackLeftCenter)
-	ChannelIDBackRightCenter     = ChannelID(C.SoundIoChannelIdBackRightCenter)
-	ChannelIDFrontLeftWide       = ChannelID(C.SoundIoChannelIdFrontLeftWide)
-	ChannelIDFrontRightWide      = ChannelID(C.SoundIoChannelIdFrontRightWide)
-	ChannelIDFrontLeftHigh       = ChannelID(C.SoundIoChannelIdFrontLeftHigh)
-	ChannelIDFrontCenterHigh     = ChannelID(C.SoundIoChannelIdFrontCenterHigh)
-	ChannelIDFrontRightHigh      = ChannelID(C.SoundIoChannelIdFrontRightHigh)
-	ChannelIDTopFrontLeftCenter  = ChannelID(C.SoundIoChannelIdTopFrontLeftCenter)
-	ChannelIDTopFrontRightCenter = ChannelID(C.SoundIoChannelIdTopFrontRightCenter)
-	ChannelIDTopSideLeft         = ChannelID(C.SoundIoChannelIdTopSideLeft)
-	ChannelIDTopSideRight        = ChannelID(C.SoundIoChannelIdTopSideRight)
-	ChannelIDLeftLfe             = ChannelID(C.SoundIoChannelIdLeftLfe)
-	ChannelIDRightLfe            = ChannelID(C.SoundIoChannelIdRightLfe)
-	ChannelIDLfe2                = ChannelID(C.SoundIoChannelIdLfe2)
-	ChannelIDBottomCenter        = ChannelID(C.SoundIoChannelIdBottomCenter)
-	ChannelIDBottomLeftCenter    = ChannelID(C.SoundIoChannelIdBottomLeftCenter)
-	ChannelIDBottomRightCenter   = ChannelID(C.SoundIoChannelIdBottomRightCenter)
+	ChannelIDBackLeftCenter
+	ChannelIDBackRightCenter
+	ChannelIDFrontLeftWide
+	ChannelIDFrontRightWide
+	ChannelIDFrontLeftHigh
+	ChannelIDFrontCenterHigh
+	ChannelIDFrontRightHigh
+	ChannelIDTopFrontLeftCenter
+	ChannelIDTopFrontRightCenter
+	ChannelIDTopSideLeft
+	ChannelIDTopSideRight
+	ChannelIDLeftLfe
+	ChannelIDRightLfe
+	ChannelIDLfe2
+	ChannelIDBottomCenter
+	ChannelIDBottomLeftCenter
+	ChannelIDBottomRightCenter
 
-	ChannelIDMsMid  = ChannelID(C.SoundIoChannelIdMsMid)  // Mid recording
-	ChannelIDMsSide = ChannelID(C.SoundIoChannelIdMsSide) // Side recording
+	ChannelIDMsMid  // Mid recording
+	ChannelIDMsSide // Side recording
 
-	ChannelIDAmbisonicW = ChannelID(C.SoundIoChannelIdAmbisonicW)
-	ChannelIDAmbisonicX = ChannelID(C.SoundIoChannelIdAmbisonicX)
-	ChannelIDAmbisonicY = ChannelID(C.SoundIoChannelIdAmbisonicY)
-	ChannelIDAmbisonicZ = ChannelID(C.SoundIoChannelIdAmbisonicZ)
+	ChannelIDAmbisonicW
+	ChannelIDAmbisonicX
+	ChannelIDAmbisonicY
+	ChannelIDAmbisonicZ
 
 	// ChannelIDXyX is X of X-Y Recording
-	ChannelIDXyX = ChannelID(C.SoundIoChannelIdXyX)
+	ChannelIDXyX
 	// ChannelIDXyY is Y of X-Y Recording
-	ChannelIDXyY = ChannelID(C.SoundIoChannelIdXyY)
+	ChannelIDXyY
 
-	ChannelIDHeadphonesLeft   = ChannelID(C.SoundIoChannelIdHeadphonesLeft)
-	ChannelIDHeadphonesRight  = ChannelID(C.SoundIoChannelIdHeadphonesRight)
-	ChannelIDClickTrack       = ChannelID(C.SoundIoChannelIdClickTrack)
-	ChannelIDForeignLanguage  = ChannelID(C.SoundIoChannelIdForeignLanguage)
-	ChannelIDHearingImpaired  = ChannelID(C.SoundIoChannelIdHearingImpaired)
-	ChannelIDNarration        = ChannelID(C.SoundIoChannelIdNarration)
-	ChannelIDHaptic           = ChannelID(C.SoundIoChannelIdHaptic)
-	ChannelIDDialogCentricMix = ChannelID(C.SoundIoChannelIdDialogCentricMix)
+	ChannelIDHeadphonesLeft
+	ChannelIDHeadphonesRight
+	ChannelIDClickTrack
+	ChannelIDForeignLanguage
+	ChannelIDHearingImpaired
+	ChannelIDNarration
+	ChannelIDHaptic
+	ChannelIDDialogCentricMix
 
-	ChannelIDAux   = ChannelID(C.SoundIoChannelIdAux)
-	ChannelIDAux0  = ChannelID(C.SoundIoChannelIdAux0)
-	ChannelIDAux1  = ChannelID(C.SoundIoChannelIdAux1)
-	ChannelIDAux2  = ChannelID(C.SoundIoChannelIdAux2)
-	ChannelIDAux3  = ChannelID(C.SoundIoChannelIdAux3)
-	ChannelIDAux4  = ChannelID(C.SoundIoChannelIdAux4)
-	ChannelIDAux5  = ChannelID(C.SoundIoChannelIdAux5)
-	ChannelIDAux6  = ChannelID(C.SoundIoChannelIdAux6)
-	ChannelIDAux7  = ChannelID(C.SoundIoChannelIdAux7)
-	ChannelIDAux8  = ChannelID(C.SoundIoChannelIdAux8)
-	ChannelIDAux9  = ChannelID(C.SoundIoChannelIdAux9)
-	ChannelIDAux10 = ChannelID(C.SoundIoChannelIdAux10)
-	ChannelIDAux11 = ChannelID(C.SoundIoChannelIdAux11)
-	ChannelIDAux12 = ChannelID(C.SoundIoChannelIdAux12)
-	ChannelIDAux13 = ChannelID(C.SoundIoChannelIdAux13)
-	ChannelIDAux14 = ChannelID(C.SoundIoChannelIdAux14)
-	ChannelIDAux15 = ChannelID(C.SoundIoChannelIdAux15)
+	ChannelIDAux
+	ChannelIDAux0
+	ChannelIDAux1
+	ChannelIDAux2
+	ChannelIDAux3
+	ChannelIDAux4
+	ChannelIDAux5
+	ChannelIDAux6
+	ChannelIDAux7
+	ChannelIDAux8
+	ChannelIDAux9
+	ChannelIDAux10
+	ChannelIDAux11
+	ChannelIDAux12
+	ChannelIDAux13
+	ChannelIDAux14
+	ChannelIDAux15
 )
-
-func (c ChannelID) String() string {
-	return C.GoString(C.soundio_get_channel_name(uint32(c)))
-}
-
-// functions
-
-// ParseChannelID returns ChannelID from string.
-func ParseChannelID(str string) ChannelID {
-	cstr := C.CString(str)
-	defer C.free(unsafe.Pointer(cstr))
-	return ChannelID(uint32(C.soundio_parse_channel_id(cstr, C.int(len(str)))))
-}
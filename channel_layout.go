@@ -7,125 +7,187 @@
 
 package soundio
 
-// #include "soundio.h"
-import "C"
-import "unsafe"
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
 
 // ChannelLayoutID is channel layout id.
 type ChannelLayoutID uint32
 
-// ChannelLayout type.
-type ChannelLayout uintptr
+// ChannelLayout is an ordered list of channels with a name.
+// It is a Go value, so it can be built, compared and kept independently of
+// libsoundio, and it is copied into the stream when one is opened.
+type ChannelLayout struct {
+	name     string
+	channels []ChannelID
+}
 
-// ChannelLayout enumeration.
 const (
-	ChannelLayoutIDMono            = ChannelLayoutID(C.SoundIoChannelLayoutIdMono)
-	ChannelLayoutIDStereo          = ChannelLayoutID(C.SoundIoChannelLayoutIdStereo)
-	ChannelLayoutID2Point1         = ChannelLayoutID(C.SoundIoChannelLayoutId2Point1)
-	ChannelLayoutID3Point0         = ChannelLayoutID(C.SoundIoChannelLayoutId3Point0)
-	ChannelLayoutID3Point0Back     = ChannelLayoutID(C.SoundIoChannelLayoutId3Point0Back)
-	ChannelLayoutID3Point1         = ChannelLayoutID(C.SoundIoChannelLayoutId3Point1)
-	ChannelLayoutID4Point0         = ChannelLayoutID(C.SoundIoChannelLayoutId4Point0)
-	ChannelLayoutIDQuad            = ChannelLayoutID(C.SoundIoChannelLayoutIdQuad)
-	ChannelLayoutIDQuadSide        = ChannelLayoutID(C.SoundIoChannelLayoutIdQuadSide)
-	ChannelLayoutID4Point1         = ChannelLayoutID(C.SoundIoChannelLayoutId4Point1)
-	ChannelLayoutID5Point0Back     = ChannelLayoutID(C.SoundIoChannelLayoutId5Point0Back)
-	ChannelLayoutID5Point0Side     = ChannelLayoutID(C.SoundIoChannelLayoutId5Point0Side)
-	ChannelLayoutID5Point1         = ChannelLayoutID(C.SoundIoChannelLayoutId5Point1)
-	ChannelLayoutID5Point1Back     = ChannelLayoutID(C.SoundIoChannelLayoutId5Point1Back)
-	ChannelLayoutID6Point0Side     = ChannelLayoutID(C.SoundIoChannelLayoutId6Point0Side)
-	ChannelLayoutID6Point0Front    = ChannelLayoutID(C.SoundIoChannelLayoutId6Point0Front)
-	ChannelLayoutIDHexagonal       = ChannelLayoutID(C.SoundIoChannelLayoutIdHexagonal)
-	ChannelLayoutID6Point1         = ChannelLayoutID(C.SoundIoChannelLayoutId6Point1)
-	ChannelLayoutID6Point1Back     = ChannelLayoutID(C.SoundIoChannelLayoutId6Point1Back)
-	ChannelLayoutID6Point1Front    = ChannelLayoutID(C.SoundIoChannelLayoutId6Point1Front)
-	ChannelLayoutID7Point0         = ChannelLayoutID(C.SoundIoChannelLayoutId7Point0)
-	ChannelLayoutID7Point0Front    = ChannelLayoutID(C.SoundIoChannelLayoutId7Point0Front)
-	ChannelLayoutID7Point1         = ChannelLayoutID(C.SoundIoChannelLayoutId7Point1)
-	ChannelLayoutID7Point1Wide     = ChannelLayoutID(C.SoundIoChannelLayoutId7Point1Wide)
-	ChannelLayoutID7Point1WideBack = ChannelLayoutID(C.SoundIoChannelLayoutId7Point1WideBack)
-	ChannelLayoutIDOctagonal       = ChannelLayoutID(C.SoundIoChannelLayoutIdOctagonal)
+	// MaxChannels is support channel max count.
+	MaxChannels int = 24
 )
 
-// ChannelLayoutBuiltinCount returns the number of builtin channel layouts.
-func ChannelLayoutBuiltinCount() int {
-	return int(C.soundio_channel_layout_builtin_count())
-}
-
-// ChannelLayoutGetBuiltin returns a builtin channel layout.
-// 0 <= `index` < ChannelLayoutBuiltinCount
-func ChannelLayoutGetBuiltin(index ChannelLayoutID) *ChannelLayout {
-	return newChannelLayout(C.soundio_channel_layout_get_builtin(C.int(index)))
-}
+// ChannelLayout enumeration.
+const (
+	ChannelLayoutIDMono ChannelLayoutID = iota
+	ChannelLayoutIDStereo
+	ChannelLayoutID2Point1
+	ChannelLayoutID3Point0
+	ChannelLayoutID3Point0Back
+	ChannelLayoutID3Point1
+	ChannelLayoutID4Point0
+	ChannelLayoutIDQuad
+	ChannelLayoutIDQuadSide
+	ChannelLayoutID4Point1
+	ChannelLayoutID5Point0Back
+	ChannelLayoutID5Point0Side
+	ChannelLayoutID5Point1
+	ChannelLayoutID5Point1Back
+	ChannelLayoutID6Point0Side
+	ChannelLayoutID6Point0Front
+	ChannelLayoutIDHexagonal
+	ChannelLayoutID6Point1
+	ChannelLayoutID6Point1Back
+	ChannelLayoutID6Point1Front
+	ChannelLayoutID7Point0
+	ChannelLayoutID7Point0Front
+	ChannelLayoutID7Point1
+	ChannelLayoutID7Point1Wide
+	ChannelLayoutID7Point1WideBack
+	ChannelLayoutIDOctagonal
+)
 
-// ChannelLayoutGetDefault returns the default builtin channel layout for the given number of channels.
-func ChannelLayoutGetDefault(channelCount int) *ChannelLayout {
-	return newChannelLayout(C.soundio_channel_layout_get_default(C.int(channelCount)))
+// NewChannelLayout returns a channel layout of channels.
+func NewChannelLayout(name string, channels ...ChannelID) *ChannelLayout {
+	return &ChannelLayout{
+		name:     name,
+		channels: append([]ChannelID(nil), channels...),
+	}
 }
 
-// BestMatchingLayout returns NULL if none matches.
-// Iterates over preferredLayouts. Returns the first channel layout in
-// preferredLayouts which matches one of the channel layouts in availableLayouts.
-func BestMatchingLayout(device1 *Device, device2 *Device) *ChannelLayout {
-	d1p := device1.cptr()
-	d2p := device2.cptr()
-	return newChannelLayout(C.soundio_best_matching_channel_layout(d1p.layouts, d1p.layout_count, d2p.layouts, d2p.layout_count))
+// SortChannelLayouts sorts by channel count, descending.
+func SortChannelLayouts(layouts []*ChannelLayout) {
+	slices.SortStableFunc(layouts, func(a *ChannelLayout, b *ChannelLayout) int {
+		return len(b.channels) - len(a.channels)
+	})
 }
 
 // fields
 
 // Name returns channel layout name.
 func (l *ChannelLayout) Name() string {
-	p := l.cptr()
-	return C.GoString(p.name)
+	return l.name
 }
 
 // ChannelCount returns channel count.
 func (l *ChannelLayout) ChannelCount() int {
-	p := l.cptr()
-	return int(p.channel_count)
+	return len(l.channels)
 }
 
 // Channels returns list of channelID.
 func (l *ChannelLayout) Channels() []ChannelID {
-	p := l.cptr()
-	count := int(p.channel_count)
-	channels := make([]ChannelID, count)
-	for i := 0; i < count; i++ {
-		channels[i] = ChannelID(uint32(p.channels[i]))
-	}
-	return channels
+	return append([]ChannelID(nil), l.channels...)
 }
 
 // functions
 
 // FindChannel returns the index of `channel` in `layout`, or `-1` if not found.
 func (l *ChannelLayout) FindChannel(channel ChannelID) int {
-	return int(C.soundio_channel_layout_find_channel(l.cptr(), uint32(channel)))
+	return slices.Index(l.channels, channel)
 }
 
 // DetectBuiltin returns whether it found a match.
 // Populates the name field of layout if it matches a builtin one.
 func (l *ChannelLayout) DetectBuiltin() bool {
-	return bool(C.soundio_channel_layout_detect_builtin(l.cptr()))
+	for i := 0; i < ChannelLayoutBuiltinCount(); i++ {
+		builtin := ChannelLayoutGetBuiltin(ChannelLayoutID(i))
+		if l.Equal(builtin) {
+			l.name = builtin.name
+			return true
+		}
+	}
+	return false
 }
 
 // Equal returns whether the channel count field and each channel id matches in
-// the supplied channel layouts.
+// the supplied channel layouts. Names are not compared.
 func (l *ChannelLayout) Equal(o *ChannelLayout) bool {
-	return bool(C.soundio_channel_layout_equal(l.cptr(), o.cptr()))
+	if l == nil || o == nil {
+		return l == o
+	}
+	return slices.Equal(l.channels, o.channels)
 }
 
-// SortChannelLayouts sorts by channel count, descending.
-func (l *ChannelLayout) SortChannelLayouts(layoutCount int) {
-	C.soundio_sort_channel_layouts(l.cptr(), C.int(layoutCount))
+// MarshalText encodes the channels as a comma separated list of
+// abbreviated channel names, such as "FL,FR,FC,LFE".
+func (l *ChannelLayout) MarshalText() ([]byte, error) {
+	var b []byte
+	for i, c := range l.channels {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if name, ok := channelShortNames[c]; ok {
+			b = append(b, name...)
+		} else {
+			b = append(b, c.String()...)
+		}
+	}
+	return b, nil
 }
 
-func (l ChannelLayout) cptr() *C.struct_SoundIoChannelLayout {
-	return (*C.struct_SoundIoChannelLayout)(unsafe.Pointer(l))
+// UnmarshalText decodes a comma separated list of channel names.
+// The name is set if the channels match a builtin layout.
+func (l *ChannelLayout) UnmarshalText(text []byte) error {
+	var channels []ChannelID
+	if len(text) > 0 {
+		for _, name := range strings.Split(string(text), ",") {
+			c := parseChannelName(strings.TrimSpace(name))
+			if c == ChannelIDInvalid {
+				return fmt.Errorf("soundio: invalid channel name %q", name)
+			}
+			channels = append(channels, c)
+		}
+	}
+	if len(channels) > MaxChannels {
+		return ErrorInvalid
+	}
+	*l = ChannelLayout{channels: channels}
+	l.DetectBuiltin()
+	return nil
+}
+
+// channelShortNames are the abbreviations libsoundio accepts for channel names.
+var channelShortNames = map[ChannelID]string{
+	ChannelIDFrontLeft:        "FL",
+	ChannelIDFrontRight:       "FR",
+	ChannelIDFrontCenter:      "FC",
+	ChannelIDLfe:              "LFE",
+	ChannelIDBackLeft:         "BL",
+	ChannelIDBackRight:        "BR",
+	ChannelIDFrontLeftCenter:  "FLC",
+	ChannelIDFrontRightCenter: "FRC",
+	ChannelIDBackCenter:       "BC",
+	ChannelIDSideLeft:         "SL",
+	ChannelIDSideRight:        "SR",
+	ChannelIDTopCenter:        "TC",
+	ChannelIDTopFrontLeft:     "TFL",
+	ChannelIDTopFrontCenter:   "TFC",
+	ChannelIDTopFrontRight:    "TFR",
+	ChannelIDTopBackLeft:      "TBL",
+	ChannelIDTopBackCenter:    "TBC",
+	ChannelIDTopBackRight:     "TBR",
 }
 
-func newChannelLayout(p *C.struct_SoundIoChannelLayout) *ChannelLayout {
-	l := ChannelLayout(uintptr(unsafe.Pointer(p)))
-	return &l
+func parseChannelName(name string) ChannelID {
+	for c, short := range channelShortNames {
+		if strings.EqualFold(name, short) {
+			return c
+		}
+	}
+	if name == "" {
+		return ChannelIDInvalid
+	}
+	return ParseChannelID(name)
 }
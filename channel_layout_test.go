@@ -0,0 +1,110 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewChannelLayout(t *testing.T) {
+	channels := []ChannelID{ChannelIDFrontLeft, ChannelIDFrontRight, ChannelIDAux0, ChannelIDAux1}
+	l := NewChannelLayout("custom", channels...)
+	channels[0] = ChannelIDLfe
+
+	if l.Name() != "custom" || l.ChannelCount() != 4 {
+		t.Errorf("layout = %s, %d channels", l.Name(), l.ChannelCount())
+	}
+	if l.Channels()[0] != ChannelIDFrontLeft {
+		t.Error("channels are not copied")
+	}
+	l.Channels()[1] = ChannelIDLfe
+	if l.FindChannel(ChannelIDFrontRight) != 1 || l.FindChannel(ChannelIDLfe) != -1 {
+		t.Error("FindChannel does not find channels of the layout")
+	}
+}
+
+func TestChannelLayoutEqual(t *testing.T) {
+	a := NewChannelLayout("a", ChannelIDFrontLeft, ChannelIDFrontRight)
+	b := NewChannelLayout("b", ChannelIDFrontLeft, ChannelIDFrontRight)
+	c := NewChannelLayout("a", ChannelIDFrontRight, ChannelIDFrontLeft)
+	var nilLayout *ChannelLayout
+
+	if !a.Equal(b) {
+		t.Error("layouts with the same channels are not equal")
+	}
+	if a.Equal(c) {
+		t.Error("layouts with different channel orders are equal")
+	}
+	if a.Equal(nilLayout) || !nilLayout.Equal(nil) {
+		t.Error("nil layouts are not compared by identity")
+	}
+	if !ChannelLayoutGetBuiltin(ChannelLayoutIDStereo).Equal(a) {
+		t.Error("builtin stereo layout is not FL, FR")
+	}
+}
+
+func TestSortChannelLayouts(t *testing.T) {
+	mono := NewChannelLayout("mono", ChannelIDFrontCenter)
+	stereo := NewChannelLayout("stereo", ChannelIDFrontLeft, ChannelIDFrontRight)
+	other := NewChannelLayout("other", ChannelIDSideLeft, ChannelIDSideRight)
+	layouts := []*ChannelLayout{mono, stereo, other}
+	SortChannelLayouts(layouts)
+	if layouts[0] != stereo || layouts[1] != other || layouts[2] != mono {
+		t.Errorf("sorted = %s, %s, %s", layouts[0].Name(), layouts[1].Name(), layouts[2].Name())
+	}
+}
+
+func TestChannelLayoutText(t *testing.T) {
+	l := NewChannelLayout("", ChannelIDFrontLeft, ChannelIDFrontRight, ChannelIDFrontCenter, ChannelIDLfe)
+	text, err := l.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != "FL,FR,FC,LFE" {
+		t.Errorf("MarshalText = %q", text)
+	}
+
+	var decoded ChannelLayout
+	if err := decoded.UnmarshalText([]byte("FL, FR,fc,LFE")); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(l) {
+		t.Errorf("UnmarshalText = %v", decoded.Channels())
+	}
+	if want := ChannelLayoutGetBuiltin(ChannelLayoutID3Point1).Name(); decoded.Name() != want {
+		t.Errorf("name = %q, want %q", decoded.Name(), want)
+	}
+
+	if err := decoded.UnmarshalText([]byte("FL,,FR")); err == nil {
+		t.Error("empty channel name is accepted")
+	}
+}
+
+func TestChannelLayoutJSON(t *testing.T) {
+	type config struct {
+		Layout *ChannelLayout `json:"layout"`
+	}
+	in := config{Layout: NewChannelLayout("", ChannelIDFrontLeft, ChannelIDFrontRight, ChannelIDBackLeft)}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"layout":"FL,FR,BL"}` {
+		t.Errorf("json = %s", b)
+	}
+	var out config
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Layout.Equal(in.Layout) {
+		t.Errorf("layout = %v, want %v", out.Layout.Channels(), in.Layout.Channels())
+	}
+}
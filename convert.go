@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"math/rand/v2"
+)
+
+// ConvertOption is an option of ConvertFormat.
+type ConvertOption func(*convertConfig)
+
+type convertConfig struct {
+	dither bool
+}
+
+// WithDither adds triangular (TPDF) dither of one least significant bit
+// when samples are converted to an integer format of fewer bits.
+func WithDither() ConvertOption {
+	return func(c *convertConfig) {
+		c.dither = true
+	}
+}
+
+// ConvertFormat converts interleaved frames of channels channels from srcFmt in src
+// to dstFmt in dst, and returns the number of frames converted, which is the
+// smaller of the frames in src and in dst.
+// Integer samples are rounded to the nearest value, and float samples outside
+// of -1.0 to 1.0 are clipped when converted to integer formats.
+func ConvertFormat(dst []byte, dstFmt Format, src []byte, srcFmt Format, channels int, opts ...ConvertOption) (int, error) {
+	dc := codecOf(dstFmt)
+	sc := codecOf(srcFmt)
+	if dc.size == 0 || sc.size == 0 || channels <= 0 {
+		return 0, ErrorInvalid
+	}
+	var config convertConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	frames := min(len(src)/(sc.size*channels), len(dst)/(dc.size*channels))
+	samples := frames * channels
+	if *dc == *sc {
+		copy(dst, src[:samples*sc.size])
+		return frames, nil
+	}
+
+	convert := convertSample
+	if config.dither && !dc.float && (sc.float || sc.bits > dc.bits) {
+		convert = convertSampleDither
+	}
+	for i := 0; i < samples; i++ {
+		convert(dst[i*dc.size:], dc, src[i*sc.size:], sc)
+	}
+	return frames, nil
+}
+
+func convertSample(dst []byte, dc *sampleCodec, src []byte, sc *sampleCodec) {
+	raw := sc.load(src)
+	switch {
+	case sc.float && dc.float:
+		if sc.size == dc.size {
+			dc.store(dst, raw)
+		} else {
+			dc.encodeFloat64(dst, sc.decodeFloat64(src))
+		}
+	case sc.float:
+		dc.encodeFloat64(dst, sc.decodeFloat64(src))
+	case dc.float:
+		dc.encodeFloat64(dst, float64(sc.integer(raw))/float64(int64(1)<<(sc.bits-1)))
+	default:
+		dc.store(dst, dc.raw(rescale(sc.integer(raw), sc.bits, dc.bits, 0)))
+	}
+}
+
+func convertSampleDither(dst []byte, dc *sampleCodec, src []byte, sc *sampleCodec) {
+	noise := rand.Uint64()
+	if sc.float {
+		// sum of two uniform values in units of the destination step
+		tpdf := (float64(uint32(noise))+float64(noise>>32))/(1<<32) - 1
+		dc.encodeFloat64(dst, sc.decodeFloat64(src)+tpdf/float64(int64(1)<<(dc.bits-1)))
+		return
+	}
+	shift := sc.bits - dc.bits
+	mask := uint64(1)<<shift - 1
+	tpdf := int64(noise&mask) + int64((noise>>32)&mask) - int64(mask)
+	dc.store(dst, dc.raw(rescale(sc.integer(sc.load(src)), sc.bits, dc.bits, tpdf)))
+}
+
+// rescale converts a signed integer sample of srcBits bits to dstBits bits,
+// adding noise before rounding when bits are dropped.
+func rescale(v int64, srcBits uint, dstBits uint, noise int64) int64 {
+	if dstBits >= srcBits {
+		return v << (dstBits - srcBits)
+	}
+	shift := srcBits - dstBits
+	v = (v + noise + int64(1)<<(shift-1)) >> shift
+	limit := int64(1) << (dstBits - 1)
+	return min(max(v, -limit), limit-1)
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"bytes"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+var concreteFormats = []Format{
+	FormatS8, FormatU8,
+	FormatS16LE, FormatS16BE, FormatU16LE, FormatU16BE,
+	FormatS24LE, FormatS24BE, FormatU24LE, FormatU24BE,
+	FormatS32LE, FormatS32BE, FormatU32LE, FormatU32BE,
+	FormatFloat32LE, FormatFloat32BE, FormatFloat64LE, FormatFloat64BE,
+}
+
+// testSamples returns samples of format covering the extremes of its range.
+func testSamples(format Format) []byte {
+	c := codecOf(format)
+	var values []float64
+	if c.float {
+		values = []float64{-1, -0.5, 0, 1e-9, 0.25, 0.5, 0.999}
+		r := rand.New(rand.NewPCG(1, 2))
+		for i := 0; i < 1000; i++ {
+			values = append(values, r.Float64()*2-1)
+		}
+		b := make([]byte, len(values)*c.size)
+		for i, v := range values {
+			c.encodeFloat64(b[i*c.size:], v)
+		}
+		return b
+	}
+
+	limit := int64(1) << (c.bits - 1)
+	ints := []int64{-limit, -limit + 1, -1, 0, 1, limit - 2, limit - 1}
+	if c.bits == 8 {
+		ints = ints[:0]
+		for v := -limit; v < limit; v++ {
+			ints = append(ints, v)
+		}
+	}
+	r := rand.New(rand.NewPCG(3, 4))
+	for i := 0; i < 1000; i++ {
+		ints = append(ints, r.Int64N(2*limit)-limit)
+	}
+	b := make([]byte, len(ints)*c.size)
+	for i, v := range ints {
+		c.store(b[i*c.size:], c.raw(v))
+	}
+	return b
+}
+
+// exact returns whether every sample of src survives a conversion to dst.
+func exact(src *sampleCodec, dst *sampleCodec) bool {
+	switch {
+	case src.float && dst.float:
+		return dst.size >= src.size
+	case src.float:
+		return false
+	case dst.float && dst.size == 4:
+		return src.bits <= 24
+	case dst.float:
+		return true
+	default:
+		return dst.bits >= src.bits
+	}
+}
+
+// step returns the largest rounding error of dst in the range -1.0 to 1.0.
+func step(dst *sampleCodec) float64 {
+	switch {
+	case dst.float && dst.size == 4:
+		return 1.0 / (1 << 24)
+	case dst.float:
+		return 1.0 / (1 << 53)
+	default:
+		return 1 / float64(int64(1)<<(dst.bits-1))
+	}
+}
+
+func TestConvertFormatRoundTrip(t *testing.T) {
+	for _, dither := range []bool{false, true} {
+		var opts []ConvertOption
+		if dither {
+			opts = append(opts, WithDither())
+		}
+		for _, srcFmt := range concreteFormats {
+			src := testSamples(srcFmt)
+			sc := codecOf(srcFmt)
+			samples := len(src) / sc.size
+			for _, dstFmt := range concreteFormats {
+				dc := codecOf(dstFmt)
+				mid := make([]byte, samples*dc.size)
+				back := make([]byte, len(src))
+
+				if n, err := ConvertFormat(mid, dstFmt, src, srcFmt, 1, opts...); err != nil || n != samples {
+					t.Fatalf("%s -> %s: %d, %v", srcFmt, dstFmt, n, err)
+				}
+				if n, err := ConvertFormat(back, srcFmt, mid, dstFmt, 1, opts...); err != nil || n != samples {
+					t.Fatalf("%s -> %s: %d, %v", dstFmt, srcFmt, n, err)
+				}
+
+				// dither changes even samples that would survive the conversion
+				if exact(sc, dc) && !dither {
+					if !bytes.Equal(back, src) {
+						t.Errorf("%s -> %s -> %s is not exact", srcFmt, dstFmt, srcFmt)
+					}
+					continue
+				}
+				tolerance := step(dc) + step(sc)
+				if dither {
+					tolerance += step(dc)
+				}
+				for i := 0; i < samples; i++ {
+					want := sc.decodeFloat64(src[i*sc.size:])
+					got := sc.decodeFloat64(back[i*sc.size:])
+					if math.Abs(got-want) > tolerance {
+						t.Errorf("%s -> %s -> %s: sample %d = %v, want %v", srcFmt, dstFmt, srcFmt, i, got, want)
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestConvertFormatValues(t *testing.T) {
+	tests := []struct {
+		srcFmt Format
+		src    []byte
+		dstFmt Format
+		want   []byte
+	}{
+		{FormatS16LE, []byte{0x34, 0x12}, FormatS16BE, []byte{0x12, 0x34}},
+		{FormatS16LE, []byte{0x00, 0x80}, FormatU16LE, []byte{0x00, 0x00}},
+		{FormatS16LE, []byte{0xFF, 0x7F}, FormatU8, []byte{0xFF}},
+		{FormatU8, []byte{0x80}, FormatS16BE, []byte{0x00, 0x00}},
+		{FormatS8, []byte{0x80}, FormatS24LE, []byte{0x00, 0x00, 0x80, 0xFF}},
+		{FormatS24BE, []byte{0x00, 0xFF, 0xFF, 0xFF}, FormatS32LE, []byte{0x00, 0xFF, 0xFF, 0xFF}},
+		{FormatU24LE, []byte{0x00, 0x00, 0x80, 0x00}, FormatS16LE, []byte{0x00, 0x00}},
+		{FormatS32LE, []byte{0x00, 0x80, 0xFF, 0x7F}, FormatS16LE, []byte{0xFF, 0x7F}},
+		{FormatFloat32LE, []byte{0x00, 0x00, 0x00, 0x40}, FormatS16LE, []byte{0xFF, 0x7F}},
+		{FormatFloat32LE, []byte{0x00, 0x00, 0x80, 0xBF}, FormatU8, []byte{0x00}},
+		{FormatFloat64BE, []byte{0x3F, 0xE0, 0, 0, 0, 0, 0, 0}, FormatFloat32LE, []byte{0x00, 0x00, 0x00, 0x3F}},
+	}
+	for _, test := range tests {
+		dst := make([]byte, len(test.want))
+		if _, err := ConvertFormat(dst, test.dstFmt, test.src, test.srcFmt, 1); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(dst, test.want) {
+			t.Errorf("%s % x -> %s = % x, want % x", test.srcFmt, test.src, test.dstFmt, dst, test.want)
+		}
+	}
+}
+
+func TestConvertFormatFrames(t *testing.T) {
+	src := make([]byte, 2*2*5+1)
+	dst := make([]byte, 4*2*3+3)
+	n, err := ConvertFormat(dst, FormatFloat32NE, src, FormatS16NE, 2)
+	if err != nil || n != 3 {
+		t.Errorf("ConvertFormat = %d, %v, want 3 frames", n, err)
+	}
+	if _, err := ConvertFormat(dst, FormatInvalid, src, FormatS16NE, 2); err != ErrorInvalid {
+		t.Errorf("err = %v, want ErrorInvalid", err)
+	}
+	if _, err := ConvertFormat(dst, FormatS8, src, FormatS16NE, 0); err != ErrorInvalid {
+		t.Errorf("err = %v, want ErrorInvalid", err)
+	}
+}
+
+func TestConvertFormatDither(t *testing.T) {
+	// a quarter step above zero averages out to a quarter step with dither
+	const samples = 1 << 16
+	src := make([]byte, samples*8)
+	for i := 0; i < samples; i++ {
+		codecOf(FormatFloat64NE).encodeFloat64(src[i*8:], 0.25/(1<<15))
+	}
+	dst := make([]byte, samples*2)
+	for _, dither := range []bool{false, true} {
+		var opts []ConvertOption
+		if dither {
+			opts = append(opts, WithDither())
+		}
+		if _, err := ConvertFormat(dst, FormatS16NE, src, FormatFloat64NE, 1, opts...); err != nil {
+			t.Fatal(err)
+		}
+		sum := 0.0
+		for i := 0; i < samples; i++ {
+			sum += float64(codecOf(FormatS16NE).decodeInt32(dst[i*2:]) >> 16)
+		}
+		mean := sum / samples
+		want := 0.0
+		if dither {
+			want = 0.25
+		}
+		if math.Abs(mean-want) > 0.02 {
+			t.Errorf("dither %t: mean = %v, want %v", dither, mean, want)
+		}
+	}
+}
+
+func BenchmarkConvertFormat(b *testing.B) {
+	src := make([]byte, 4*2*1024)
+	dst := make([]byte, 2*2*1024)
+	b.Run("Float32ToS16", func(b *testing.B) {
+		for b.Loop() {
+			_, _ = ConvertFormat(dst, FormatS16NE, src, FormatFloat32NE, 2)
+		}
+	})
+	b.Run("Float32ToS16Dither", func(b *testing.B) {
+		for b.Loop() {
+			_, _ = ConvertFormat(dst, FormatS16NE, src, FormatFloat32NE, 2, WithDither())
+		}
+	})
+}
@@ -9,46 +9,75 @@ package soundio
 
 // #include "soundio.h"
 import "C"
-import "unsafe"
+import (
+	"log"
+	"runtime"
+	"sync"
+	"unsafe"
+	"weak"
+)
 
 // Device is input/output device.
-type Device uintptr
+// Each Device returned by SoundIo holds one reference to the device,
+// which is released by Close or RemoveReference. Once all references are
+// released, the fields return zero values and the functions ErrClosed.
+type Device struct {
+	p     *C.struct_SoundIoDevice
+	io    *SoundIo // keeps the SoundIo from being finalized before its devices
+	key   int
+	name  string
+	stack string
+
+	mu   sync.Mutex
+	refs int
+}
 
 // fields
 
 // ID returns device id.
 func (d *Device) ID() string {
 	p := d.cptr()
+	if p == nil {
+		return ""
+	}
 	return C.GoString(p.id)
 }
 
 // Name returns device name.
 func (d *Device) Name() string {
 	p := d.cptr()
+	if p == nil {
+		return ""
+	}
 	return C.GoString(p.name)
 }
 
 // Aim returns whether this device is an input device or an output device.
 func (d *Device) Aim() DeviceAim {
 	p := d.cptr()
+	if p == nil {
+		return DeviceAimInput
+	}
 	return DeviceAim(uint32(p.aim))
 }
 
-// Layouts returns list of channel layout.
+// Layouts returns copies of the channel layouts.
 // Channel layouts are handled similarly to GetFormats.
 // If this information is missing due to a GetProbeError,
-// layouts will be nil. It's OK to modify this data, for example calling
-// SortChannelLayouts on it.
+// layouts will be nil. The copies stay valid after the device is unreferenced.
 // Devices are guaranteed to have at least 1 channel layout.
 func (d *Device) Layouts() []*ChannelLayout {
 	p := d.cptr()
+	if p == nil {
+		return nil
+	}
 	count := int(p.layout_count)
+	if count == 0 {
+		return nil
+	}
 	layouts := make([]*ChannelLayout, count)
-	size := C.sizeof_struct_SoundIoChannelLayout
-	base := uintptr(unsafe.Pointer(p.layouts))
-	for i := 0; i < count; i++ {
-		l := ChannelLayout(base + uintptr(i*size))
-		layouts[i] = &l
+	for i, l := range unsafe.Slice(p.layouts, count) {
+		layouts[i] = newChannelLayout(&l)
 	}
 	return layouts
 }
@@ -56,25 +85,31 @@ func (d *Device) Layouts() []*ChannelLayout {
 // LayoutCount returns how many formats are available in GetLayouts.
 func (d *Device) LayoutCount() int {
 	p := d.cptr()
+	if p == nil {
+		return 0
+	}
 	return int(p.layout_count)
 }
 
-// CurrentLayout returns current layout.
+// CurrentLayout returns a copy of the current layout.
 func (d *Device) CurrentLayout() *ChannelLayout {
 	p := d.cptr()
+	if p == nil {
+		return nil
+	}
 	return newChannelLayout(&p.current_layout)
 }
 
 // Formats returns list of formats this device supports.
 func (d *Device) Formats() []Format {
 	p := d.cptr()
+	if p == nil {
+		return nil
+	}
 	count := int(p.format_count)
 	formats := make([]Format, count)
-	size := C.sizeof_int
-	base := uintptr(unsafe.Pointer(p.formats))
-	for i := 0; i < count; i++ {
-		f := (*uint32)(unsafe.Pointer(base + uintptr(i*size)))
-		formats[i] = Format(*f)
+	for i, f := range unsafe.Slice(p.formats, count) {
+		formats[i] = Format(f)
 	}
 	return formats
 }
@@ -82,24 +117,31 @@ func (d *Device) Formats() []Format {
 // FormatCount returns how many formats are available in GetFormats.
 func (d *Device) FormatCount() int {
 	p := d.cptr()
+	if p == nil {
+		return 0
+	}
 	return int(p.format_count)
 }
 
 // CurrentFormat returns current format.
 func (d *Device) CurrentFormat() Format {
 	p := d.cptr()
+	if p == nil {
+		return FormatInvalid
+	}
 	return Format(uint32(p.current_format))
 }
 
 // SampleRates returns list of sample rate this device supports.
 func (d *Device) SampleRates() []SampleRateRange {
 	p := d.cptr()
+	if p == nil {
+		return nil
+	}
 	count := int(p.sample_rate_count)
 	rates := make([]SampleRateRange, count)
-	size := C.sizeof_struct_SoundIoSampleRateRange
-	base := uintptr(unsafe.Pointer(p.sample_rates))
-	for i := 0; i < count; i++ {
-		rates[i] = newSampleRateRange(base + uintptr(i*size))
+	for i, r := range unsafe.Slice(p.sample_rates, count) {
+		rates[i] = newSampleRateRange(&r)
 	}
 	return rates
 }
@@ -107,12 +149,18 @@ func (d *Device) SampleRates() []SampleRateRange {
 // SampleRateCount returns how many sample rate are available in GetSampleRates.
 func (d *Device) SampleRateCount() int {
 	p := d.cptr()
+	if p == nil {
+		return 0
+	}
 	return int(p.sample_rate_count)
 }
 
 // SampleRateCurrent returns current sample rate.
 func (d *Device) SampleRateCurrent() int {
 	p := d.cptr()
+	if p == nil {
+		return 0
+	}
 	return int(p.sample_rate_current)
 }
 
@@ -121,6 +169,9 @@ func (d *Device) SampleRateCurrent() int {
 // For PulseAudio and WASAPI this value is unknown until you open a stream.
 func (d *Device) SoftwareLatencyMin() float64 {
 	p := d.cptr()
+	if p == nil {
+		return 0
+	}
 	return float64(p.software_latency_min)
 }
 
@@ -129,6 +180,9 @@ func (d *Device) SoftwareLatencyMin() float64 {
 // For PulseAudio and WASAPI this value is unknown until you open a stream.
 func (d *Device) SoftwareLatencyMax() float64 {
 	p := d.cptr()
+	if p == nil {
+		return 0
+	}
 	return float64(p.software_latency_max)
 }
 
@@ -137,6 +191,9 @@ func (d *Device) SoftwareLatencyMax() float64 {
 // For PulseAudio and WASAPI this value is unknown until you open a stream.
 func (d *Device) SoftwareLatencyCurrent() float64 {
 	p := d.cptr()
+	if p == nil {
+		return 0
+	}
 	return float64(p.software_latency_current)
 }
 
@@ -147,12 +204,18 @@ func (d *Device) SoftwareLatencyCurrent() float64 {
 // resampling and thus tend to have fewer formats available.
 func (d *Device) Raw() bool {
 	p := d.cptr()
+	if p == nil {
+		return false
+	}
 	return bool(p.is_raw)
 }
 
 // RefCount returns number of devices referenced.
 func (d *Device) RefCount() int {
 	p := d.cptr()
+	if p == nil {
+		return 0
+	}
 	return int(p.ref_count)
 }
 
@@ -162,54 +225,135 @@ func (d *Device) RefCount() int {
 // then information about formats, sample rates, and channel layouts might be missing.
 //
 // Possible errors:
+// * ErrClosed
 // * ErrorOpeningDevice
 // * ErrorNoMem
 func (d *Device) ProbeError() error {
 	p := d.cptr()
+	if p == nil {
+		return ErrClosed
+	}
 	return convertToError(p.probe_error)
 }
 
 // functions
 
 // AddReference is increments the device's reference count.
+// Adding a reference to a released Device is ignored,
+// or panics when built with the soundio_debug tag.
 func (d *Device) AddReference() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.refs == 0 {
+		releasedDeviceUsed(d, "referenced")
+		return
+	}
+	d.refs++
 	C.soundio_device_ref(d.cptr())
 }
 
 // RemoveReference is decrements the device's reference count.
+// Removing more references than this Device holds is ignored,
+// or panics when built with the soundio_debug tag.
 func (d *Device) RemoveReference() {
-	C.soundio_device_unref(d.cptr())
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.refs == 0 {
+		releasedDeviceUsed(d, "unreferenced")
+		return
+	}
+	d.release(1)
+}
+
+// Close removes all references this Device holds.
+// Calling Close more than once does nothing.
+// A Device that is garbage collected without being released is logged by a
+// finalizer, and released by SoundIo.Close.
+func (d *Device) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.release(d.refs)
+	return nil
+}
+
+// release removes count references. d.mu must be held.
+func (d *Device) release(count int) {
+	if count == 0 {
+		return
+	}
+	for range count {
+		C.soundio_device_unref(d.cptr())
+	}
+	d.refs -= count
+	if d.refs == 0 {
+		d.p = nil
+		d.io.tracker.untrack(d.key)
+	}
+}
+
+// released returns whether all references this Device holds are removed.
+func (d *Device) released() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.refs == 0
 }
 
 // Equal returns true if and only if the devices have the same GetID,
 // IsRaw, and GetAim are the same.
+// Released devices are not equal to any device.
 func (d *Device) Equal(o *Device) bool {
-	return bool(C.soundio_device_equal(d.cptr(), o.cptr()))
+	p, op := d.cptr(), o.cptr()
+	if p == nil || op == nil {
+		return false
+	}
+	return bool(C.soundio_device_equal(p, op))
 }
 
 // SortChannelLayouts sorts channel layouts by channel count, descending.
 func (d *Device) SortChannelLayouts() {
-	C.soundio_device_sort_channel_layouts(d.cptr())
+	p := d.cptr()
+	if p == nil {
+		return
+	}
+	C.soundio_device_sort_channel_layouts(p)
 }
 
 // SupportsFormat returns whether `format` is included in the device's supported formats.
 func (d *Device) SupportsFormat(format Format) bool {
-	return bool(C.soundio_device_supports_format(d.cptr(), uint32(format)))
+	p := d.cptr()
+	if p == nil {
+		return false
+	}
+	return bool(C.soundio_device_supports_format(p, uint32(format)))
 }
 
 // SupportsLayout returns whether `layout` is included in the device's supported channel layouts.
-func (d *Device) SupportsLayout(layout ChannelLayout) bool {
-	return bool(C.soundio_device_supports_layout(d.cptr(), layout.cptr()))
+func (d *Device) SupportsLayout(layout *ChannelLayout) bool {
+	p := d.cptr()
+	if p == nil || layout == nil || layout.ChannelCount() > MaxChannels {
+		return false
+	}
+	var l C.struct_SoundIoChannelLayout
+	layout.copyTo(&l)
+	return bool(C.soundio_device_supports_layout(p, &l))
 }
 
 // SupportsSampleRate returns whether `sampleRate` is included in the device's supported sample rates.
 func (d *Device) SupportsSampleRate(sampleRate int) bool {
-	return bool(C.soundio_device_supports_sample_rate(d.cptr(), C.int(sampleRate)))
+	p := d.cptr()
+	if p == nil {
+		return false
+	}
+	return bool(C.soundio_device_supports_sample_rate(p, C.int(sampleRate)))
 }
 
 // NearestSampleRate returns the available sample rate nearest to sampleRate, rounding up.
 func (d *Device) NearestSampleRate(sampleRate int) int {
-	return int(C.soundio_device_nearest_sample_rate(d.cptr(), C.int(sampleRate)))
+	p := d.cptr()
+	if p == nil {
+		return 0
+	}
+	return int(C.soundio_device_nearest_sample_rate(p, C.int(sampleRate)))
 }
 
 // NewInStream allocates memory and sets defaults.
@@ -219,6 +363,7 @@ func (d *Device) NearestSampleRate(sampleRate int) int {
 // you must call Destroy function on it.
 //
 // Possible errors:
+//   - ErrClosed - device was released
 //   - ErrorInvalid
 //     device aim is not DeviceAimInput
 //     format is not valid
@@ -241,6 +386,7 @@ func (d *Device) NewInStream(config *InStreamConfig) (*InStream, error) {
 // you must call Destroy function on it.
 //
 // Possible errors:
+//   - ErrClosed - device was released
 //   - ErrorInvalid
 //     device aim is not DeviceAimOutput
 //     format is not valid
@@ -258,14 +404,55 @@ func (d *Device) NewOutStream(config *OutStreamConfig) (*OutStream, error) {
 	return newOutStream(d, config)
 }
 
-func (d Device) cptr() *C.struct_SoundIoDevice {
-	return (*C.struct_SoundIoDevice)(unsafe.Pointer(d))
+// opError wraps err with the device.
+func (d *Device) opError(op string, config any, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &OpError{Op: op, DeviceID: d.ID(), Backend: d.io.CurrentBackend(), Config: config, Err: err}
+}
+
+// cptr returns the C device, or nil once all references are released.
+func (d *Device) cptr() *C.struct_SoundIoDevice {
+	return d.p
 }
 
-func newDevice(p *C.struct_SoundIoDevice) *Device {
+// newDevice wraps a referenced device, and tracks it until it is released.
+func newDevice(io *SoundIo, p *C.struct_SoundIoDevice) *Device {
 	if p == nil {
 		return nil
 	}
-	d := Device(uintptr(unsafe.Pointer(p)))
-	return &d
+	d := &Device{
+		p:     p,
+		io:    io,
+		name:  C.GoString(p.name),
+		stack: creationStack(),
+		refs:  1,
+	}
+	w := weak.Make(d)
+	d.key = io.tracker.track("device", d.name, d.stack, func() {
+		if d := w.Value(); d != nil {
+			_ = d.Close()
+		}
+	})
+	runtime.SetFinalizer(d, finalizeDevice)
+	return d
+}
+
+// finalizeDevice logs a device that was not released, and leaves its
+// references to SoundIo.Close, as libsoundio does not count them atomically
+// and FlushEvents may unref the same device meanwhile.
+func finalizeDevice(d *Device) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.refs == 0 {
+		return
+	}
+	log.Printf("soundio: device %q was not released", d.name)
+	p, refs := d.p, d.refs
+	d.io.tracker.setRelease(d.key, func() {
+		for range refs {
+			C.soundio_device_unref(p)
+		}
+	})
 }
@@ -7,18 +7,13 @@
 
 package soundio
 
-/*
-#include <soundio/soundio.h>
-*/
-import "C"
-
 // DeviceAim is device aim.
 type DeviceAim uint32
 
 // DeviceAim enumeration.
 const (
-	DeviceAimInput  = DeviceAim(C.SoundIoDeviceAimInput)  // capture / recording
-	DeviceAimOutput = DeviceAim(C.SoundIoDeviceAimOutput) // playback
+	DeviceAimInput  DeviceAim = iota // capture / recording
+	DeviceAimOutput                  // playback
 )
 
 func (a DeviceAim) String() string {
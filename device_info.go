@@ -0,0 +1,123 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+// DeviceInfo is a copy of the description of a device.
+// It does not refer to libsoundio memory, so it stays valid after the
+// device is unreferenced or the SoundIo is destroyed.
+type DeviceInfo struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Aim     DeviceAim `json:"aim"`
+	Raw     bool      `json:"raw"`
+	Default bool      `json:"default"` // set by SoundIo.Devices
+
+	Layouts       []*ChannelLayout `json:"layouts"`
+	CurrentLayout *ChannelLayout   `json:"current_layout,omitempty"`
+
+	Formats       []Format `json:"formats"`
+	CurrentFormat Format   `json:"current_format"`
+
+	SampleRates       []SampleRateRange `json:"sample_rates"`
+	SampleRateCurrent int               `json:"sample_rate_current"`
+
+	SoftwareLatencyMin     float64 `json:"software_latency_min"`
+	SoftwareLatencyMax     float64 `json:"software_latency_max"`
+	SoftwareLatencyCurrent float64 `json:"software_latency_current"`
+
+	// ProbeError is ErrorNone when the device was probed successfully.
+	// Otherwise formats, sample rates and layouts might be missing.
+	ProbeError Error `json:"probe_error,omitempty"`
+}
+
+// DeviceKey identifies a device, as Device.Equal does.
+type DeviceKey struct {
+	ID  string
+	Aim DeviceAim
+	Raw bool
+}
+
+// Key returns the key of the device.
+func (i *DeviceInfo) Key() DeviceKey {
+	return DeviceKey{ID: i.ID, Aim: i.Aim, Raw: i.Raw}
+}
+
+// Snapshot returns a copy of the description of the device.
+func (d *Device) Snapshot() DeviceInfo {
+	info := DeviceInfo{
+		ID:                     d.ID(),
+		Name:                   d.Name(),
+		Aim:                    d.Aim(),
+		Raw:                    d.Raw(),
+		Layouts:                d.Layouts(),
+		Formats:                d.Formats(),
+		CurrentFormat:          d.CurrentFormat(),
+		SampleRates:            d.SampleRates(),
+		SampleRateCurrent:      d.SampleRateCurrent(),
+		SoftwareLatencyMin:     d.SoftwareLatencyMin(),
+		SoftwareLatencyMax:     d.SoftwareLatencyMax(),
+		SoftwareLatencyCurrent: d.SoftwareLatencyCurrent(),
+	}
+	if current := d.CurrentLayout(); current.ChannelCount() > 0 {
+		info.CurrentLayout = current
+	}
+	if err, ok := d.ProbeError().(Error); ok {
+		info.ProbeError = err
+	}
+	return info
+}
+
+// Devices calls FlushEvents, and returns snapshots of all input devices
+// followed by all output devices, taken from the same device list.
+//
+// Possible errors:
+// * ErrClosed
+// * ErrorInvalid - not connected to a backend
+func (s *SoundIo) Devices() ([]DeviceInfo, error) {
+	if s.ptr == nil {
+		return nil, ErrClosed
+	}
+	if s.CurrentBackend() == BackendNone {
+		return nil, ErrorInvalid
+	}
+	s.FlushEvents()
+	return s.snapshotDevices(), nil
+}
+
+// snapshotDevices returns snapshots of all devices without flushing events.
+func (s *SoundIo) snapshotDevices() []DeviceInfo {
+	inputCount := s.InputDeviceCount()
+	outputCount := s.OutputDeviceCount()
+	defaultInput := s.DefaultInputDeviceIndex()
+	defaultOutput := s.DefaultOutputDeviceIndex()
+
+	devices := make([]DeviceInfo, 0, max(inputCount, 0)+max(outputCount, 0))
+	for i := 0; i < inputCount; i++ {
+		device := s.InputDevice(i)
+		if device == nil {
+			continue
+		}
+		info := device.Snapshot()
+		info.Default = i == defaultInput
+		device.RemoveReference()
+		devices = append(devices, info)
+	}
+	for i := 0; i < outputCount; i++ {
+		device := s.OutputDevice(i)
+		if device == nil {
+			continue
+		}
+		info := device.Snapshot()
+		info.Default = i == defaultOutput
+		device.RemoveReference()
+		devices = append(devices, info)
+	}
+	return devices
+}
@@ -0,0 +1,66 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDeviceInfoJSON(t *testing.T) {
+	stereo := NewChannelLayout("", ChannelIDFrontLeft, ChannelIDFrontRight)
+	in := DeviceInfo{
+		ID:                     "hw:0,0",
+		Name:                   "Built-in Audio",
+		Aim:                    DeviceAimOutput,
+		Raw:                    true,
+		Default:                true,
+		Layouts:                []*ChannelLayout{stereo, NewChannelLayout("", ChannelIDFrontCenter)},
+		CurrentLayout:          stereo,
+		Formats:                []Format{FormatS16LE, FormatFloat32LE},
+		CurrentFormat:          FormatS16LE,
+		SampleRates:            []SampleRateRange{{min: 44100, max: 44100}, {min: 48000, max: 96000}},
+		SampleRateCurrent:      48000,
+		SoftwareLatencyMin:     0.001,
+		SoftwareLatencyMax:     2,
+		SoftwareLatencyCurrent: 0.02,
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["probe_error"]; ok {
+		t.Error("probe_error is encoded without an error")
+	}
+	if got := fields["sample_rates"].([]any)[1]; !reflect.DeepEqual(got, map[string]any{"min": 48000.0, "max": 96000.0}) {
+		t.Errorf("sample rate range = %v", got)
+	}
+	if got := fields["current_layout"]; got != "FL,FR" {
+		t.Errorf("current layout = %v", got)
+	}
+
+	var out DeviceInfo
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.CurrentLayout.Equal(in.CurrentLayout) || len(out.Layouts) != 2 || !out.Layouts[1].Equal(in.Layouts[1]) {
+		t.Errorf("layouts = %v, %v", out.Layouts, out.CurrentLayout)
+	}
+	out.Layouts, out.CurrentLayout = in.Layouts, in.CurrentLayout
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("decoded = %+v, want %+v", out, in)
+	}
+}
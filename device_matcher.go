@@ -0,0 +1,159 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DeviceMatcher returns whether device is the one looked for.
+type DeviceMatcher func(device *Device) bool
+
+// MatchID matches the device with the id.
+func MatchID(id string) DeviceMatcher {
+	return func(device *Device) bool {
+		return device.ID() == id
+	}
+}
+
+// MatchRaw matches raw devices, or devices that are not raw.
+func MatchRaw(raw bool) DeviceMatcher {
+	return func(device *Device) bool {
+		return device.Raw() == raw
+	}
+}
+
+// MatchName matches devices whose name contains substr, ignoring case.
+func MatchName(substr string) DeviceMatcher {
+	substr = strings.ToLower(substr)
+	return func(device *Device) bool {
+		return strings.Contains(strings.ToLower(device.Name()), substr)
+	}
+}
+
+// MatchNameRegexp matches devices whose name matches re.
+func MatchNameRegexp(re *regexp.Regexp) DeviceMatcher {
+	return func(device *Device) bool {
+		return re.MatchString(device.Name())
+	}
+}
+
+// MatchSampleRate matches devices that support sampleRate.
+func MatchSampleRate(sampleRate int) DeviceMatcher {
+	return func(device *Device) bool {
+		return device.ProbeError() == nil && device.SupportsSampleRate(sampleRate)
+	}
+}
+
+// MatchFormat matches devices that support format.
+func MatchFormat(format Format) DeviceMatcher {
+	return func(device *Device) bool {
+		return device.ProbeError() == nil && device.SupportsFormat(format)
+	}
+}
+
+// MatchMinChannels matches devices with a channel layout of at least channelCount channels.
+func MatchMinChannels(channelCount int) DeviceMatcher {
+	return func(device *Device) bool {
+		if device.ProbeError() != nil {
+			return false
+		}
+		for _, layout := range device.Layouts() {
+			if layout.ChannelCount() >= channelCount {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchAll matches devices that all of matchers match.
+func MatchAll(matchers ...DeviceMatcher) DeviceMatcher {
+	return func(device *Device) bool {
+		for _, match := range matchers {
+			if !match(device) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAny matches devices that any of matchers matches.
+func MatchAny(matchers ...DeviceMatcher) DeviceMatcher {
+	return func(device *Device) bool {
+		for _, match := range matchers {
+			if match(device) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FindInputDevice returns the first input device that match matches,
+// trying the default device first. A nil match returns the default device.
+// Call RemoveReference when done. Other devices are released before returning.
+//
+// Possible errors:
+// * ErrClosed
+// * ErrorNoSuchDevice
+func (s *SoundIo) FindInputDevice(match DeviceMatcher) (*Device, error) {
+	if s.ptr == nil {
+		return nil, ErrClosed
+	}
+	return findDevice(match, s.InputDeviceCount(), s.DefaultInputDeviceIndex(), s.InputDevice)
+}
+
+// FindOutputDevice returns the first output device that match matches,
+// trying the default device first. A nil match returns the default device.
+// Call RemoveReference when done. Other devices are released before returning.
+//
+// Possible errors:
+// * ErrClosed
+// * ErrorNoSuchDevice
+func (s *SoundIo) FindOutputDevice(match DeviceMatcher) (*Device, error) {
+	if s.ptr == nil {
+		return nil, ErrClosed
+	}
+	return findDevice(match, s.OutputDeviceCount(), s.DefaultOutputDeviceIndex(), s.OutputDevice)
+}
+
+func findDevice(match DeviceMatcher, count int, defaultIndex int, device func(index int) *Device) (*Device, error) {
+	for _, i := range searchOrder(count, defaultIndex, match == nil) {
+		d := device(i)
+		if d == nil {
+			continue
+		}
+		if match == nil || match(d) {
+			return d, nil
+		}
+		d.RemoveReference()
+	}
+	return nil, ErrorNoSuchDevice
+}
+
+// searchOrder returns the device indexes to try, the default device first.
+func searchOrder(count int, defaultIndex int, defaultOnly bool) []int {
+	indexes := make([]int, 0, max(count, 0))
+	if 0 <= defaultIndex && defaultIndex < count {
+		indexes = append(indexes, defaultIndex)
+	}
+	if defaultOnly {
+		return indexes
+	}
+	for i := 0; i < count; i++ {
+		if i != defaultIndex {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
@@ -0,0 +1,39 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSearchOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		count        int
+		defaultIndex int
+		defaultOnly  bool
+		want         []int
+	}{
+		{"default first", 4, 2, false, []int{2, 0, 1, 3}},
+		{"no default", 3, -1, false, []int{0, 1, 2}},
+		{"default only", 4, 2, true, []int{2}},
+		{"default only without default", 3, -1, true, []int{}},
+		{"no devices", 0, -1, false, []int{}},
+		{"stale default", 2, 5, false, []int{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchOrder(tt.count, tt.defaultIndex, tt.defaultOnly); !slices.Equal(got, tt.want) {
+				t.Errorf("searchOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// DeviceEventType is type of DeviceEvent.
+type DeviceEventType int
+
+// DeviceEventType enumeration.
+const (
+	DeviceAdded DeviceEventType = iota
+	DeviceRemoved
+	DefaultInputChanged
+	DefaultOutputChanged
+	DeviceCapabilitiesChanged
+)
+
+func (t DeviceEventType) String() string {
+	switch t {
+	case DeviceAdded:
+		return "DeviceAdded"
+	case DeviceRemoved:
+		return "DeviceRemoved"
+	case DefaultInputChanged:
+		return "DefaultInputChanged"
+	case DefaultOutputChanged:
+		return "DefaultOutputChanged"
+	case DeviceCapabilitiesChanged:
+		return "DeviceCapabilitiesChanged"
+	default:
+		return ""
+	}
+}
+
+// DeviceEvent is a change of the device list.
+type DeviceEvent struct {
+	Type DeviceEventType
+	// Device is the added, removed or changed device, or the new default device.
+	// It is the zero value when there is no longer a default device.
+	Device DeviceInfo
+	// Previous is the device before a DeviceCapabilitiesChanged,
+	// or the previous default device.
+	Previous DeviceInfo
+}
+
+// WatchDevices returns a channel of changes of the device list.
+// The first events describe the current devices as added.
+// Events are pumped with WaitEvents until ctx is done, and then the channel
+// is closed. FlushEvents and WaitEvents must not be called concurrently while
+// watching, but OnDevicesChange is still called.
+//
+// Possible errors:
+// * ErrClosed
+// * ErrorInvalid - not connected to a backend
+func (s *SoundIo) WatchDevices(ctx context.Context) (<-chan DeviceEvent, error) {
+	if s.ptr == nil {
+		return nil, ErrClosed
+	}
+	if s.CurrentBackend() == BackendNone {
+		return nil, ErrorInvalid
+	}
+
+	events := make(chan DeviceEvent, 16)
+	var mu sync.Mutex
+	var previous []DeviceInfo
+	closed := false
+	update := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		next := s.snapshotDevices()
+		for _, event := range diffDevices(previous, next) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+		previous = next
+	}
+
+	remove := s.AddDevicesChangeListener(update)
+	go func() {
+		update()
+		_ = s.WaitEvents(ctx)
+
+		// a pending update gives up sending once ctx is done
+		remove()
+		mu.Lock()
+		closed = true
+		close(events)
+		mu.Unlock()
+	}()
+	return events, nil
+}
+
+// diffDevices returns the events that turn prev into next:
+// removals, additions, capability changes, then default device changes.
+func diffDevices(prev []DeviceInfo, next []DeviceInfo) []DeviceEvent {
+	prevByKey := make(map[DeviceKey]*DeviceInfo, len(prev))
+	for i := range prev {
+		prevByKey[prev[i].Key()] = &prev[i]
+	}
+	nextByKey := make(map[DeviceKey]*DeviceInfo, len(next))
+	for i := range next {
+		nextByKey[next[i].Key()] = &next[i]
+	}
+
+	var events []DeviceEvent
+	for i := range prev {
+		if _, ok := nextByKey[prev[i].Key()]; !ok {
+			events = append(events, DeviceEvent{Type: DeviceRemoved, Device: prev[i]})
+		}
+	}
+	for i := range next {
+		if _, ok := prevByKey[next[i].Key()]; !ok {
+			events = append(events, DeviceEvent{Type: DeviceAdded, Device: next[i]})
+		}
+	}
+	for i := range next {
+		if p, ok := prevByKey[next[i].Key()]; ok && !sameCapabilities(p, &next[i]) {
+			events = append(events, DeviceEvent{Type: DeviceCapabilitiesChanged, Device: next[i], Previous: *p})
+		}
+	}
+
+	for _, change := range []struct {
+		aim       DeviceAim
+		eventType DeviceEventType
+	}{
+		{DeviceAimInput, DefaultInputChanged},
+		{DeviceAimOutput, DefaultOutputChanged},
+	} {
+		p := defaultDevice(prev, change.aim)
+		n := defaultDevice(next, change.aim)
+		if p.Key() != n.Key() {
+			events = append(events, DeviceEvent{Type: change.eventType, Device: n, Previous: p})
+		}
+	}
+	return events
+}
+
+// defaultDevice returns the default device of aim, or the zero value.
+func defaultDevice(devices []DeviceInfo, aim DeviceAim) DeviceInfo {
+	for _, d := range devices {
+		if d.Default && d.Aim == aim {
+			return d
+		}
+	}
+	return DeviceInfo{}
+}
+
+// sameCapabilities returns whether the descriptions of a device are equal,
+// ignoring whether it is the default device.
+func sameCapabilities(a *DeviceInfo, b *DeviceInfo) bool {
+	return a.Name == b.Name &&
+		slices.EqualFunc(a.Layouts, b.Layouts, (*ChannelLayout).Equal) &&
+		a.CurrentLayout.Equal(b.CurrentLayout) &&
+		slices.Equal(a.Formats, b.Formats) &&
+		a.CurrentFormat == b.CurrentFormat &&
+		slices.Equal(a.SampleRates, b.SampleRates) &&
+		a.SampleRateCurrent == b.SampleRateCurrent &&
+		a.SoftwareLatencyMin == b.SoftwareLatencyMin &&
+		a.SoftwareLatencyMax == b.SoftwareLatencyMax &&
+		a.SoftwareLatencyCurrent == b.SoftwareLatencyCurrent &&
+		a.ProbeError == b.ProbeError
+}
@@ -0,0 +1,114 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"slices"
+	"testing"
+)
+
+func testDevice(id string, aim DeviceAim, isDefault bool) DeviceInfo {
+	return DeviceInfo{
+		ID:          id,
+		Name:        id,
+		Aim:         aim,
+		Default:     isDefault,
+		Layouts:     []*ChannelLayout{NewChannelLayout("", ChannelIDFrontLeft, ChannelIDFrontRight)},
+		Formats:     []Format{FormatS16LE},
+		SampleRates: []SampleRateRange{{min: 48000, max: 48000}},
+	}
+}
+
+type eventSummary struct {
+	eventType DeviceEventType
+	device    string
+	previous  string
+}
+
+func summarize(events []DeviceEvent) []eventSummary {
+	summaries := make([]eventSummary, len(events))
+	for i, e := range events {
+		summaries[i] = eventSummary{e.Type, e.Device.ID, e.Previous.ID}
+	}
+	return summaries
+}
+
+func TestDiffDevices(t *testing.T) {
+	mic := testDevice("mic", DeviceAimInput, true)
+	speaker := testDevice("speaker", DeviceAimOutput, true)
+	headphones := testDevice("headphones", DeviceAimOutput, false)
+	prev := []DeviceInfo{mic, speaker, headphones}
+
+	t.Run("initial", func(t *testing.T) {
+		got := summarize(diffDevices(nil, prev))
+		want := []eventSummary{
+			{DeviceAdded, "mic", ""},
+			{DeviceAdded, "speaker", ""},
+			{DeviceAdded, "headphones", ""},
+			{DefaultInputChanged, "mic", ""},
+			{DefaultOutputChanged, "speaker", ""},
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("events = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		if events := diffDevices(prev, []DeviceInfo{mic, speaker, headphones}); len(events) != 0 {
+			t.Errorf("events = %v", summarize(events))
+		}
+	})
+
+	t.Run("headphones plugged in", func(t *testing.T) {
+		newSpeaker := speaker
+		newSpeaker.Default = false
+		newHeadphones := headphones
+		newHeadphones.Default = true
+		newHeadphones.SampleRates = []SampleRateRange{{min: 44100, max: 96000}}
+		got := summarize(diffDevices(prev, []DeviceInfo{mic, newSpeaker, newHeadphones}))
+		want := []eventSummary{
+			{DeviceCapabilitiesChanged, "headphones", "headphones"},
+			{DefaultOutputChanged, "headphones", "speaker"},
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("events = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		got := summarize(diffDevices(prev, []DeviceInfo{speaker, headphones}))
+		want := []eventSummary{
+			{DeviceRemoved, "mic", ""},
+			{DefaultInputChanged, "", "mic"},
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("events = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("raw device is distinct", func(t *testing.T) {
+		raw := mic
+		raw.Raw = true
+		raw.Default = false
+		got := summarize(diffDevices(prev, []DeviceInfo{mic, raw, speaker, headphones}))
+		want := []eventSummary{{DeviceAdded, "mic", ""}}
+		if !slices.Equal(got, want) {
+			t.Errorf("events = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("layouts compared by value", func(t *testing.T) {
+		copied := headphones
+		copied.Layouts = []*ChannelLayout{NewChannelLayout("Stereo", ChannelIDFrontLeft, ChannelIDFrontRight)}
+		if events := diffDevices(prev, []DeviceInfo{mic, speaker, copied}); len(events) != 0 {
+			t.Errorf("events = %v", summarize(events))
+		}
+	})
+}
@@ -0,0 +1,332 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"context"
+	"errors"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Tests against the dummy backend, which runs headless. newDummySoundIo
+// fails them when a device or stream reference is leaked, and running them
+// with GOEXPERIMENT=cgocheck2 checks every callback for cgo pointer violations.
+
+// dummyRunDuration is how long streams are run for.
+const dummyRunDuration = 200 * time.Millisecond
+
+func TestDummyDevices(t *testing.T) {
+	s := newDummySoundIo(t)
+	if backend := s.CurrentBackend(); backend != BackendDummy {
+		t.Fatalf("CurrentBackend() = %v, want %v", backend, BackendDummy)
+	}
+
+	for _, aim := range []DeviceAim{DeviceAimInput, DeviceAimOutput} {
+		count, defaultIndex, device := s.InputDeviceCount(), s.DefaultInputDeviceIndex(), s.InputDevice
+		if aim == DeviceAimOutput {
+			count, defaultIndex, device = s.OutputDeviceCount(), s.DefaultOutputDeviceIndex(), s.OutputDevice
+		}
+		if count <= 0 {
+			t.Fatalf("%v device count = %d", aim, count)
+		}
+		if defaultIndex < 0 || defaultIndex >= count {
+			t.Errorf("default %v device index = %d, count %d", aim, defaultIndex, count)
+		}
+		for i := 0; i < count; i++ {
+			d := device(i)
+			if d == nil {
+				t.Fatalf("%v device %d is nil", aim, i)
+			}
+			if d.Aim() != aim {
+				t.Errorf("%s: Aim() = %v, want %v", d.ID(), d.Aim(), aim)
+			}
+			if d.ID() == "" || d.Name() == "" {
+				t.Errorf("device %d has id %q and name %q", i, d.ID(), d.Name())
+			}
+			if err := d.ProbeError(); err != nil {
+				t.Errorf("%s: ProbeError() = %v", d.ID(), err)
+			}
+			if formats := d.Formats(); len(formats) == 0 || !d.SupportsFormat(d.CurrentFormat()) {
+				t.Errorf("%s: formats %v, current %v", d.ID(), formats, d.CurrentFormat())
+			}
+			if layouts := d.Layouts(); len(layouts) == 0 || !d.SupportsLayout(d.CurrentLayout()) {
+				t.Errorf("%s: layouts %v, current %v", d.ID(), layouts, d.CurrentLayout())
+			}
+			if rate := d.SampleRateCurrent(); !d.SupportsSampleRate(rate) {
+				t.Errorf("%s: current sample rate %d is not supported", d.ID(), rate)
+			}
+			if d.SoftwareLatencyMin() > d.SoftwareLatencyMax() {
+				t.Errorf("%s: software latency min %v > max %v", d.ID(), d.SoftwareLatencyMin(), d.SoftwareLatencyMax())
+			}
+			if err := d.Close(); err != nil {
+				t.Errorf("%s: Close() = %v", d.ID(), err)
+			}
+		}
+	}
+}
+
+func TestDummyOutStreamFormats(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	defer device.Close()
+
+	for _, format := range device.Formats() {
+		t.Run(format.String(), func(t *testing.T) {
+			stream, err := device.NewOutStream(&OutStreamConfig{Format: format})
+			if err != nil {
+				t.Fatalf("unable to open output stream: %s", err)
+			}
+			defer stream.Close()
+			if stream.Format() != format {
+				t.Errorf("Format() = %v, want %v", stream.Format(), format)
+			}
+
+			var frames atomic.Int64
+			var failed atomic.Pointer[error]
+			phase := 0.0
+			stream.SetWriteCallback(func(stream *OutStream, frameCountMin int, frameCountMax int) {
+				step := 2 * math.Pi * 440 / float64(stream.SampleRate())
+				for frameCountLeft := frameCountMax; frameCountLeft > 0; {
+					frameCount := frameCountLeft
+					areas, err := stream.BeginWrite(&frameCount)
+					if err != nil {
+						failed.CompareAndSwap(nil, &err)
+						return
+					}
+					if frameCount <= 0 {
+						break
+					}
+					for frame := 0; frame < frameCount; frame++ {
+						sample := math.Sin(phase)
+						for channel := 0; channel < areas.ChannelCount(); channel++ {
+							areas.WriteFloat64(channel, frame, sample)
+						}
+						phase = math.Mod(phase+step, 2*math.Pi)
+					}
+					if err := stream.EndWrite(); err != nil {
+						failed.CompareAndSwap(nil, &err)
+						return
+					}
+					frameCountLeft -= frameCount
+					frames.Add(int64(frameCount))
+				}
+			})
+			stream.SetErrorCallback(func(stream *OutStream, err error) {
+				failed.CompareAndSwap(nil, &err)
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), dummyRunDuration)
+			defer cancel()
+			if err := stream.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Run() = %v, want %v", err, context.DeadlineExceeded)
+			}
+			if err := failed.Load(); err != nil {
+				t.Errorf("write callback failed: %s", *err)
+			}
+			if frames.Load() == 0 {
+				t.Error("no frames were written")
+			}
+		})
+	}
+}
+
+func TestDummyInStreamFormats(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.InputDevice(s.DefaultInputDeviceIndex())
+	defer device.Close()
+
+	for _, format := range device.Formats() {
+		t.Run(format.String(), func(t *testing.T) {
+			stream, err := device.NewInStream(&InStreamConfig{Format: format})
+			if err != nil {
+				t.Fatalf("unable to open input stream: %s", err)
+			}
+			defer stream.Close()
+			if stream.Format() != format {
+				t.Errorf("Format() = %v, want %v", stream.Format(), format)
+			}
+
+			var frames atomic.Int64
+			var failed atomic.Pointer[error]
+			stream.SetReadCallback(func(stream *InStream, frameCountMin int, frameCountMax int) {
+				for frameCountLeft := frameCountMax; frameCountLeft > 0; {
+					frameCount := frameCountLeft
+					areas, err := stream.BeginRead(&frameCount)
+					if err != nil {
+						failed.CompareAndSwap(nil, &err)
+						return
+					}
+					if frameCount <= 0 {
+						break
+					}
+					// a nil areas is a hole in the buffer
+					if areas != nil {
+						for frame := 0; frame < frameCount; frame++ {
+							for channel := 0; channel < areas.ChannelCount(); channel++ {
+								if v := areas.ReadFloat64(channel, frame); v < -1 || v > 1 {
+									err := errors.New("sample out of range")
+									failed.CompareAndSwap(nil, &err)
+								}
+							}
+						}
+					}
+					if err := stream.EndRead(); err != nil {
+						failed.CompareAndSwap(nil, &err)
+						return
+					}
+					frameCountLeft -= frameCount
+					frames.Add(int64(frameCount))
+				}
+			})
+			stream.SetErrorCallback(func(stream *InStream, err error) {
+				failed.CompareAndSwap(nil, &err)
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), dummyRunDuration)
+			defer cancel()
+			if err := stream.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Run() = %v, want %v", err, context.DeadlineExceeded)
+			}
+			if err := failed.Load(); err != nil {
+				t.Errorf("read callback failed: %s", *err)
+			}
+			if frames.Load() == 0 {
+				t.Error("no frames were read")
+			}
+		})
+	}
+}
+
+// pausableStream is the part of InStream and OutStream testStreamControls uses.
+type pausableStream interface {
+	Start() error
+	Pause(pause bool) error
+}
+
+// testStreamControls starts, pauses and resumes stream, whose callback sends
+// to calls, and checks that the callback is not called while paused.
+func testStreamControls(t *testing.T, stream pausableStream, calls <-chan struct{}) {
+	t.Helper()
+	waitCall := func(what string) {
+		t.Helper()
+		select {
+		case <-calls:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("callback was not called %s", what)
+		}
+	}
+
+	if err := stream.Start(); err != nil {
+		t.Fatalf("unable to start stream: %s", err)
+	}
+	waitCall("after Start")
+
+	if err := stream.Pause(true); err != nil {
+		t.Fatalf("Pause(true) = %v", err)
+	}
+	// drain a call that raced with pausing
+	time.Sleep(dummyRunDuration / 4)
+	select {
+	case <-calls:
+	default:
+	}
+	select {
+	case <-calls:
+		t.Error("callback was called while paused")
+	case <-time.After(dummyRunDuration):
+	}
+
+	if err := stream.Pause(false); err != nil {
+		t.Fatalf("Pause(false) = %v", err)
+	}
+	waitCall("after resuming")
+}
+
+func TestDummyOutStreamControls(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	defer device.Close()
+
+	stream, err := device.NewOutStream(&OutStreamConfig{})
+	if err != nil {
+		t.Fatalf("unable to open output stream: %s", err)
+	}
+	defer stream.Close()
+
+	calls := make(chan struct{}, 1)
+	stream.SetWriteCallback(func(stream *OutStream, frameCountMin int, frameCountMax int) {
+		runtime.GC()
+		frameCount := frameCountMax
+		if frameCount > 0 {
+			if _, err := stream.BeginWrite(&frameCount); err == nil {
+				_ = stream.EndWrite()
+			}
+		}
+		select {
+		case calls <- struct{}{}:
+		default:
+		}
+	})
+	testStreamControls(t, stream, calls)
+
+	if latency, err := stream.Latency(0); err != nil || latency < 0 {
+		t.Errorf("Latency() = %v, %v", latency, err)
+	}
+	if err := stream.ClearBuffer(); err != nil {
+		t.Errorf("ClearBuffer() = %v", err)
+	}
+
+	// the dummy backend may not support volume
+	switch err := stream.SetVolume(0.5); {
+	case err == nil:
+		if volume := stream.Volume(); math.Abs(float64(volume)-0.5) > 1e-6 {
+			t.Errorf("Volume() = %v, want 0.5", volume)
+		}
+	case errors.Is(err, ErrorIncompatibleBackend), errors.Is(err, ErrorIncompatibleDevice):
+	default:
+		t.Errorf("SetVolume() = %v", err)
+	}
+}
+
+func TestDummyInStreamControls(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.InputDevice(s.DefaultInputDeviceIndex())
+	defer device.Close()
+
+	stream, err := device.NewInStream(&InStreamConfig{})
+	if err != nil {
+		t.Fatalf("unable to open input stream: %s", err)
+	}
+	defer stream.Close()
+
+	calls := make(chan struct{}, 1)
+	stream.SetReadCallback(func(stream *InStream, frameCountMin int, frameCountMax int) {
+		runtime.GC()
+		frameCount := frameCountMax
+		if frameCount > 0 {
+			if _, err := stream.BeginRead(&frameCount); err == nil {
+				_ = stream.EndRead()
+			}
+		}
+		select {
+		case calls <- struct{}{}:
+		default:
+		}
+	})
+	testStreamControls(t, stream, calls)
+
+	if latency, err := stream.Latency(); err != nil || latency < 0 {
+		t.Errorf("Latency() = %v, %v", latency, err)
+	}
+}
@@ -7,41 +7,92 @@
 
 package soundio
 
-/*
-#include <soundio/soundio.h>
-*/
-import "C"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrClosed is returned when using a closed SoundIo, InStream or OutStream,
+// or a released Device.
+var ErrClosed = errors.New("soundio: use of closed object")
 
 // Error is libsoundio error.
 type Error int
 
 // Error enumeration.
 const (
-	ErrorNone                = Error(C.SoundIoErrorNone)
-	ErrorNoMem               = Error(C.SoundIoErrorNoMem)            // Out of memory
-	ErrorInitAudioBackend    = Error(C.SoundIoErrorInitAudioBackend) // The backend does not appear to be active or running
-	ErrorSystemResources     = Error(C.SoundIoErrorSystemResources)  // A system resource other than memory was not available
-	ErrorOpeningDevice       = Error(C.SoundIoErrorOpeningDevice)    // Attempted to open a device and failed
-	ErrorNoSuchDevice        = Error(C.SoundIoErrorNoSuchDevice)
-	ErrorInvalid             = Error(C.SoundIoErrorInvalid)             // The programmer did not comply with the API
-	ErrorBackendUnavailable  = Error(C.SoundIoErrorBackendUnavailable)  // libsoundio was compiled without support for that backend
-	ErrorStreaming           = Error(C.SoundIoErrorStreaming)           // An open stream had an error that can only be recovered from by destroying the stream and creating it again
-	ErrorIncompatibleDevice  = Error(C.SoundIoErrorIncompatibleDevice)  // Attempted to use a device with parameters it cannot support
-	ErrorNoSuchClient        = Error(C.SoundIoErrorNoSuchClient)        // When JACK returns `JackNoSuchClient`
-	ErrorIncompatibleBackend = Error(C.SoundIoErrorIncompatibleBackend) // Attempted to use parameters that the backend cannot support.
-	ErrorBackendDisconnected = Error(C.SoundIoErrorBackendDisconnected) // Backend server shutdown or became inactive
-	ErrorInterrupted         = Error(C.SoundIoErrorInterrupted)
-	ErrorUnderflow           = Error(C.SoundIoErrorUnderflow)      // Buffer underrun occurred
-	ErrorEncodingString      = Error(C.SoundIoErrorEncodingString) // Unable to convert to or from UTF-8 to the native string format
+	ErrorNone             Error = iota
+	ErrorNoMem                  // Out of memory
+	ErrorInitAudioBackend       // The backend does not appear to be active or running
+	ErrorSystemResources        // A system resource other than memory was not available
+	ErrorOpeningDevice          // Attempted to open a device and failed
+	ErrorNoSuchDevice
+	ErrorInvalid             // The programmer did not comply with the API
+	ErrorBackendUnavailable  // libsoundio was compiled without support for that backend
+	ErrorStreaming           // An open stream had an error that can only be recovered from by destroying the stream and creating it again
+	ErrorIncompatibleDevice  // Attempted to use a device with parameters it cannot support
+	ErrorNoSuchClient        // When JACK returns `JackNoSuchClient`
+	ErrorIncompatibleBackend // Attempted to use parameters that the backend cannot support.
+	ErrorBackendDisconnected // Backend server shutdown or became inactive
+	ErrorInterrupted
+	ErrorUnderflow      // Buffer underrun occurred
+	ErrorEncodingString // Unable to convert to or from UTF-8 to the native string format
 )
 
-func (e Error) Error() string {
-	return C.GoString(C.soundio_strerror(C.int(e)))
+// OpError is the error of an operation on a SoundIo, device or stream.
+// It wraps the cause, so that errors.Is(err, ErrorIncompatibleDevice) works.
+type OpError struct {
+	// Op is the operation, such as "connect", "open out stream" or "start".
+	Op string
+	// DeviceID is the id of the device, or empty.
+	DeviceID string
+	// Backend is the backend, or BackendNone when not connected.
+	Backend Backend
+	// Config is the *InStreamConfig or *OutStreamConfig of the stream, or nil.
+	Config any
+	// Err is the cause.
+	Err error
+}
+
+func (e *OpError) Error() string {
+	s := "soundio: " + e.Op
+	if e.DeviceID != "" {
+		s += fmt.Sprintf(" %q", e.DeviceID)
+	}
+	if e.Backend != BackendNone {
+		s += " on " + e.Backend.String()
+	}
+	return s + ": " + e.Err.Error()
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// IsDisconnect returns whether err means that the backend disconnected.
+// SoundIo must be connected again, and the streams opened again.
+func IsDisconnect(err error) bool {
+	return errors.Is(err, ErrorBackendDisconnected)
 }
 
-func convertToError(err C.int) error {
-	if err == C.SoundIoErrorNone {
-		return nil
+// RequiresRecreation returns whether err can only be recovered from by
+// closing the stream and opening it again: ErrorStreaming, or a disconnect.
+func RequiresRecreation(err error) bool {
+	return errors.Is(err, ErrorStreaming) || IsDisconnect(err)
+}
+
+// IsRecoverable returns whether the operation might succeed when retried,
+// after the stream is opened again or SoundIo is connected again as needed.
+func IsRecoverable(err error) bool {
+	var e Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e {
+	case ErrorStreaming, ErrorBackendDisconnected, ErrorInterrupted, ErrorUnderflow,
+		ErrorOpeningDevice, ErrorSystemResources:
+		return true
+	default:
+		return false
 	}
-	return Error(err)
 }
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestOpError(t *testing.T) {
+	err := fmt.Errorf("playing: %w", &OpError{
+		Op:       "open out stream",
+		DeviceID: "hw:0,0",
+		Backend:  BackendAlsa,
+		Config:   &OutStreamConfig{Format: FormatS16LE},
+		Err:      ErrorIncompatibleDevice,
+	})
+	if !errors.Is(err, ErrorIncompatibleDevice) {
+		t.Errorf("%v is not ErrorIncompatibleDevice", err)
+	}
+	var opErr *OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("%v is not an OpError", err)
+	}
+	if config, ok := opErr.Config.(*OutStreamConfig); !ok || config.Format != FormatS16LE {
+		t.Errorf("Config = %v", opErr.Config)
+	}
+
+	opErr.Err = errors.New("cause")
+	if got, want := opErr.Error(), `soundio: open out stream "hw:0,0" on ALSA: cause`; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	connectErr := &OpError{Op: "connect", Err: errors.New("cause")}
+	if got, want := connectErr.Error(), "soundio: connect: cause"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		err          error
+		recoverable  bool
+		disconnect   bool
+		recreateOnly bool
+	}{
+		{ErrorStreaming, true, false, true},
+		{ErrorBackendDisconnected, true, true, true},
+		{ErrorUnderflow, true, false, false},
+		{ErrorInvalid, false, false, false},
+		{ErrorIncompatibleDevice, false, false, false},
+		{ErrClosed, false, false, false},
+		{&OpError{Op: "stream", Err: ErrorStreaming}, true, false, true},
+		{nil, false, false, false},
+	}
+	for _, tt := range tests {
+		if got := IsRecoverable(tt.err); got != tt.recoverable {
+			t.Errorf("IsRecoverable(%v) = %t", tt.err, got)
+		}
+		if got := IsDisconnect(tt.err); got != tt.disconnect {
+			t.Errorf("IsDisconnect(%v) = %t", tt.err, got)
+		}
+		if got := RequiresRecreation(tt.err); got != tt.recreateOnly {
+			t.Errorf("RequiresRecreation(%v) = %t", tt.err, got)
+		}
+	}
+}
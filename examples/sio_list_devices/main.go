@@ -9,6 +9,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -27,25 +29,26 @@ func main() {
 		watchEvents bool
 		backend     string
 		shortOutput bool
+		jsonOutput  bool
 	)
 	flag.NewFlagSet("help", flag.ExitOnError)
 	flag.BoolVar(&watchEvents, "watch", false, "watch")
 	flag.StringVar(&backend, "backend", "", "dummy|alsa|pulseaudio|jack|coreaudio|wasapi")
 	flag.BoolVar(&shortOutput, "short", false, "short")
+	flag.BoolVar(&jsonOutput, "json", false, "json")
 	flag.Parse()
 
 	enumBackend, err := parseBackend(backend)
 	if err != nil {
 		log.Println(err)
 	} else {
-		ctx := context.Background()
-		parentCtx := signalContext(ctx)
-		err := realMain(parentCtx, enumBackend, watchEvents, shortOutput)
-		if err != nil {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+		err := realMain(ctx, enumBackend, watchEvents, shortOutput, jsonOutput)
+		stop()
+		if err != nil && !errors.Is(err, context.Canceled) {
 			exitCode = 1
 			log.Println(err)
 		}
-		parentCtx.Done()
 	}
 	os.Exit(exitCode)
 }
@@ -84,100 +87,98 @@ func printChannelLayout(layout *soundio.ChannelLayout) {
 	}
 }
 
-func printDevice(device *soundio.Device, shortOutput bool, isDefault bool) {
+func printDevice(device *soundio.DeviceInfo, shortOutput bool) {
 	defaultStr := ""
-	if isDefault {
+	if device.Default {
 		defaultStr = " (default)"
 	}
 
 	rawStr := ""
-	if device.Raw() {
+	if device.Raw {
 		rawStr = " (raw)"
 	}
 
-	log.Printf("%s%s%s", device.Name(), defaultStr, rawStr)
+	log.Printf("%s%s%s", device.Name, defaultStr, rawStr)
 	if shortOutput {
 		return
 	}
 
-	log.Printf("  id: %s", device.ID())
+	log.Printf("  id: %s", device.ID)
 
-	if device.ProbeError() == nil {
+	if device.ProbeError == soundio.ErrorNone {
 		log.Println("  channel layouts:")
-		for _, layout := range device.Layouts() {
+		for _, layout := range device.Layouts {
 			printChannelLayout(layout)
 		}
-		if device.CurrentLayout().ChannelCount() > 0 {
+		if device.CurrentLayout != nil {
 			log.Print("  current layout: ")
-			printChannelLayout(device.CurrentLayout())
+			printChannelLayout(device.CurrentLayout)
 		}
 
 		log.Println("  sample rates:")
-		for _, rate := range device.SampleRates() {
+		for _, rate := range device.SampleRates {
 			log.Printf("    %d - %d", rate.Min(), rate.Max())
 		}
-		if device.SampleRateCurrent() > 0 {
-			log.Printf("  current sample rate: %d", device.SampleRateCurrent())
+		if device.SampleRateCurrent > 0 {
+			log.Printf("  current sample rate: %d", device.SampleRateCurrent)
 		}
 
-		formats := make([]string, device.FormatCount())
-		for i, format := range device.Formats() {
+		formats := make([]string, len(device.Formats))
+		for i, format := range device.Formats {
 			formats[i] = fmt.Sprint(format)
 		}
 		log.Printf("  formats: %s", strings.Join(formats, ", "))
 
-		if device.CurrentFormat() != soundio.FormatInvalid {
-			log.Printf("  current format: %s", device.CurrentFormat())
+		if device.CurrentFormat != soundio.FormatInvalid {
+			log.Printf("  current format: %s", device.CurrentFormat)
 		}
 
-		log.Printf("  min software latency: %0.8f sec", device.SoftwareLatencyMin())
-		log.Printf("  max software latency: %0.8f sec", device.SoftwareLatencyMax())
-		if device.SoftwareLatencyCurrent() != 0.0 {
-			log.Printf("  current software latency: %0.8f sec", device.SoftwareLatencyCurrent())
+		log.Printf("  min software latency: %0.8f sec", device.SoftwareLatencyMin)
+		log.Printf("  max software latency: %0.8f sec", device.SoftwareLatencyMax)
+		if device.SoftwareLatencyCurrent != 0.0 {
+			log.Printf("  current software latency: %0.8f sec", device.SoftwareLatencyCurrent)
 		}
 	} else {
-		log.Printf("  probe error: %s", device.ProbeError())
+		log.Printf("  probe error: %s", device.ProbeError)
 	}
 
 	log.Println()
 }
 
-func listDevices(s *soundio.SoundIo, shortOutput bool) {
-	outputCount := s.OutputDeviceCount()
-	inputCount := s.InputDeviceCount()
+func listDevices(s *soundio.SoundIo, shortOutput bool, jsonOutput bool) error {
+	devices, err := s.Devices()
+	if err != nil {
+		return err
+	}
 
-	defaultOutput := s.DefaultOutputDeviceIndex()
-	defaultInput := s.DefaultInputDeviceIndex()
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(devices)
+	}
 
 	log.Println("--------Input Devices--------")
-	for i := 0; i < inputCount; i++ {
-		device := s.InputDevice(i)
-		printDevice(device, shortOutput, defaultInput == i)
-		device.RemoveReference()
+	for i := range devices {
+		if devices[i].Aim == soundio.DeviceAimInput {
+			printDevice(&devices[i], shortOutput)
+		}
 	}
 
 	log.Println("--------Output Devices--------")
-	for i := 0; i < outputCount; i++ {
-		device := s.OutputDevice(i)
-		printDevice(device, shortOutput, defaultOutput == i)
-		device.RemoveReference()
+	for i := range devices {
+		if devices[i].Aim == soundio.DeviceAimOutput {
+			printDevice(&devices[i], shortOutput)
+		}
 	}
 
 	log.Println()
-	log.Printf("%d devices found", inputCount+outputCount)
+	log.Printf("%d devices found", len(devices))
+	return nil
 }
 
-func realMain(ctx context.Context, backend soundio.Backend, watchEvents bool, shortOutput bool) error {
-	opts := make([]soundio.Option, 0)
-	opts = append(opts, soundio.WithBackend(backend))
-	if watchEvents {
-		opts = append(opts, soundio.WithOnDevicesChange(func(s *soundio.SoundIo) {
-			log.Println("devices changed")
-			listDevices(s, shortOutput)
-		}))
-	}
-
-	s := soundio.Create(opts...)
+func realMain(ctx context.Context, backend soundio.Backend, watchEvents bool, shortOutput bool, jsonOutput bool) error {
+	s := soundio.Create(soundio.WithBackend(backend))
+	defer s.Close()
 
 	err := s.Connect()
 	if err != nil {
@@ -185,40 +186,26 @@ func realMain(ctx context.Context, backend soundio.Backend, watchEvents bool, sh
 	}
 
 	if watchEvents {
-		return s.WaitEvents(ctx)
+		return watchDevices(ctx, s, shortOutput)
 	}
-	s.FlushEvents()
-	listDevices(s, shortOutput)
-
-	return nil
+	return listDevices(s, shortOutput, jsonOutput)
 }
 
-func signalContext(ctx context.Context) context.Context {
-	parent, cancelParent := context.WithCancel(ctx)
-	go func() {
-		defer cancelParent()
-
-		sig := make(chan os.Signal, 1)
-		signal.Notify(sig,
-			syscall.SIGHUP,
-			syscall.SIGINT,
-			syscall.SIGTERM,
-			syscall.SIGQUIT,
-		)
-		defer signal.Stop(sig)
-
-		select {
-		case <-parent.Done():
-			log.Println("Cancel from parent")
-			return
-		case s := <-sig:
-			switch s {
-			case syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
-				log.Println("Stop!")
-				return
-			}
+func watchDevices(ctx context.Context, s *soundio.SoundIo, shortOutput bool) error {
+	events, err := s.WatchDevices(ctx)
+	if err != nil {
+		return err
+	}
+	for event := range events {
+		switch event.Type {
+		case soundio.DeviceAdded, soundio.DeviceCapabilitiesChanged:
+			log.Printf("%s %s:", event.Type, event.Device.Aim)
+			printDevice(&event.Device, shortOutput)
+		case soundio.DeviceRemoved:
+			log.Printf("%s %s: %s", event.Type, event.Device.Aim, event.Device.Name)
+		case soundio.DefaultInputChanged, soundio.DefaultOutputChanged:
+			log.Printf("%s: %q -> %q", event.Type, event.Previous.Name, event.Device.Name)
 		}
-	}()
-
-	return parent
+	}
+	return ctx.Err()
 }
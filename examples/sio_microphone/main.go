@@ -9,51 +9,29 @@ package main
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 
 	soundio "github.com/crow-misia/go-libsoundio"
+	"github.com/crow-misia/go-libsoundio/resample"
 	"github.com/glycerine/rbuf"
 )
 
+// chunkFrames is the most frames moved by one BeginRead or BeginWrite.
+const chunkFrames = 1024
+
 var overflowCount = 0
 var underflowCount = 0
 var exitCode = 0
 
-var prioritizedFormats = []soundio.Format{
-	soundio.FormatFloat32NE,
-	soundio.FormatFloat32FE,
-	soundio.FormatS32NE,
-	soundio.FormatS32FE,
-	soundio.FormatS24NE,
-	soundio.FormatS24FE,
-	soundio.FormatS16NE,
-	soundio.FormatS16FE,
-	soundio.FormatFloat64NE,
-	soundio.FormatFloat64FE,
-	soundio.FormatU32NE,
-	soundio.FormatU32FE,
-	soundio.FormatU24NE,
-	soundio.FormatU24FE,
-	soundio.FormatU16NE,
-	soundio.FormatU16FE,
-	soundio.FormatS8,
-	soundio.FormatU8,
-}
-
-var prioritizedSampleRates = []int{
-	48000,
-	44100,
-	96000,
-	24000,
-}
-
 func main() {
 	var (
 		backend        string
@@ -77,14 +55,13 @@ func main() {
 		log.Println(err)
 		exitCode = 1
 	} else {
-		ctx := context.Background()
-		parentCtx := signalContext(ctx)
-		err := realMain(parentCtx, enumBackend, inputDeviceId, inputIsRaw, outputDeviceId, outputIsRaw, latencySec)
-		if err != nil {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+		err := realMain(ctx, enumBackend, inputDeviceId, inputIsRaw, outputDeviceId, outputIsRaw, latencySec)
+		stop()
+		if err != nil && !errors.Is(err, context.Canceled) {
 			exitCode = 1
 			log.Println(err)
 		}
-		parentCtx.Done()
 	}
 
 	os.Exit(exitCode)
@@ -111,51 +88,28 @@ func parseBackend(str string) (soundio.Backend, error) {
 	}
 }
 
-func selectDevice(s *soundio.SoundIo, deviceId string, isRaw bool, getDeviceCount func(io *soundio.SoundIo) int, getDefaultIndex func(io *soundio.SoundIo) int, getDevice func(io *soundio.SoundIo, index int) *soundio.Device) (*soundio.Device, error) {
-	var selectedDevice *soundio.Device
-	if len(deviceId) > 0 {
-		count := getDeviceCount(s)
-		for i := 0; i < count; i++ {
-			device := getDevice(s, i)
-			if device.Raw() == isRaw && deviceId == device.ID() {
-				selectedDevice = device
-				break
-			}
-			device.RemoveReference()
-		}
-		if selectedDevice == nil {
-			return nil, fmt.Errorf("invalid device id: %s", deviceId)
-		}
-	} else {
-		deviceIndex := getDefaultIndex(s)
-		selectedDevice = getDevice(s, deviceIndex)
-		if selectedDevice == nil {
-			return nil, errors.New("no input devices available")
-		}
+func deviceMatcher(deviceId string, isRaw bool) soundio.DeviceMatcher {
+	if len(deviceId) == 0 {
+		return nil
 	}
-	return selectedDevice, nil
+	return soundio.MatchAll(soundio.MatchID(deviceId), soundio.MatchRaw(isRaw))
 }
 
 func realMain(ctx context.Context, backend soundio.Backend, inputDeviceId string, inputIsRaw bool, outputDeviceId string, outputIsRaw bool, latencySec float64) error {
-	_, cancelParent := context.WithCancel(ctx)
-	defer cancelParent()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
 	s := soundio.Create(soundio.WithBackend(backend))
+	defer s.Close()
 
 	err := s.Connect()
 	if err != nil {
 		return err
 	}
 
-	selectedInputDevice, err := selectDevice(s, inputDeviceId, inputIsRaw, func(io *soundio.SoundIo) int {
-		return io.InputDeviceCount()
-	}, func(io *soundio.SoundIo) int {
-		return io.DefaultInputDeviceIndex()
-	}, func(io *soundio.SoundIo, index int) *soundio.Device {
-		return io.InputDevice(index)
-	})
+	selectedInputDevice, err := s.FindInputDevice(deviceMatcher(inputDeviceId, inputIsRaw))
 	if err != nil {
-		return err
+		return fmt.Errorf("no input device found: %w", err)
 	}
 	defer selectedInputDevice.RemoveReference()
 	log.Printf("Input device: %s", selectedInputDevice.Name())
@@ -163,15 +117,9 @@ func realMain(ctx context.Context, backend soundio.Backend, inputDeviceId string
 		return fmt.Errorf("unable to probe device: %s", selectedInputDevice.ProbeError())
 	}
 
-	selectedOutputDevice, err := selectDevice(s, outputDeviceId, outputIsRaw, func(io *soundio.SoundIo) int {
-		return io.OutputDeviceCount()
-	}, func(io *soundio.SoundIo) int {
-		return io.DefaultOutputDeviceIndex()
-	}, func(io *soundio.SoundIo, index int) *soundio.Device {
-		return io.OutputDevice(index)
-	})
+	selectedOutputDevice, err := s.FindOutputDevice(deviceMatcher(outputDeviceId, outputIsRaw))
 	if err != nil {
-		return err
+		return fmt.Errorf("no output device found: %w", err)
 	}
 	defer selectedOutputDevice.RemoveReference()
 	log.Printf("Output device: %s", selectedOutputDevice.Name())
@@ -179,195 +127,149 @@ func realMain(ctx context.Context, backend soundio.Backend, inputDeviceId string
 		return fmt.Errorf("unable to probe device: %s", selectedOutputDevice.ProbeError())
 	}
 
-	selectedInputDevice.SortChannelLayouts()
-	selectedOutputDevice.SortChannelLayouts()
-	layout := soundio.BestMatchingLayout(selectedOutputDevice, selectedInputDevice)
-	if layout == nil {
-		return errors.New("channel layouts not compatible")
+	// Parameters common to both devices are preferred. Otherwise the input
+	// is converted to the output format, layout and sample rate.
+	duplex, err := soundio.NegotiateDuplex(selectedInputDevice, selectedOutputDevice, &soundio.StreamPreferences{
+		SoftwareLatency: latencySec,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to negotiate streams: %s", err)
 	}
-	channels := layout.ChannelCount()
+	inConfig, outConfig, plan := duplex.In, duplex.Out, duplex.OutPlan
+	log.Printf("Sample rate: %d -> %d", inConfig.SampleRate, outConfig.SampleRate)
+	log.Printf("Format: %s -> %s", inConfig.Format, outConfig.Format)
 
-	sampleRate := 0
-	for _, rate := range prioritizedSampleRates {
-		if selectedInputDevice.SupportsSampleRate(rate) && selectedOutputDevice.SupportsSampleRate(rate) {
-			sampleRate = rate
-			break
+	var remixer *soundio.Remixer
+	if plan.Remix {
+		remixer, err = soundio.NewRemixer(inConfig.Layout, outConfig.Layout)
+		if err != nil {
+			return err
 		}
 	}
-	if sampleRate == 0 {
-		return errors.New("incompatible sample rates")
+	inChannels := inConfig.Layout.ChannelCount()
+	channels := outConfig.Layout.ChannelCount()
+	inSampleRate, outSampleRate := inConfig.SampleRate, outConfig.SampleRate
+
+	// Audio is passed from input to output as native endian float32,
+	// remixed to the output layout and resampled when the devices run at different rates.
+	var resampler *resample.Resampler
+	if plan.Resample {
+		resampler, err = resample.New(channels, inSampleRate, outSampleRate, resample.Medium)
+		if err != nil {
+			return err
+		}
 	}
-	log.Printf("Sample rate: %d", sampleRate)
+	frameBytes := channels * 4
+	capacity := int(2*latencySec*float64(outSampleRate)) * frameBytes
+	ringBuffer := rbuf.NewFixedSizeRingBuf(capacity)
+	_, _ = ringBuffer.Write(make([]byte, capacity/2/frameBytes*frameBytes))
+	log.Printf("capacity %d", capacity)
 
-	format := soundio.FormatInvalid
-	for _, f := range prioritizedFormats {
-		if selectedInputDevice.SupportsFormat(f) && selectedOutputDevice.SupportsFormat(f) {
-			format = f
-			break
-		}
+	inSamples := make([]float32, chunkFrames*inChannels)
+	remixed := inSamples
+	if remixer != nil {
+		remixed = make([]float32, chunkFrames*channels)
 	}
-	if format == soundio.FormatInvalid {
-		return errors.New("incompatible sample formats")
+	resampled := remixed
+	if resampler != nil {
+		resampled = make([]float32, resampler.MaxOutput(chunkFrames)*channels)
 	}
-	log.Printf("Format: %s", format)
+	inBytes := make([]byte, len(resampled)*4)
 
-	var ringBuffer *rbuf.FixedSizeRingBuf
-
-	inConfig := &soundio.InStreamConfig{
-		Format:          format,
-		Layout:          layout,
-		SampleRate:      sampleRate,
-		SoftwareLatency: latencySec,
-	}
 	instream, err := selectedInputDevice.NewInStream(inConfig)
 	if err != nil {
 		return fmt.Errorf("unable to open input device: %s", err)
 	}
-	defer instream.Destroy()
+	defer instream.Close()
 
 	instream.SetReadCallback(func(stream *soundio.InStream, frameCountMin int, frameCountMax int) {
-		frameBytes := layout.ChannelCount() * instream.BytesPerFrame()
-		freeBytes := ringBuffer.N - ringBuffer.Readable
-		freeCount := freeBytes / frameBytes
-		if frameCountMin > freeCount {
-			log.Println("ring buffer overflow")
-			return
-		}
-		writeFrames := freeCount
-		if writeFrames > frameCountMax {
-			writeFrames = frameCountMax
-		}
-
-		frameLeft := writeFrames
-
-		for {
-			frameCount := frameLeft
-			if frameLeft <= 0 {
-				break
-			}
-
+		frameLeft := frameCountMax
+		for frameLeft > 0 {
+			frameCount := min(frameLeft, chunkFrames)
 			areas, err := stream.BeginRead(&frameCount)
 			if err != nil {
-				log.Printf("begin read error: %s", err)
-				cancelParent()
+				cancel(fmt.Errorf("begin read error: %w", err))
 				return
 			}
 			if frameCount <= 0 {
 				break
 			}
-			if areas != nil {
-				for frame := 0; frame < frameCount; frame++ {
-					for ch := 0; ch < channels; ch++ {
-						buffer := areas.Buffer(ch, frame)
-						_, err = ringBuffer.Write(buffer)
-						if err != nil {
-							log.Printf("ringbuffer write error: %s %d", err, len(buffer))
-						}
-					}
-				}
+			samples := inSamples[:frameCount*inChannels]
+			if areas == nil {
+				clear(samples)
+			} else {
+				areas.ReadInterleavedFloat32(samples)
 			}
 			err = stream.EndRead()
 			if err != nil {
-				log.Printf("end read error: %s", err)
-				cancelParent()
+				cancel(fmt.Errorf("end read error: %w", err))
 				return
 			}
-
 			frameLeft -= frameCount
+
+			if remixer != nil {
+				produced := remixer.Process(remixed, samples)
+				samples = remixed[:produced*channels]
+			}
+			if resampler != nil {
+				_, produced := resampler.Process(resampled, samples)
+				samples = resampled[:produced*channels]
+			}
+			buffer := encodeFloat32(inBytes, samples)
+			if ringBuffer.N-ringBuffer.Readable < len(buffer) {
+				log.Println("ring buffer overflow")
+				continue
+			}
+			_, _ = ringBuffer.Write(buffer)
 		}
 	})
 	instream.SetOverflowCallback(func(stream *soundio.InStream) {
 		overflowCount++
 		log.Printf("overflow %d", overflowCount)
 	})
+	instream.SetErrorCallback(func(stream *soundio.InStream, err error) {
+		cancel(fmt.Errorf("input stream error: %w", err))
+	})
+
+	outSamples := make([]float32, chunkFrames*channels)
+	outBytes := make([]byte, len(outSamples)*4)
 
-	outConfig := &soundio.OutStreamConfig{
-		Format:          format,
-		Layout:          layout,
-		SampleRate:      sampleRate,
-		SoftwareLatency: latencySec,
-	}
 	outstream, err := selectedOutputDevice.NewOutStream(outConfig)
 	if err != nil {
 		return fmt.Errorf("unable to open output device: %s", err)
 	}
-	defer outstream.Destroy()
+	defer outstream.Close()
 
-	log.Printf("format: %s", format)
-	log.Printf("layout name: %s", layout.Name())
-	log.Printf("layout channel count: %d", channels)
-	log.Printf("sample rate: %d", sampleRate)
+	log.Printf("layout name: %s -> %s", inConfig.Layout.Name(), outConfig.Layout.Name())
+	log.Printf("layout channel count: %d -> %d", inChannels, channels)
 	log.Printf("latency seconds: %f sec", latencySec)
 
 	outstream.SetWriteCallback(func(stream *soundio.OutStream, frameCountMin int, frameCountMax int) {
-		channelCount := stream.Layout().ChannelCount()
-
-		fillBytes := ringBuffer.Readable
-		fillCount := fillBytes / (stream.BytesPerFrame() * channels)
-
-		if frameCountMin > fillCount {
-			// Ring buffer does not have enough data, fill with zeroes.
-			frameCount := frameCountMin
-			if frameCount <= 0 {
-				return
-			}
+		// Fill with zeroes when the ring buffer does not have enough data.
+		fillCount := ringBuffer.Readable / frameBytes
+		frameLeft := min(frameCountMax, max(frameCountMin, fillCount))
 
+		for frameLeft > 0 {
+			frameCount := min(frameLeft, chunkFrames)
 			areas, err := stream.BeginWrite(&frameCount)
 			if err != nil {
-				log.Printf("begin write error: %s", err)
-				cancelParent()
+				cancel(fmt.Errorf("begin write error: %w", err))
 				return
 			}
-			if frameCount <= 0 {
-				return
-			}
-			zeroArray := make([]byte, 64)
-			for frame := 0; frame < frameCount; frame++ {
-				for ch := 0; ch < channelCount; ch++ {
-					buffer := areas.Buffer(ch, frame)
-					copy(buffer, zeroArray)
-				}
-			}
-			_ = stream.EndWrite()
-		}
-
-		readCount := fillCount
-		if readCount > frameCountMax {
-			readCount = frameCountMax
-		}
-
-		frameLeft := readCount
-		for {
-			frameCount := frameLeft
 			if frameCount <= 0 {
 				break
 			}
+			buffer := outBytes[:min(frameCount, ringBuffer.Readable/frameBytes)*frameBytes]
+			_, _ = ringBuffer.Read(buffer)
+			samples := outSamples[:frameCount*channels]
+			clear(samples[decodeFloat32(samples, buffer):])
+			areas.WriteInterleavedFloat32(samples)
 
-			areas, err := stream.BeginWrite(&frameCount)
-			if err != nil {
-				log.Printf("begin write error: %s", err)
-				cancelParent()
-				return
-			}
-			if frameCount <= 0 {
-				break
-			}
-			for frame := 0; frame < frameCount; frame++ {
-				for ch := 0; ch < channelCount; ch++ {
-					buffer := areas.Buffer(ch, frame)
-					_, err = ringBuffer.Read(buffer)
-					if err != nil {
-						//	log.Printf("ringbuffer read error: %s", err)
-					}
-				}
-			}
 			err = stream.EndWrite()
 			if err != nil {
-				log.Printf("end write error: %s", err)
-				cancelParent()
+				cancel(fmt.Errorf("end write error: %w", err))
 				return
 			}
-
 			frameLeft -= frameCount
 		}
 	})
@@ -376,51 +278,30 @@ func realMain(ctx context.Context, backend soundio.Backend, inputDeviceId string
 		log.Printf("underflow %d", underflowCount)
 	})
 
-	capacity := channels * int(0.2*float64(instream.SampleRate()*instream.BytesPerFrame()))
-	log.Printf("capacity %d", capacity)
-	ringBuffer = rbuf.NewFixedSizeRingBuf(capacity)
-	_, _ = ringBuffer.Write(make([]byte, capacity))
-
 	err = instream.Start()
 	if err != nil {
 		return fmt.Errorf("unable to start input device: %s", err)
 	}
-	err = outstream.Start()
-	if err != nil {
-		return fmt.Errorf("unable to start output device: %s", err)
-	}
 
 	log.Println("Type CTRL+C to quit by killing process...")
 
-	return s.WaitEvents(ctx)
+	return outstream.Run(ctx)
 }
 
-func signalContext(ctx context.Context) context.Context {
-	parent, cancelParent := context.WithCancel(ctx)
-	go func() {
-		defer cancelParent()
-
-		sig := make(chan os.Signal, 1)
-		signal.Notify(sig,
-			syscall.SIGHUP,
-			syscall.SIGINT,
-			syscall.SIGTERM,
-			syscall.SIGQUIT,
-		)
-		defer signal.Stop(sig)
-
-		select {
-		case <-parent.Done():
-			log.Println("Cancel from parent")
-			return
-		case s := <-sig:
-			switch s {
-			case syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
-				log.Println("Stop!")
-				return
-			}
-		}
-	}()
+// encodeFloat32 stores samples into dst as native endian bytes.
+func encodeFloat32(dst []byte, samples []float32) []byte {
+	dst = dst[:0]
+	for _, v := range samples {
+		dst = binary.NativeEndian.AppendUint32(dst, math.Float32bits(v))
+	}
+	return dst
+}
 
-	return parent
+// decodeFloat32 loads native endian bytes into samples, and returns the number of samples.
+func decodeFloat32(samples []float32, src []byte) int {
+	n := min(len(samples), len(src)/4)
+	for i := 0; i < n; i++ {
+		samples[i] = math.Float32frombits(binary.NativeEndian.Uint32(src[i*4:]))
+	}
+	return n
 }
@@ -0,0 +1,294 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	soundio "github.com/crow-misia/go-libsoundio"
+	"github.com/crow-misia/go-libsoundio/resample"
+	"github.com/crow-misia/go-libsoundio/wav"
+)
+
+// chunkFrames is the most frames converted at once.
+const chunkFrames = 1024
+
+var exitCode = 0
+
+func main() {
+	var (
+		deviceId string
+		backend  string
+		isRaw    bool
+		infile   string
+	)
+	flag.NewFlagSet("help", flag.ExitOnError)
+	flag.StringVar(&deviceId, "device", "", "id")
+	flag.StringVar(&backend, "backend", "", "dummy|alsa|pulseaudio|jack|coreaudio|wasapi")
+	flag.BoolVar(&isRaw, "raw", false, "raw")
+	flag.StringVar(&infile, "file", "", "wav filename")
+	flag.Parse()
+
+	enumBackend, err := parseBackend(backend)
+	if err != nil {
+		log.Println(err)
+		exitCode = 1
+	} else if len(infile) == 0 {
+		flag.PrintDefaults()
+		exitCode = 1
+	} else {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+		err := realMain(ctx, enumBackend, deviceId, isRaw, infile)
+		stop()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			exitCode = 1
+			log.Println(err)
+		}
+	}
+
+	os.Exit(exitCode)
+}
+
+func parseBackend(str string) (soundio.Backend, error) {
+	switch strings.ToLower(str) {
+	case "":
+		return soundio.BackendNone, nil
+	case "dummy":
+		return soundio.BackendDummy, nil
+	case "alsa":
+		return soundio.BackendAlsa, nil
+	case "pulseaudio":
+		return soundio.BackendPulseAudio, nil
+	case "jack":
+		return soundio.BackendJack, nil
+	case "coreaudio":
+		return soundio.BackendCoreAudio, nil
+	case "wasapi":
+		return soundio.BackendWasapi, nil
+	default:
+		return soundio.BackendNone, fmt.Errorf("invalid backend: %s", str)
+	}
+}
+
+func deviceMatcher(deviceId string, isRaw bool) soundio.DeviceMatcher {
+	if len(deviceId) == 0 {
+		return nil
+	}
+	return soundio.MatchAll(soundio.MatchID(deviceId), soundio.MatchRaw(isRaw))
+}
+
+func realMain(ctx context.Context, backend soundio.Backend, deviceId string, isRaw bool, infile string) error {
+	file, err := os.Open(infile)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %s", infile, err)
+	}
+	defer file.Close()
+
+	decoder, err := wav.NewDecoder(bufio.NewReader(file))
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %s", infile, err)
+	}
+	log.Printf("File: %s, %d Hz, %d channels", decoder.Format(), decoder.SampleRate(), decoder.ChannelCount())
+
+	s := soundio.Create(soundio.WithBackend(backend))
+	defer s.Close()
+
+	err = s.Connect()
+	if err != nil {
+		return err
+	}
+	s.FlushEvents()
+
+	selectedDevice, err := s.FindOutputDevice(deviceMatcher(deviceId, isRaw))
+	if err != nil {
+		return fmt.Errorf("no output device found: %w", err)
+	}
+	defer selectedDevice.RemoveReference()
+
+	log.Printf("Device: %s", selectedDevice.Name())
+
+	if selectedDevice.ProbeError() != nil {
+		return fmt.Errorf("unable to probe device: %s", selectedDevice.ProbeError())
+	}
+
+	config, plan, err := selectedDevice.NegotiateOut(&soundio.StreamPreferences{
+		AppFormat:     decoder.Format(),
+		AppSampleRate: decoder.SampleRate(),
+		AppLayout:     decoder.Layout(),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to negotiate stream: %s", err)
+	}
+	layout, sampleRate := config.Layout, config.SampleRate
+	log.Printf("Layout: %s", layout.Name())
+	log.Printf("Sample rate: %d", sampleRate)
+	log.Printf("Format: %s", config.Format)
+
+	// The writer converts formats, the converter remixes and resamples.
+	direct := !plan.Resample && !plan.Remix && decoder.Layout() != nil
+	writerFormat := soundio.FormatFloat32NE
+	if direct {
+		writerFormat = decoder.Format()
+	}
+
+	writer, err := soundio.NewPlaybackWriter(selectedDevice, &soundio.PlaybackConfig{
+		Stream: *config,
+		Format: writerFormat,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to open output device: %s", err)
+	}
+
+	log.Println("Type CTRL+C to stop playing...")
+
+	if direct {
+		err = play(ctx, writer, decoder)
+	} else {
+		var c *converter
+		c, err = newConverter(decoder, layout, sampleRate, plan)
+		if err == nil {
+			err = play(ctx, writer, c)
+		}
+	}
+	if closeErr := writer.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// play copies r to w until EOF or cancel.
+func play(ctx context.Context, w io.Writer, r io.Reader) error {
+	buffer := make([]byte, 16*1024)
+	for ctx.Err() == nil {
+		n, err := r.Read(buffer)
+		if n > 0 {
+			if _, err := w.Write(buffer[:n]); err != nil {
+				return fmt.Errorf("write error: %s", err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read error: %s", err)
+		}
+	}
+	return ctx.Err()
+}
+
+// converter remixes and resamples the decoded audio,
+// producing native endian float32 frames.
+type converter struct {
+	decoder   *wav.Decoder
+	remixer   *soundio.Remixer
+	resampler *resample.Resampler
+	channels  int
+	src       []float32
+	remixed   []float32
+	resampled []float32
+	pending   []float32
+	flushed   bool
+	buffer    []byte
+	out       []byte
+}
+
+func newConverter(decoder *wav.Decoder, layout *soundio.ChannelLayout, sampleRate int, plan *soundio.Plan) (*converter, error) {
+	channels := layout.ChannelCount()
+	c := &converter{
+		decoder:  decoder,
+		channels: channels,
+		src:      make([]float32, chunkFrames*decoder.ChannelCount()),
+	}
+	c.remixed = c.src
+	srcLayout := decoder.Layout()
+	if srcLayout == nil {
+		srcLayout = soundio.ChannelLayoutGetDefault(decoder.ChannelCount())
+	}
+	if plan.Remix {
+		if srcLayout == nil {
+			return nil, fmt.Errorf("unsupported channel count: %d", decoder.ChannelCount())
+		}
+		remixer, err := soundio.NewRemixer(srcLayout, layout)
+		if err != nil {
+			return nil, err
+		}
+		c.remixer = remixer
+		c.remixed = make([]float32, chunkFrames*channels)
+	}
+	c.resampled = c.remixed
+	if plan.Resample {
+		resampler, err := resample.New(channels, decoder.SampleRate(), sampleRate, resample.Medium)
+		if err != nil {
+			return nil, err
+		}
+		c.resampler = resampler
+		c.resampled = make([]float32, resampler.MaxOutput(chunkFrames)*channels)
+	}
+	c.buffer = make([]byte, len(c.resampled)*4)
+	return c, nil
+}
+
+func (c *converter) Read(p []byte) (int, error) {
+	for len(c.out) == 0 {
+		if len(c.pending) == 0 {
+			n, err := c.decoder.ReadFloat32(c.src)
+			if n == 0 {
+				if c.resampler == nil || c.flushed || !errors.Is(err, io.EOF) {
+					return 0, err
+				}
+				// silence pushes the last frames out of the resampler.
+				c.flushed = true
+				c.pending = make([]float32, c.resampler.Latency()*c.channels)
+			} else {
+				c.pending = c.remix(c.src[:n-n%c.decoder.ChannelCount()])
+			}
+		}
+		samples := c.pending
+		if c.resampler != nil {
+			consumed, produced := c.resampler.Process(c.resampled, c.pending)
+			c.pending = c.pending[consumed*c.channels:]
+			samples = c.resampled[:produced*c.channels]
+		} else {
+			c.pending = nil
+		}
+		c.out = encodeFloat32(c.buffer, samples)
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+// remix converts samples to the output layout.
+func (c *converter) remix(samples []float32) []float32 {
+	if c.remixer == nil {
+		return samples
+	}
+	produced := c.remixer.Process(c.remixed, samples)
+	return c.remixed[:produced*c.channels]
+}
+
+// encodeFloat32 stores samples as native endian bytes.
+func encodeFloat32(dst []byte, samples []float32) []byte {
+	dst = dst[:0]
+	for _, v := range samples {
+		dst = binary.NativeEndian.AppendUint32(dst, math.Float32bits(v))
+	}
+	return dst
+}
@@ -12,15 +12,15 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
-	"time"
 
 	soundio "github.com/crow-misia/go-libsoundio"
-	"github.com/glycerine/rbuf"
+	"github.com/crow-misia/go-libsoundio/wav"
 )
 
 const ringBufferDurationSeconds = 30
@@ -28,34 +28,6 @@ const ringBufferDurationSeconds = 30
 var overflowCount = 0
 var exitCode = 0
 
-var prioritizedFormats = []soundio.Format{
-	soundio.FormatFloat32NE,
-	soundio.FormatFloat32FE,
-	soundio.FormatS32NE,
-	soundio.FormatS32FE,
-	soundio.FormatS24NE,
-	soundio.FormatS24FE,
-	soundio.FormatS16NE,
-	soundio.FormatS16FE,
-	soundio.FormatFloat64NE,
-	soundio.FormatFloat64FE,
-	soundio.FormatU32NE,
-	soundio.FormatU32FE,
-	soundio.FormatU24NE,
-	soundio.FormatU24FE,
-	soundio.FormatU16NE,
-	soundio.FormatU16FE,
-	soundio.FormatS8,
-	soundio.FormatU8,
-}
-
-var prioritizedSampleRates = []int{
-	48000,
-	44100,
-	96000,
-	24000,
-}
-
 func main() {
 	var (
 		deviceId string
@@ -78,14 +50,13 @@ func main() {
 		flag.PrintDefaults()
 		exitCode = 1
 	} else {
-		ctx := context.Background()
-		parentCtx := signalContext(ctx)
-		err := realMain(parentCtx, enumBackend, deviceId, isRaw, outfile)
-		if err != nil {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+		err := realMain(ctx, enumBackend, deviceId, isRaw, outfile)
+		stop()
+		if err != nil && !errors.Is(err, context.Canceled) {
 			exitCode = 1
 			log.Println(err)
 		}
-		parentCtx.Done()
 	}
 
 	os.Exit(exitCode)
@@ -112,51 +83,25 @@ func parseBackend(str string) (soundio.Backend, error) {
 	}
 }
 
-func selectDevice(s *soundio.SoundIo, deviceId string, isRaw bool, getDeviceCount func(io *soundio.SoundIo) int, getDefaultIndex func(io *soundio.SoundIo) int, getDevice func(io *soundio.SoundIo, index int) *soundio.Device) (*soundio.Device, error) {
-	var selectedDevice *soundio.Device
-	if len(deviceId) > 0 {
-		count := getDeviceCount(s)
-		for i := 0; i < count; i++ {
-			device := getDevice(s, i)
-			if device.Raw() == isRaw && deviceId == device.ID() {
-				selectedDevice = device
-				break
-			}
-			device.RemoveReference()
-		}
-		if selectedDevice == nil {
-			return nil, fmt.Errorf("invalid device id: %s", deviceId)
-		}
-	} else {
-		deviceIndex := getDefaultIndex(s)
-		selectedDevice = getDevice(s, deviceIndex)
-		if selectedDevice == nil {
-			return nil, errors.New("no input devices available")
-		}
+func deviceMatcher(deviceId string, isRaw bool) soundio.DeviceMatcher {
+	if len(deviceId) == 0 {
+		return nil
 	}
-	return selectedDevice, nil
+	return soundio.MatchAll(soundio.MatchID(deviceId), soundio.MatchRaw(isRaw))
 }
 
 func realMain(ctx context.Context, backend soundio.Backend, deviceId string, isRaw bool, outfile string) error {
-	_, cancelParent := context.WithCancel(ctx)
-	defer cancelParent()
-
 	s := soundio.Create(soundio.WithBackend(backend))
+	defer s.Close()
 
 	err := s.Connect()
 	if err != nil {
 		return err
 	}
 
-	selectedDevice, err := selectDevice(s, deviceId, isRaw, func(io *soundio.SoundIo) int {
-		return io.InputDeviceCount()
-	}, func(io *soundio.SoundIo) int {
-		return io.DefaultInputDeviceIndex()
-	}, func(io *soundio.SoundIo, index int) *soundio.Device {
-		return io.InputDevice(index)
-	})
+	selectedDevice, err := s.FindInputDevice(deviceMatcher(deviceId, isRaw))
 	if err != nil {
-		return err
+		return fmt.Errorf("no input device found: %w", err)
 	}
 	defer selectedDevice.RemoveReference()
 
@@ -166,31 +111,13 @@ func realMain(ctx context.Context, backend soundio.Backend, deviceId string, isR
 		return fmt.Errorf("unable to probe device: %s", selectedDevice.ProbeError())
 	}
 
-	selectedDevice.SortChannelLayouts()
-
-	sampleRate := 0
-	for _, rate := range prioritizedSampleRates {
-		if selectedDevice.SupportsSampleRate(rate) {
-			sampleRate = rate
-			break
-		}
-	}
-	if sampleRate == 0 {
-		sampleRate = selectedDevice.SampleRates()[0].Max()
-	}
-	log.Printf("Sample rate: %d", sampleRate)
-
-	format := soundio.FormatInvalid
-	for _, f := range prioritizedFormats {
-		if selectedDevice.SupportsFormat(f) {
-			format = f
-			break
-		}
-	}
-	if format == soundio.FormatInvalid {
-		format = selectedDevice.Formats()[0]
+	config, _, err := selectedDevice.NegotiateIn(&soundio.StreamPreferences{})
+	if err != nil {
+		return fmt.Errorf("unable to negotiate stream: %s", err)
 	}
-	log.Printf("Format: %s", format)
+	log.Printf("Sample rate: %d", config.SampleRate)
+	log.Printf("Format: %s", config.Format)
+	log.Printf("Layout: %s", config.Layout.Name())
 
 	file, err := os.Create(outfile)
 	if err != nil {
@@ -198,126 +125,54 @@ func realMain(ctx context.Context, backend soundio.Backend, deviceId string, isR
 	}
 	defer file.Close()
 
-	var ringBuffer *rbuf.FixedSizeRingBuf
-
-	config := &soundio.InStreamConfig{
-		Format:     format,
-		SampleRate: sampleRate,
-	}
-	instream, err := selectedDevice.NewInStream(config)
+	reader, err := soundio.NewCaptureReader(selectedDevice, &soundio.CaptureConfig{
+		Stream:   *config,
+		Capacity: ringBufferDurationSeconds * config.SampleRate,
+	})
 	if err != nil {
 		return fmt.Errorf("unable to open input device: %s", err)
 	}
-	defer instream.Destroy()
-
-	frameBytes := instream.Layout().ChannelCount() * instream.BytesPerFrame()
-
-	instream.SetReadCallback(func(stream *soundio.InStream, frameCountMin int, frameCountMax int) {
-		freeBytes := ringBuffer.N - ringBuffer.Readable
-		freeCount := freeBytes / frameBytes
-		writeFrames := freeCount
-		if writeFrames > frameCountMax {
-			writeFrames = frameCountMax
-		}
-
-		channelCount := stream.Layout().ChannelCount()
-		frameLeft := writeFrames
-
-		for {
-			frameCount := frameLeft
-			if frameCount <= 0 {
-				break
-			}
+	defer reader.Close()
 
-			areas, err := stream.BeginRead(&frameCount)
-			if err != nil {
-				log.Printf("begin read error: %s", err)
-				cancelParent()
-				return
-			}
-			if frameCount <= 0 {
-				break
-			}
-			if areas == nil {
-				_, _ = ringBuffer.Write(make([]byte, frameCount*channelCount*stream.BytesPerFrame()))
-			} else {
-				for frame := 0; frame < frameCount; frame++ {
-					for ch := 0; ch < channelCount; ch++ {
-						buffer := areas.Buffer(ch, frame)
-						_, err = ringBuffer.Write(buffer)
-						if err != nil {
-							log.Printf("ringbuffer write error: %s, len %d", err, len(buffer))
-						}
-					}
-				}
-			}
-			err = stream.EndRead()
-			if err != nil {
-				log.Printf("end read error: %s", err)
-				cancelParent()
-				return
-			}
-
-			frameLeft -= frameCount
-		}
-	})
-	instream.SetOverflowCallback(func(stream *soundio.InStream) {
-		overflowCount++
-		log.Printf("overflow %d", overflowCount)
-	})
-
-	capacity := instream.Layout().ChannelCount() * ringBufferDurationSeconds * 5 * instream.SampleRate() * instream.BytesPerFrame()
-	ringBuffer = rbuf.NewFixedSizeRingBuf(capacity)
+	go func() {
+		<-ctx.Done()
+		_ = reader.Close()
+	}()
 
-	err = instream.Start()
+	encoder, err := wav.NewStreamEncoder(file, reader.Stream())
 	if err != nil {
-		return fmt.Errorf("unable to start input device: %s", err)
+		return fmt.Errorf("unable to write %s: %s", outfile, err)
 	}
 
 	log.Println("Type CTRL+C to quit by killing process...")
 
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			s.FlushEvents()
-			time.Sleep(1 * time.Second)
-			_, err := ringBuffer.WriteTo(file)
-
-			if err != nil {
-				return fmt.Errorf("write error: %s", err)
-			}
-		}
+	err = record(ctx, reader, encoder)
+	if closeErr := encoder.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("write error: %s", closeErr)
 	}
+	return err
 }
 
-func signalContext(ctx context.Context) context.Context {
-	parent, cancelParent := context.WithCancel(ctx)
-	go func() {
-		defer cancelParent()
-
-		sig := make(chan os.Signal, 1)
-		signal.Notify(sig,
-			syscall.SIGHUP,
-			syscall.SIGINT,
-			syscall.SIGTERM,
-			syscall.SIGQUIT,
-		)
-		defer signal.Stop(sig)
-
-		select {
-		case <-parent.Done():
-			log.Println("Cancel from parent")
-			return
-		case s := <-sig:
-			switch s {
-			case syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
-				log.Println("Stop!")
-				return
+func record(ctx context.Context, reader *soundio.CaptureReader, w io.Writer) error {
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := reader.Read(buffer)
+		if n > 0 {
+			if _, err := w.Write(buffer[:n]); err != nil {
+				return fmt.Errorf("write error: %s", err)
 			}
 		}
-	}()
 
-	return parent
+		var overflow *soundio.OverflowError
+		switch {
+		case err == nil:
+		case errors.As(err, &overflow):
+			overflowCount++
+			log.Printf("overflow %d: %s", overflowCount, overflow)
+		case errors.Is(err, io.EOF):
+			return ctx.Err()
+		default:
+			return fmt.Errorf("read error: %s", err)
+		}
+	}
 }
@@ -9,7 +9,7 @@ package main
 
 import (
 	"context"
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -25,21 +25,20 @@ var exitCode = 0
 var secondsOffset = 0.0
 
 func main() {
-	ctx := context.Background()
-	parentCtx := signalContext(ctx)
-	err := realMain(parentCtx)
-	if err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	err := realMain(ctx)
+	stop()
+	if err != nil && !errors.Is(err, context.Canceled) {
 		exitCode = 1
 		log.Println(err)
 	}
-	parentCtx.Done()
 
 	os.Exit(exitCode)
 }
 
 func realMain(ctx context.Context) error {
-	_, cancelParent := context.WithCancel(ctx)
-	defer cancelParent()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
 	backends := []soundio.Backend{
 		soundio.BackendJack, soundio.BackendPulseAudio, soundio.BackendAlsa,
@@ -54,6 +53,7 @@ func realMain(ctx context.Context) error {
 	log.Printf("Channel Layout Builtin Count = %d", soundio.ChannelLayoutBuiltinCount())
 
 	s := soundio.Create(soundio.WithAppName("FugaHoge"))
+	defer s.Close()
 
 	log.Printf("App Name = %s", s.AppName())
 
@@ -129,21 +129,20 @@ func realMain(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("error opening: %s", err)
 	}
-	defer outStream.Destroy()
+	defer outStream.Close()
 
 	outStream.SetWriteCallback(func(stream *soundio.OutStream, frameCountMix int, frameCountMax int) {
 		layout := stream.Layout()
 		sampleRate := float64(stream.SampleRate())
 		secondsPerFrame := 1.0 / sampleRate
-		var areas *soundio.ChannelAreas
 
 		framesLeft := frameCountMax
 		for framesLeft > 0 {
 			frameCount := framesLeft
-			areas, err = stream.BeginWrite(&frameCount)
+			areas, err := stream.BeginWrite(&frameCount)
 			if err != nil {
-				log.Println(err)
-				cancelParent()
+				cancel(err)
+				return
 			}
 
 			if frameCount <= 0 {
@@ -158,9 +157,7 @@ func realMain(ctx context.Context) error {
 				sample := float32(math.Sin((secondsOffset + float64(frame)*secondsPerFrame) * radiansPerSecond))
 
 				for channel := 0; channel < channelCount; channel++ {
-					buffer := areas.Buffer(channel, frame)
-					bites := math.Float32bits(sample)
-					binary.LittleEndian.PutUint32(buffer, bites)
+					areas.WriteFloat32(channel, frame, sample)
 				}
 			}
 
@@ -168,8 +165,8 @@ func realMain(ctx context.Context) error {
 
 			err = stream.EndWrite()
 			if err != nil {
-				log.Println(err)
-				cancelParent()
+				cancel(err)
+				return
 			}
 			framesLeft -= frameCount
 		}
@@ -186,40 +183,6 @@ func realMain(ctx context.Context) error {
 	log.Printf("    Volume = %f", outStream.Volume())
 	log.Printf("    NonTerminalHint = %t", outStream.NonTerminalHint())
 
-	err = outStream.Start()
-	if err != nil {
-		return fmt.Errorf("error opening: %s", err)
-	}
-
-	return s.WaitEvents(ctx)
-}
-
-func signalContext(ctx context.Context) context.Context {
-	parent, cancelParent := context.WithCancel(ctx)
-	go func() {
-		defer cancelParent()
-
-		sig := make(chan os.Signal, 1)
-		signal.Notify(sig,
-			syscall.SIGHUP,
-			syscall.SIGINT,
-			syscall.SIGTERM,
-			syscall.SIGQUIT,
-		)
-		defer signal.Stop(sig)
-
-		select {
-		case <-parent.Done():
-			log.Println("Cancel from parent")
-			return
-		case s := <-sig:
-			switch s {
-			case syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
-				log.Println("Stop!")
-				return
-			}
-		}
-	}()
-
-	return parent
+	log.Println("Type CTRL+C to quit by killing process...")
+	return outStream.Run(ctx)
 }
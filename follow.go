@@ -0,0 +1,769 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/crow-misia/go-libsoundio/resample"
+)
+
+// FollowOption is option function of streams that follow the default device.
+type FollowOption func(*followConfig)
+
+type followConfig struct {
+	crossfade time.Duration
+}
+
+// WithCrossfade sets the duration of the crossfade from the previous default
+// output device to the new one. Defaults to 50 milliseconds.
+func WithCrossfade(crossfade time.Duration) FollowOption {
+	return func(c *followConfig) {
+		c.crossfade = max(crossfade, 0)
+	}
+}
+
+// followedStream is a stream opened by a follower.
+type followedStream interface {
+	comparable
+	Start() error
+	Close() error
+}
+
+// retiringStream is a stream replaced by a stream on the new default device.
+type retiringStream[S followedStream] struct {
+	stream S
+	device *Device
+	until  time.Time
+}
+
+// follower keeps a stream opened on the default device.
+// Streams are opened and closed only by the goroutine calling run.
+type follower[S followedStream] struct {
+	io     *SoundIo
+	aim    DeviceAim
+	config followConfig
+	// open opens a stream on device, replacing prev, which is nil for the first stream.
+	open func(device *Device, prev S) (S, error)
+	// switched is called with mu held when next replaces prev, and returns
+	// how long prev keeps running.
+	switched func(prev S, next S) time.Duration
+	// abandoned is called with mu held when next failed to start, and the
+	// previous stream is in use again.
+	abandoned func(next S)
+
+	// active is the stream in use, read by the callbacks without locking.
+	active atomic.Pointer[S]
+
+	mu       sync.Mutex
+	stream   S
+	device   *Device
+	retiring []retiringStream[S]
+	failed   S
+	err      error
+	wake     context.CancelCauseFunc
+}
+
+func newFollower[S followedStream](s *SoundIo, aim DeviceAim, opts []FollowOption) follower[S] {
+	config := followConfig{
+		crossfade: 50 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return follower[S]{io: s, aim: aim, config: config}
+}
+
+// current returns the stream in use, and whether there is one.
+func (f *follower[S]) current() (S, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stream, f.device != nil
+}
+
+// isCurrent returns whether stream is the stream in use.
+func (f *follower[S]) isCurrent(stream S) bool {
+	active := f.active.Load()
+	return active != nil && *active == stream
+}
+
+// setStream replaces the stream in use. f.mu must be held.
+func (f *follower[S]) setStream(stream S, device *Device) {
+	f.stream, f.device = stream, device
+	f.active.Store(&stream)
+}
+
+func (f *follower[S]) run(ctx context.Context) error {
+	if _, ok := f.current(); ok {
+		return ErrorInvalid
+	}
+	if err := f.follow(); err != nil {
+		return err
+	}
+	defer f.closeAll()
+	remove := f.io.AddDevicesChangeListener(func() {
+		// the current stream keeps running when the new default device cannot be opened.
+		_ = f.follow()
+	})
+	defer remove()
+
+	for {
+		if err := f.wait(ctx); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		f.closeRetired(time.Now())
+		if err := f.recover(); err != nil {
+			return err
+		}
+	}
+}
+
+// wait waits events until ctx is done, a stream fails, or a retiring stream
+// is due to be closed.
+func (f *follower[S]) wait(ctx context.Context) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	f.mu.Lock()
+	if f.err != nil {
+		f.mu.Unlock()
+		return nil
+	}
+	f.wake = cancel
+	var until time.Time
+	for _, r := range f.retiring {
+		if until.IsZero() || r.until.Before(until) {
+			until = r.until
+		}
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.wake = nil
+		f.mu.Unlock()
+	}()
+
+	if !until.IsZero() {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithDeadline(ctx, until)
+		defer cancelDeadline()
+	}
+	if err := f.io.WaitEvents(ctx); errors.Is(err, ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+// follow opens a stream on the default device, unless the current stream
+// is already on it.
+func (f *follower[S]) follow() error {
+	find := f.io.FindOutputDevice
+	if f.aim == DeviceAimInput {
+		find = f.io.FindInputDevice
+	}
+	device, err := find(nil)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	prev, prevDevice := f.stream, f.device
+	f.mu.Unlock()
+	if prevDevice != nil && prevDevice.ID() == device.ID() && prevDevice.Raw() == device.Raw() {
+		_ = device.Close()
+		return nil
+	}
+
+	stream, err := f.open(device, prev)
+	if err != nil {
+		_ = device.Close()
+		return err
+	}
+	var linger time.Duration
+	f.mu.Lock()
+	f.setStream(stream, device)
+	if prevDevice != nil && f.switched != nil {
+		linger = f.switched(prev, stream)
+	}
+	f.mu.Unlock()
+
+	if err := stream.Start(); err != nil {
+		f.mu.Lock()
+		f.setStream(prev, prevDevice)
+		if f.abandoned != nil {
+			f.abandoned(stream)
+		}
+		f.mu.Unlock()
+		_ = stream.Close()
+		_ = device.Close()
+		return err
+	}
+	if prevDevice != nil {
+		f.mu.Lock()
+		f.retiring = append(f.retiring, retiringStream[S]{stream: prev, device: prevDevice, until: time.Now().Add(linger)})
+		f.mu.Unlock()
+	}
+	return nil
+}
+
+// fail records an error of the current stream, and wakes up run.
+// Errors of retiring streams are ignored, as their device may be gone.
+func (f *follower[S]) fail(stream S, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if stream != f.stream || f.err != nil {
+		return
+	}
+	f.failed, f.err = stream, err
+	if f.wake != nil {
+		f.wake(err)
+	}
+}
+
+// recover follows the default device after the current stream failed, as
+// the error may be reported before the default device changes. It returns
+// the error when the failed stream is still in use.
+func (f *follower[S]) recover() error {
+	f.mu.Lock()
+	err := f.err
+	f.mu.Unlock()
+	if err == nil {
+		return nil
+	}
+
+	f.io.FlushEvents()
+	_ = f.follow()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failed == f.stream {
+		return err
+	}
+	var zero S
+	f.failed, f.err = zero, nil
+	return nil
+}
+
+// closeRetired closes the retiring streams that are due at now.
+func (f *follower[S]) closeRetired(now time.Time) {
+	f.mu.Lock()
+	var due []retiringStream[S]
+	f.retiring = slices.DeleteFunc(f.retiring, func(r retiringStream[S]) bool {
+		if r.until.After(now) {
+			return false
+		}
+		due = append(due, r)
+		return true
+	})
+	f.mu.Unlock()
+	for _, r := range due {
+		_ = r.stream.Close()
+		_ = r.device.Close()
+	}
+}
+
+// closeAll closes every stream, and makes the follower ready to run again.
+func (f *follower[S]) closeAll() {
+	f.mu.Lock()
+	streams := f.retiring
+	if f.device != nil {
+		streams = append(streams, retiringStream[S]{stream: f.stream, device: f.device})
+	}
+	var zero S
+	f.setStream(zero, nil)
+	f.retiring = nil
+	f.failed, f.err = zero, nil
+	f.mu.Unlock()
+	for _, r := range streams {
+		_ = r.stream.Close()
+		_ = r.device.Close()
+	}
+}
+
+// FollowingOutStream is an output stream that moves to the default output
+// device when it changes. The new stream keeps the format, sample rate and
+// layout of the previous one when the new device supports them, and the audio
+// written to it is crossfaded from the previous device, resampled and remixed
+// when they differ, whose buffered audio is played out before it is closed.
+// The callbacks are called only for the stream in use.
+type FollowingOutStream struct {
+	follower[*OutStream]
+	streamConfig      OutStreamConfig
+	writeCallback     func(*OutStream, int, int)
+	underflowCallback func(*OutStream)
+	errorCallback     func(*OutStream, error)
+	fade              atomic.Pointer[crossfade]
+}
+
+// NewFollowingOutStream returns an output stream that follows the default
+// output device while Run is running.
+func (s *SoundIo) NewFollowingOutStream(config *OutStreamConfig, opts ...FollowOption) *FollowingOutStream {
+	stream := &FollowingOutStream{
+		follower:     newFollower[*OutStream](s, DeviceAimOutput, opts),
+		streamConfig: *config,
+	}
+	stream.open = stream.openStream
+	stream.switched = stream.startCrossfade
+	stream.abandoned = stream.dropCrossfade
+	return stream
+}
+
+// Stream returns the stream in use, or nil.
+func (s *FollowingOutStream) Stream() *OutStream {
+	stream, _ := s.current()
+	return stream
+}
+
+// SetWriteCallback sets WriteCallback.
+func (s *FollowingOutStream) SetWriteCallback(callback func(stream *OutStream, frameCountMin int, frameCountMax int)) {
+	s.writeCallback = callback
+}
+
+// SetUnderflowCallback sets UnderflowCallback.
+func (s *FollowingOutStream) SetUnderflowCallback(callback func(stream *OutStream)) {
+	s.underflowCallback = callback
+}
+
+// SetErrorCallback sets ErrorCallback.
+func (s *FollowingOutStream) SetErrorCallback(callback func(stream *OutStream, err error)) {
+	s.errorCallback = callback
+}
+
+// Run opens a stream on the default output device, and waits events of the
+// SoundIo until ctx is done, moving the stream when the default device changes.
+// An error of the stream in use makes Run return it, unless the default device
+// has changed. Streams are closed when Run returns.
+// FlushEvents and WaitEvents must not be called while running.
+//
+// Possible errors:
+// * ErrClosed
+// * ErrorInvalid - Run is already running
+// * errors of FindOutputDevice, NewOutStream and Start
+func (s *FollowingOutStream) Run(ctx context.Context) error {
+	defer s.fade.Store(nil)
+	return s.run(ctx)
+}
+
+func (s *FollowingOutStream) openStream(device *Device, prev *OutStream) (*OutStream, error) {
+	config := s.streamConfig
+	if prev != nil {
+		config.Format = keepFormat(device, prev.Format())
+		config.SampleRate = keepSampleRate(device, prev.SampleRate())
+		config.Layout = keepLayout(device, prev.Layout())
+	}
+	stream, err := device.NewOutStream(&config)
+	if err != nil {
+		return nil, err
+	}
+	stream.SetWriteCallback(s.streamWriteCallback)
+	stream.SetUnderflowCallback(s.streamUnderflowCallback)
+	stream.SetErrorCallback(s.streamErrorCallback)
+	stream.endWriteHook = func(areas *ChannelAreas) {
+		if fade := s.fade.Load(); fade != nil && fade.to == stream {
+			fade.fadeIn(areas)
+		}
+	}
+	return stream, nil
+}
+
+// startCrossfade starts passing the audio written to next to prev.
+func (s *FollowingOutStream) startCrossfade(prev *OutStream, next *OutStream) time.Duration {
+	s.fade.Store(newCrossfade(prev, next, s.config.crossfade))
+	return s.config.crossfade + time.Duration((prev.SoftwareLatency()+next.SoftwareLatency())*float64(time.Second))
+}
+
+// dropCrossfade stops the crossfade into next, which failed to start.
+func (s *FollowingOutStream) dropCrossfade(next *OutStream) {
+	if fade := s.fade.Load(); fade != nil && fade.to == next {
+		s.fade.Store(nil)
+	}
+}
+
+func (s *FollowingOutStream) streamWriteCallback(stream *OutStream, frameCountMin int, frameCountMax int) {
+	if s.isCurrent(stream) {
+		if s.writeCallback != nil {
+			s.writeCallback(stream, frameCountMin, frameCountMax)
+		}
+		return
+	}
+	fade := s.fade.Load()
+	if fade != nil && fade.from != stream {
+		// a stream replaced before the last crossfade plays silence.
+		fade = nil
+	}
+	writeTail(stream, fade, frameCountMax)
+}
+
+func (s *FollowingOutStream) streamUnderflowCallback(stream *OutStream) {
+	if s.underflowCallback != nil && s.isCurrent(stream) {
+		s.underflowCallback(stream)
+	}
+}
+
+func (s *FollowingOutStream) streamErrorCallback(stream *OutStream, err error) {
+	if s.errorCallback != nil && s.isCurrent(stream) {
+		s.errorCallback(stream, err)
+	}
+	s.fail(stream, err)
+}
+
+// tailFrames is the most frames a replaced stream is written at once, so
+// that the buffers of a crossfade are allocated before the callbacks use them.
+const tailFrames = 1024
+
+// silentTail is written to a stream replaced before the last crossfade.
+var silentTail [tailFrames * MaxChannels]float32
+
+// writeTail writes the fading out audio of fade, or silence when fade is nil,
+// to a stream that has been replaced.
+func writeTail(stream *OutStream, fade *crossfade, frameCountMax int) {
+	channelCount := stream.Layout().ChannelCount()
+	frameLeft := frameCountMax
+	for frameLeft > 0 {
+		frameCount := min(frameLeft, tailFrames)
+		areas, err := stream.BeginWrite(&frameCount)
+		if err != nil || frameCount <= 0 {
+			return
+		}
+		if areas != nil {
+			if fade != nil {
+				areas.WriteInterleavedFloat32(fade.fadeOut(frameCount))
+			} else {
+				areas.WriteInterleavedFloat32(silentTail[:frameCount*channelCount])
+			}
+		}
+		if err := stream.EndWrite(); err != nil {
+			return
+		}
+		frameLeft -= frameCount
+	}
+}
+
+// crossfade passes the audio written to a new stream to the stream it
+// replaces, fading one in while the other fades out.
+// Fields used by the callbacks of each stream are not shared.
+type crossfade struct {
+	from *OutStream
+	to   *OutStream
+	// frames is the duration in frames of to, and outFrames in frames of from.
+	frames    int
+	outFrames int
+	// queue passes interleaved float32 samples in the layout and sample rate
+	// of to, and is nil when they cannot be converted for from.
+	queue *ringBuffer
+	// resampler converts into the sample rate of from, or is nil.
+	resampler *resample.Resampler
+	// remix converts from the layout of to into the layout of from, or is nil.
+	remix        *Remixer
+	toChannels   int
+	fromChannels int
+
+	// used by the callback of to
+	faded  int
+	buffer []float32
+
+	// used by the callback of from
+	fadedOut  int
+	popped    []float32
+	pending   []float32
+	resampled []float32
+	tail      []float32
+}
+
+func newCrossfade(from *OutStream, to *OutStream, duration time.Duration) *crossfade {
+	c := newCrossfadeBuffers(to.Layout(), to.SampleRate(), from.Layout(), from.SampleRate(), duration)
+	c.from, c.to = from, to
+	return c
+}
+
+// newCrossfadeBuffers returns a crossfade from audio in layout toLayout at
+// toRate into fromLayout at fromRate, with its buffers allocated.
+func newCrossfadeBuffers(toLayout *ChannelLayout, toRate int, fromLayout *ChannelLayout, fromRate int, duration time.Duration) *crossfade {
+	c := &crossfade{
+		frames:       int(duration.Seconds() * float64(toRate)),
+		outFrames:    int(duration.Seconds() * float64(fromRate)),
+		toChannels:   toLayout.ChannelCount(),
+		fromChannels: fromLayout.ChannelCount(),
+	}
+	c.buffer = make([]float32, c.frames*c.toChannels)
+	c.tail = make([]float32, tailFrames*c.fromChannels)
+	if c.frames == 0 || c.toChannels == 0 {
+		return c
+	}
+	if fromRate != toRate {
+		resampler, err := resample.New(c.toChannels, toRate, fromRate, resample.Medium)
+		if err != nil {
+			return c
+		}
+		c.resampler = resampler
+		c.popped = make([]float32, tailFrames*c.toChannels)
+	}
+	if !fromLayout.Equal(toLayout) {
+		remix, err := NewRemixer(toLayout, fromLayout)
+		if err != nil {
+			return c
+		}
+		c.remix = remix
+	}
+	c.queue = newRingBuffer(len(c.buffer) * 4)
+	c.resampled = make([]float32, tailFrames*c.toChannels)
+	return c
+}
+
+// fadeIn passes the samples written to areas to the previous stream, and
+// fades them in.
+func (c *crossfade) fadeIn(areas *ChannelAreas) {
+	if c.faded >= c.frames {
+		return
+	}
+	frames := min(areas.FrameCount(), c.frames-c.faded)
+	samples := c.buffer[:frames*areas.ChannelCount()]
+	frames = areas.ReadInterleavedFloat32(samples)
+	c.tee(samples[:frames*areas.ChannelCount()], areas.ChannelCount())
+	areas.WriteInterleavedFloat32(samples[:frames*areas.ChannelCount()])
+}
+
+// tee passes interleaved samples to the previous stream, and fades them in.
+func (c *crossfade) tee(samples []float32, channels int) {
+	if c.queue != nil {
+		frames := min(len(samples)/channels, c.queue.Writable()/(4*channels))
+		c.queue.Write(float32Bytes(samples[:frames*channels]))
+	}
+	for i := range samples {
+		samples[i] *= fadeInGain(c.faded+i/channels, c.frames)
+	}
+	c.faded += len(samples) / channels
+}
+
+// fadeOut returns frames of interleaved samples for the previous stream,
+// fading out the samples passed by tee, followed by silence. frames must not
+// be greater than tailFrames.
+func (c *crossfade) fadeOut(frames int) []float32 {
+	out := c.tail[:frames*c.fromChannels]
+	clear(out)
+	if c.queue == nil || c.fadedOut >= c.outFrames {
+		return out
+	}
+	src := c.pull(frames)
+	n := len(src) / c.toChannels
+	if c.remix != nil {
+		c.remix.Process(out, src)
+	} else {
+		copy(out, src)
+	}
+	for i := range out[:n*c.fromChannels] {
+		out[i] *= fadeOutGain(c.fadedOut+i/c.fromChannels, c.outFrames)
+	}
+	c.fadedOut += n
+	return out
+}
+
+// pull returns at most frames frames passed by tee, converted to the sample
+// rate of from.
+func (c *crossfade) pull(frames int) []float32 {
+	dst := c.resampled[:frames*c.toChannels]
+	if c.resampler == nil {
+		return dst[:c.read(dst)]
+	}
+	produced := 0
+	for produced < frames {
+		if len(c.pending) == 0 {
+			c.pending = c.popped[:c.read(c.popped)]
+			if len(c.pending) == 0 {
+				break
+			}
+		}
+		consumed, n := c.resampler.Process(dst[produced*c.toChannels:], c.pending)
+		if consumed == 0 && n == 0 {
+			break
+		}
+		c.pending = c.pending[consumed*c.toChannels:]
+		produced += n
+	}
+	return dst[:produced*c.toChannels]
+}
+
+// read removes whole frames passed by tee into dst, and returns the number
+// of samples removed.
+func (c *crossfade) read(dst []float32) int {
+	frames := min(len(dst)/c.toChannels, c.queue.Readable()/(4*c.toChannels))
+	return c.queue.Read(float32Bytes(dst[:frames*c.toChannels])) / 4
+}
+
+// fadeInGain returns the gain of frame of an equal power fade in over frames.
+func fadeInGain(frame int, frames int) float32 {
+	if frame >= frames {
+		return 1
+	}
+	return float32(math.Sin(float64(frame) / float64(frames) * math.Pi / 2))
+}
+
+// fadeOutGain returns the gain of frame of an equal power fade out over frames.
+func fadeOutGain(frame int, frames int) float32 {
+	if frame >= frames {
+		return 0
+	}
+	return float32(math.Cos(float64(frame) / float64(frames) * math.Pi / 2))
+}
+
+// float32Bytes returns the memory of samples as bytes.
+func float32Bytes(samples []float32) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(samples))), len(samples)*4)
+}
+
+// FollowingInStream is an input stream that moves to the default input device
+// when it changes. The new stream keeps the format, sample rate and layout of
+// the previous one when the new device supports them. The read callback
+// receives the audio of the new stream from its first callback, without a
+// crossfade, and the previous stream is closed.
+// The callbacks are called only for the stream in use.
+type FollowingInStream struct {
+	follower[*InStream]
+	streamConfig     InStreamConfig
+	readCallback     func(*InStream, int, int)
+	overflowCallback func(*InStream)
+	errorCallback    func(*InStream, error)
+}
+
+// NewFollowingInStream returns an input stream that follows the default
+// input device while Run is running.
+func (s *SoundIo) NewFollowingInStream(config *InStreamConfig, opts ...FollowOption) *FollowingInStream {
+	stream := &FollowingInStream{
+		follower:     newFollower[*InStream](s, DeviceAimInput, opts),
+		streamConfig: *config,
+	}
+	stream.open = stream.openStream
+	return stream
+}
+
+// Stream returns the stream in use, or nil.
+func (s *FollowingInStream) Stream() *InStream {
+	stream, _ := s.current()
+	return stream
+}
+
+// SetReadCallback sets ReadCallback.
+func (s *FollowingInStream) SetReadCallback(callback func(stream *InStream, frameCountMin int, frameCountMax int)) {
+	s.readCallback = callback
+}
+
+// SetOverflowCallback sets OverflowCallback.
+func (s *FollowingInStream) SetOverflowCallback(callback func(stream *InStream)) {
+	s.overflowCallback = callback
+}
+
+// SetErrorCallback sets ErrorCallback.
+func (s *FollowingInStream) SetErrorCallback(callback func(stream *InStream, err error)) {
+	s.errorCallback = callback
+}
+
+// Run opens a stream on the default input device, and waits events of the
+// SoundIo until ctx is done, moving the stream when the default device changes.
+// An error of the stream in use makes Run return it, unless the default device
+// has changed. Streams are closed when Run returns.
+// FlushEvents and WaitEvents must not be called while running.
+//
+// Possible errors:
+// * ErrClosed
+// * ErrorInvalid - Run is already running
+// * errors of FindInputDevice, NewInStream and Start
+func (s *FollowingInStream) Run(ctx context.Context) error {
+	return s.run(ctx)
+}
+
+func (s *FollowingInStream) openStream(device *Device, prev *InStream) (*InStream, error) {
+	config := s.streamConfig
+	if prev != nil {
+		config.Format = keepFormat(device, prev.Format())
+		config.SampleRate = keepSampleRate(device, prev.SampleRate())
+		config.Layout = keepLayout(device, prev.Layout())
+	}
+	stream, err := device.NewInStream(&config)
+	if err != nil {
+		return nil, err
+	}
+	stream.SetReadCallback(s.streamReadCallback)
+	stream.SetOverflowCallback(s.streamOverflowCallback)
+	stream.SetErrorCallback(s.streamErrorCallback)
+	return stream, nil
+}
+
+func (s *FollowingInStream) streamReadCallback(stream *InStream, frameCountMin int, frameCountMax int) {
+	if !s.isCurrent(stream) {
+		discardFrames(stream, frameCountMax)
+		return
+	}
+	if s.readCallback != nil {
+		s.readCallback(stream, frameCountMin, frameCountMax)
+	}
+}
+
+func (s *FollowingInStream) streamOverflowCallback(stream *InStream) {
+	if s.overflowCallback != nil && s.isCurrent(stream) {
+		s.overflowCallback(stream)
+	}
+}
+
+func (s *FollowingInStream) streamErrorCallback(stream *InStream, err error) {
+	if s.errorCallback != nil && s.isCurrent(stream) {
+		s.errorCallback(stream, err)
+	}
+	s.fail(stream, err)
+}
+
+// discardFrames reads and drops the frames captured by a stream that has been
+// replaced. Errors are ignored, as the stream is about to be closed.
+func discardFrames(stream *InStream, frameCountMax int) {
+	frameLeft := frameCountMax
+	for frameLeft > 0 {
+		frameCount := frameLeft
+		if _, err := stream.BeginRead(&frameCount); err != nil || frameCount <= 0 {
+			return
+		}
+		if err := stream.EndRead(); err != nil {
+			return
+		}
+		frameLeft -= frameCount
+	}
+}
+
+// keepFormat returns format when device supports it, and otherwise the default.
+func keepFormat(device *Device, format Format) Format {
+	if device.SupportsFormat(format) {
+		return format
+	}
+	return FormatInvalid
+}
+
+// keepSampleRate returns sampleRate when device supports it, and otherwise the default.
+func keepSampleRate(device *Device, sampleRate int) int {
+	if device.SupportsSampleRate(sampleRate) {
+		return sampleRate
+	}
+	return 0
+}
+
+// keepLayout returns layout when device supports it, and otherwise the default.
+func keepLayout(device *Device, layout *ChannelLayout) *ChannelLayout {
+	if device.SupportsLayout(layout) {
+		return layout
+	}
+	return nil
+}
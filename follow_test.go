@@ -0,0 +1,266 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCrossfade(t *testing.T) {
+	stereo := ChannelLayoutGetDefault(2)
+	c := newCrossfadeBuffers(stereo, 1000, stereo, 1000, 4*time.Millisecond)
+
+	samples := []float32{1, 1, 1, 1, 1, 1, 1, 1}
+	c.tee(samples[:4], 2)
+	for frame := 0; frame < 2; frame++ {
+		want := float32(math.Sin(float64(frame) / 4 * math.Pi / 2))
+		if samples[frame*2] != want || samples[frame*2+1] != want {
+			t.Errorf("faded in frame %d = %v, want %v", frame, samples[frame*2:frame*2+2], want)
+		}
+	}
+
+	// only the frames passed by tee are faded out, and the rest wait for it.
+	out := c.fadeOut(4)
+	if !slices.Equal(out[4:], []float32{0, 0, 0, 0}) || c.fadedOut != 2 {
+		t.Errorf("fadeOut() before tee = %v, faded out %d frames, want 2", out, c.fadedOut)
+	}
+	c.tee(samples[4:], 2)
+	out = c.fadeOut(4)
+	for frame := 0; frame < 4; frame++ {
+		want := float32(0)
+		if frame < 2 {
+			want = float32(math.Cos(float64(frame+2) / 4 * math.Pi / 2))
+		}
+		if out[frame*2] != want || out[frame*2+1] != want {
+			t.Errorf("faded out frame %d = %v, want %v", frame, out[frame*2:frame*2+2], want)
+		}
+	}
+	if out := c.fadeOut(2); slices.ContainsFunc(out, func(s float32) bool { return s != 0 }) {
+		t.Errorf("after crossfade = %v, want silence", out)
+	}
+}
+
+func TestCrossfadeResample(t *testing.T) {
+	c := newCrossfadeBuffers(ChannelLayoutGetDefault(1), 2000, ChannelLayoutGetDefault(2), 1000, 100*time.Millisecond)
+	if c.queue == nil || c.resampler == nil || c.remix == nil {
+		t.Fatal("crossfade between sample rates and layouts is not converted")
+	}
+
+	samples := make([]float32, c.frames)
+	for i := range samples {
+		samples[i] = 0.5
+	}
+	c.tee(samples, 1)
+
+	var peak float32
+	for c.fadedOut < c.outFrames {
+		fadedOut := c.fadedOut
+		out := c.fadeOut(16)
+		if c.fadedOut == fadedOut {
+			break
+		}
+		for _, sample := range out {
+			peak = max(peak, sample)
+		}
+	}
+	if c.fadedOut < c.outFrames-c.resampler.Latency() {
+		t.Errorf("faded out %d frames, want %d", c.fadedOut, c.outFrames)
+	}
+	if peak <= 0.25 || peak > 0.6 {
+		t.Errorf("peak of the resampled fade out = %v", peak)
+	}
+}
+
+func TestFollowingOutStreamRun(t *testing.T) {
+	s := newDummySoundIo(t)
+	following := s.NewFollowingOutStream(&OutStreamConfig{})
+
+	errStop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	following.SetWriteCallback(func(stream *OutStream, frameCountMin int, frameCountMax int) {
+		cancel(errStop)
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- following.Run(ctx)
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errStop) {
+			t.Errorf("Run() = %v, want %v", err, errStop)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	if stream := following.Stream(); stream != nil {
+		t.Errorf("Stream() after Run = %v, want nil", stream)
+	}
+}
+
+// followFromOtherDevice starts a stream of following as if it had been opened
+// on another output device, which the dummy backend has only one of, so that
+// follow moves it. The input device stands in for the other device.
+func followFromOtherDevice(t *testing.T, s *SoundIo, following *FollowingOutStream) *OutStream {
+	t.Helper()
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	t.Cleanup(func() { _ = device.Close() })
+	prev, err := following.openStream(device, nil)
+	if err != nil {
+		t.Fatalf("unable to open output stream: %s", err)
+	}
+	following.mu.Lock()
+	following.setStream(prev, s.InputDevice(s.DefaultInputDeviceIndex()))
+	following.mu.Unlock()
+	return prev
+}
+
+// writeFrames writes frameCountMax frames of a constant level.
+func writeFrames(stream *OutStream, frameCountMin int, frameCountMax int) {
+	for frameLeft := frameCountMax; frameLeft > 0; {
+		frameCount := frameLeft
+		areas, err := stream.BeginWrite(&frameCount)
+		if err != nil || frameCount <= 0 {
+			return
+		}
+		for frame := 0; frame < frameCount; frame++ {
+			for ch := 0; ch < areas.ChannelCount(); ch++ {
+				areas.WriteFloat32(ch, frame, 0.5)
+			}
+		}
+		if err := stream.EndWrite(); err != nil {
+			return
+		}
+		frameLeft -= frameCount
+	}
+}
+
+func TestFollowingOutStreamFollow(t *testing.T) {
+	s := newDummySoundIo(t)
+	following := s.NewFollowingOutStream(&OutStreamConfig{})
+	defer following.closeAll()
+	following.SetWriteCallback(writeFrames)
+
+	// the crossfade is followed through the end write hooks, which are
+	// called by the callback of each stream.
+	var fadedIn, fadedOut atomic.Int64
+	open := following.open
+	following.open = func(device *Device, prev *OutStream) (*OutStream, error) {
+		stream, err := open(device, prev)
+		if err != nil {
+			return nil, err
+		}
+		hook := stream.endWriteHook
+		stream.endWriteHook = func(areas *ChannelAreas) {
+			hook(areas)
+			if fade := following.fade.Load(); fade != nil && fade.to == stream {
+				fadedIn.Store(int64(fade.faded))
+			}
+		}
+		return stream, nil
+	}
+	prev := followFromOtherDevice(t, s, following)
+	prev.endWriteHook = func(areas *ChannelAreas) {
+		if fade := following.fade.Load(); fade != nil && fade.from == prev {
+			fadedOut.Store(int64(fade.fadedOut))
+		}
+	}
+	if err := prev.Start(); err != nil {
+		t.Fatalf("unable to start output stream: %s", err)
+	}
+
+	if err := following.follow(); err != nil {
+		t.Fatalf("follow() = %v", err)
+	}
+	next := following.Stream()
+	if next == nil || next == prev {
+		t.Fatalf("Stream() after follow = %v, want a new stream", next)
+	}
+	if fade := following.fade.Load(); fade == nil || fade.from != prev || fade.to != next {
+		t.Fatal("no crossfade from the previous stream to the new one")
+	}
+	following.mu.Lock()
+	retiring := slices.Clone(following.retiring)
+	following.mu.Unlock()
+	if len(retiring) != 1 || retiring[0].stream != prev {
+		t.Fatalf("retiring = %+v, want the previous stream", retiring)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); fadedIn.Load() == 0 || fadedOut.Load() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("faded in %d frames and out %d frames", fadedIn.Load(), fadedOut.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the previous stream lingers until the crossfade has been played
+	until := retiring[0].until
+	following.closeRetired(until.Add(-time.Millisecond))
+	if prev.cptr() == nil {
+		t.Error("previous stream was closed before its linger time")
+	}
+	following.closeRetired(until)
+	if prev.cptr() != nil {
+		t.Error("previous stream was not closed after its linger time")
+	}
+	following.mu.Lock()
+	defer following.mu.Unlock()
+	if len(following.retiring) != 0 {
+		t.Errorf("retiring after closing = %+v, want none", following.retiring)
+	}
+}
+
+func TestFollowingOutStreamFollowStartError(t *testing.T) {
+	s := newDummySoundIo(t)
+	following := s.NewFollowingOutStream(&OutStreamConfig{})
+	defer following.closeAll()
+
+	// a stream closed before it is started fails to start
+	var failed *OutStream
+	open := following.open
+	following.open = func(device *Device, prev *OutStream) (*OutStream, error) {
+		stream, err := open(device, prev)
+		if err != nil {
+			return nil, err
+		}
+		failed = stream
+		_ = stream.Close()
+		return stream, nil
+	}
+	prev := followFromOtherDevice(t, s, following)
+
+	if err := following.follow(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("follow() = %v, want %v", err, ErrClosed)
+	}
+	if failed == nil {
+		t.Fatal("follow did not open a stream")
+	}
+	if stream := following.Stream(); stream != prev {
+		t.Errorf("Stream() after a failed start = %v, want the previous stream", stream)
+	}
+	if !following.isCurrent(prev) {
+		t.Error("the previous stream is not in use after a failed start")
+	}
+	if following.fade.Load() != nil {
+		t.Error("the crossfade into the failed stream was kept")
+	}
+	following.mu.Lock()
+	defer following.mu.Unlock()
+	if len(following.retiring) != 0 {
+		t.Errorf("retiring after a failed start = %+v, want none", following.retiring)
+	}
+}
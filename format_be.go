@@ -0,0 +1,30 @@
+//go:build mips || mips64 || ppc64 || s390x
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+// Format enumeration in the byte order of big endian architectures.
+const (
+	FormatS16NE     = FormatS16BE     // Signed 16 bit Native Endian
+	FormatS16FE     = FormatS16LE     // Signed 16 bit Foreign Endian
+	FormatU16NE     = FormatU16BE     // Unsigned 16 bit Native Endian
+	FormatU16FE     = FormatU16LE     // Unsigned 16 bit Foreign Endian
+	FormatS24NE     = FormatS24BE     // Signed 24 bit Native Endian using low three bytes in 32-bit word
+	FormatS24FE     = FormatS24LE     // Signed 24 bit Foreign Endian using low three bytes in 32-bit word
+	FormatU24NE     = FormatU24BE     // Unsigned 24 bit Native Endian using low three bytes in 32-bit word
+	FormatU24FE     = FormatU24LE     // Unsigned 24 bit Foreign Endian using low three bytes in 32-bit word
+	FormatS32NE     = FormatS32BE     // Signed 32 bit Native Endian
+	FormatS32FE     = FormatS32LE     // Signed 32 bit Foreign Endian
+	FormatU32NE     = FormatU32BE     // Unsigned 32 bit Native Endian
+	FormatU32FE     = FormatU32LE     // Unsigned 32 bit Foreign Endian
+	FormatFloat32NE = FormatFloat32BE // Float 32 bit Native Endian, Range -1.0 to 1.0
+	FormatFloat32FE = FormatFloat32LE // Float 32 bit Foreign Endian, Range -1.0 to 1.0
+	FormatFloat64NE = FormatFloat64BE // Float 64 bit Native Endian, Range -1.0 to 1.0
+	FormatFloat64FE = FormatFloat64LE // Float 64 bit Foreign Endian, Range -1.0 to 1.0
+)
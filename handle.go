@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+/*
+#include <stdint.h>
+*/
+import "C"
+import (
+	"runtime/cgo"
+	"unsafe"
+)
+
+// Native userdata fields hold a cgo.Handle instead of a Go pointer,
+// so that C memory never refers to Go memory.
+
+// newHandle registers v and returns the value to store in a native userdata field.
+func newHandle(v any) (cgo.Handle, C.uintptr_t) {
+	h := cgo.NewHandle(v)
+	return h, C.uintptr_t(h)
+}
+
+// handleValue returns the value registered for a native userdata field.
+// It returns false for a userdata field that was never set.
+func handleValue[T any](userdata unsafe.Pointer) (T, bool) {
+	var zero T
+	h := cgo.Handle(uintptr(userdata))
+	if h == 0 {
+		return zero, false
+	}
+	v, ok := h.Value().(T)
+	return v, ok
+}
+
+// deleteHandle releases h, once the native object no longer calls back into Go.
+func deleteHandle(h *cgo.Handle) {
+	if *h != 0 {
+		h.Delete()
+		*h = 0
+	}
+}
@@ -14,53 +14,59 @@ package soundio
 */
 import "C"
 import (
+	"context"
+	"runtime/cgo"
+	"sync/atomic"
 	"unsafe"
+	"weak"
 )
 
 // InStream is Input Stream.
 type InStream struct {
 	p                uintptr
+	handle           cgo.Handle
 	d                *Device
+	key              int
 	readCallback     func(*InStream, int, int)
 	overflowCallback func(*InStream)
 	errorCallback    func(*InStream, error)
-}
-
-// InStreamConfig is config of input stream.
-type InStreamConfig struct {
-	// Format
-	Format Format
-	// SampleRate
-	SampleRate int
-	// Layout
-	Layout *ChannelLayout
-	// SoftwareLatency
-	SoftwareLatency float64
-	// Name
-	Name string
+	stopRun          atomic.Pointer[context.CancelCauseFunc]
+	layout           ChannelLayout
+	areas            ChannelAreas
+	// out parameters of begin read live in the stream, so that they do not escape to the heap.
+	nativeAreas      *C.struct_SoundIoChannelArea
+	nativeFrameCount C.int
+	// reading is whether areas are in use between BeginRead and EndRead.
+	reading atomic.Bool
 }
 
 //export instreamReadCallbackDelegate
 func instreamReadCallbackDelegate(nativeStream *C.struct_SoundIoInStream, frameCountMin C.int, frameCountMax C.int) {
-	stream := (*InStream)(nativeStream.userdata)
-	if stream.readCallback != nil {
+	stream, ok := handleValue[*InStream](nativeStream.userdata)
+	if ok && stream.readCallback != nil {
 		stream.readCallback(stream, int(frameCountMin), int(frameCountMax))
 	}
 }
 
 //export instreamOverflowCallbackDelegate
 func instreamOverflowCallbackDelegate(nativeStream *C.struct_SoundIoInStream) {
-	stream := (*InStream)(nativeStream.userdata)
-	if stream.overflowCallback != nil {
+	stream, ok := handleValue[*InStream](nativeStream.userdata)
+	if ok && stream.overflowCallback != nil {
 		stream.overflowCallback(stream)
 	}
 }
 
 //export instreamErrorCallbackDelegate
 func instreamErrorCallbackDelegate(nativeStream *C.struct_SoundIoInStream, err C.int) {
-	stream := (*InStream)(nativeStream.userdata)
+	stream, ok := handleValue[*InStream](nativeStream.userdata)
+	if !ok {
+		return
+	}
 	if stream.errorCallback != nil {
-		stream.errorCallback(stream, convertToError(err))
+		stream.errorCallback(stream, stream.opError("stream", convertToError(err)))
+	}
+	if stop := stream.stopRun.Load(); stop != nil {
+		(*stop)(stream.opError("stream", convertToError(err)))
 	}
 }
 
@@ -74,30 +80,41 @@ func (s *InStream) Device() *Device {
 // Format returns format of stream.
 func (s *InStream) Format() Format {
 	p := s.cptr()
+	if p == nil {
+		return FormatInvalid
+	}
 	return Format(p.format)
 }
 
 // SampleRate returns sample rate of stream.
 func (s *InStream) SampleRate() int {
 	p := s.cptr()
+	if p == nil {
+		return 0
+	}
 	return int(p.sample_rate)
 }
 
 // Layout returns layout of stream.
 func (s *InStream) Layout() *ChannelLayout {
-	p := s.cptr()
-	return newChannelLayout(&p.layout)
+	return &s.layout
 }
 
 // SoftwareLatency returns software latency of stream.
 func (s *InStream) SoftwareLatency() float64 {
 	p := s.cptr()
+	if p == nil {
+		return 0
+	}
 	return float64(p.software_latency)
 }
 
 // Name returns name of stream.
 func (s *InStream) Name() string {
 	p := s.cptr()
+	if p == nil {
+		return ""
+	}
 	return C.GoString(p.name)
 }
 
@@ -106,18 +123,27 @@ func (s *InStream) Name() string {
 // passed on or made available to another stream. Defaults to `false`.
 func (s *InStream) NonTerminalHint() bool {
 	p := s.cptr()
+	if p == nil {
+		return false
+	}
 	return bool(p.non_terminal_hint)
 }
 
 // BytesPerFrame returns bytes per frame.
 func (s *InStream) BytesPerFrame() int {
 	p := s.cptr()
+	if p == nil {
+		return 0
+	}
 	return int(p.bytes_per_frame)
 }
 
 // BytesPerSample returns bytes per sample.
 func (s *InStream) BytesPerSample() int {
 	p := s.cptr()
+	if p == nil {
+		return 0
+	}
 	return int(p.bytes_per_sample)
 }
 
@@ -126,6 +152,9 @@ func (s *InStream) BytesPerSample() int {
 // * SoundIoErrorIncompatibleDevice
 func (s *InStream) LayoutError() error {
 	p := s.cptr()
+	if p == nil {
+		return ErrClosed
+	}
 	return convertToError(p.layout_error)
 }
 
@@ -146,50 +175,100 @@ func (s *InStream) SetErrorCallback(callback func(stream *InStream, err error))
 
 // functions
 
+// Close stops the stream and releases resources.
+// It must not be called from the stream callbacks.
+// Calling Close more than once does nothing, and other methods return
+// ErrClosed or zero values after Close.
+func (s *InStream) Close() error {
+	p := s.cptr()
+	if p == nil {
+		return nil
+	}
+	C.free(unsafe.Pointer(p.name))
+	C.soundio_instream_destroy(p)
+	// the callbacks have returned, so areas are in use only when EndRead was not called.
+	if s.reading.Swap(false) {
+		s.areas.invalidate()
+	}
+	s.p = 0
+	deleteHandle(&s.handle)
+	s.d.io.tracker.untrack(s.key)
+	return nil
+}
+
 // Destroy releases resources.
+//
+// Deprecated: Use Close.
 func (s *InStream) Destroy() {
-	p := s.cptr()
-	if p != nil {
-		C.free(unsafe.Pointer(p.name))
-		C.soundio_instream_destroy(p)
-		s.p = 0
+	_ = s.Close()
+}
+
+// Run starts recording, and waits events of the SoundIo until ctx is done or
+// the stream reports an error. It returns the stream error, or the cause of
+// ctx being canceled, so a ReadCallback can stop Run by canceling ctx with
+// context.WithCancelCause. The error callback is still called.
+// The stream is paused when Run returns, and Close still releases it.
+// FlushEvents and WaitEvents must not be called while running.
+//
+// Possible errors:
+// * ErrClosed
+// * errors of Start
+func (s *InStream) Run(ctx context.Context) error {
+	if s.cptr() == nil {
+		return ErrClosed
 	}
+	return s.d.io.runStream(ctx, s.Start, s.Pause, &s.stopRun)
 }
 
 // Start starts recording.
 // After you call this function, ReadCallback will be called.
 func (s *InStream) Start() error {
 	p := s.cptr()
-	return convertToError(C.soundio_instream_start(p))
+	if p == nil {
+		return ErrClosed
+	}
+	return s.opError("start", convertToError(C.soundio_instream_start(p)))
 }
 
 // BeginRead called when you are ready to begin reading from the device buffer.
 func (s *InStream) BeginRead(frameCount *int) (*ChannelAreas, error) {
 	p := s.cptr()
-	var ptrs *C.struct_SoundIoChannelArea
-	nativeFrameCount := C.int(*frameCount)
-	err := convertToError(C.soundio_instream_begin_read(p, &ptrs, &nativeFrameCount))
-	*frameCount = int(nativeFrameCount)
+	if p == nil {
+		return nil, ErrClosed
+	}
+	s.nativeFrameCount = C.int(*frameCount)
+	err := convertToError(C.soundio_instream_begin_read(p, &s.nativeAreas, &s.nativeFrameCount))
+	*frameCount = int(s.nativeFrameCount)
 	if err != nil {
-		return nil, err
+		return nil, s.opError("begin read", err)
 	}
-	if ptrs == nil {
+	if s.nativeAreas == nil {
 		return nil, nil
 	}
-	return newChannelAreas(ptrs, s.Format(), s.Layout().ChannelCount(), *frameCount), nil
+	s.areas.reset(s.nativeAreas, Format(p.format), int(p.bytes_per_sample), int(p.layout.channel_count), *frameCount)
+	s.reading.Store(true)
+	return &s.areas, nil
 }
 
 // EndRead will drop all of the frames from when you called.
 func (s *InStream) EndRead() error {
 	p := s.cptr()
-	return convertToError(C.soundio_instream_end_read(p))
+	if p == nil {
+		return ErrClosed
+	}
+	s.areas.invalidate()
+	s.reading.Store(false)
+	return s.opError("end read", convertToError(C.soundio_instream_end_read(p)))
 }
 
 // Pause pauses the stream and prevents ReadCallback from being called
 // If the underlying device supports pausing.
 func (s *InStream) Pause(pause bool) error {
 	p := s.cptr()
-	return convertToError(C.soundio_instream_pause(p, C.bool(pause)))
+	if p == nil {
+		return ErrClosed
+	}
+	return s.opError("pause", convertToError(C.soundio_instream_pause(p, C.bool(pause))))
 }
 
 // Latency returns the number of seconds that the next frame of sound being
@@ -198,20 +277,30 @@ func (s *InStream) Pause(pause bool) error {
 // This includes both software and hardware latency.
 func (s *InStream) Latency() (float64, error) {
 	p := s.cptr()
+	if p == nil {
+		return 0, ErrClosed
+	}
 	var latency C.double
 	err := convertToError(C.soundio_instream_get_latency(p, &latency))
-	return float64(latency), err
+	return float64(latency), s.opError("latency", err)
 }
 
 func newInStream(d *Device, config *InStreamConfig) (*InStream, error) {
+	if d.released() {
+		return nil, ErrClosed
+	}
+	if config.Layout != nil && config.Layout.ChannelCount() > MaxChannels {
+		return nil, d.opError("open in stream", config, ErrorInvalid)
+	}
 	p := C.soundio_instream_create(d.cptr())
 	s := &InStream{
 		p: uintptr(unsafe.Pointer(p)),
 		d: d,
 	}
 
-	p.userdata = unsafe.Pointer(s)
-	C.setInStreamCallback(p)
+	var userdata C.uintptr_t
+	s.handle, userdata = newHandle(s)
+	C.setInStreamCallback(p, userdata)
 
 	// set config values
 	if config.Format != FormatInvalid {
@@ -221,7 +310,7 @@ func newInStream(d *Device, config *InStreamConfig) (*InStream, error) {
 		p.sample_rate = C.int(config.SampleRate)
 	}
 	if config.Layout != nil {
-		C.memcpy(unsafe.Pointer(&p.layout), unsafe.Pointer(config.Layout.cptr()), C.sizeof_struct_SoundIoChannelLayout)
+		config.Layout.copyTo(&p.layout)
 	}
 	if config.SoftwareLatency > 0.0 {
 		p.software_latency = C.double(config.SoftwareLatency)
@@ -232,13 +321,38 @@ func newInStream(d *Device, config *InStreamConfig) (*InStream, error) {
 
 	err := convertToError(C.soundio_instream_open(p))
 	if err != nil {
+		C.free(unsafe.Pointer(p.name))
 		C.soundio_instream_destroy(p)
-		return nil, err
+		deleteHandle(&s.handle)
+		return nil, d.opError("open in stream", config, err)
 	}
+	s.layout = *newChannelLayout(&p.layout)
+
+	w := weak.Make(s)
+	s.key = d.io.tracker.track("in stream", C.GoString(p.name), creationStack(), func() {
+		if s := w.Value(); s != nil {
+			_ = s.Close()
+		}
+	})
 
 	return s, nil
 }
 
+// opError wraps err with the device and the parameters of the stream.
+func (s *InStream) opError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	layout := s.layout
+	return s.d.opError(op, &InStreamConfig{
+		Format:          s.Format(),
+		SampleRate:      s.SampleRate(),
+		Layout:          &layout,
+		SoftwareLatency: s.SoftwareLatency(),
+		Name:            s.Name(),
+	}, err)
+}
+
 func (s *InStream) cptr() *C.struct_SoundIoInStream {
 	if s.p == 0 {
 		return nil
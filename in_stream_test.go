@@ -0,0 +1,63 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInStreamBeginReadAllocs(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.InputDevice(s.DefaultInputDeviceIndex())
+	defer device.RemoveReference()
+
+	stream, err := device.NewInStream(&InStreamConfig{})
+	if err != nil {
+		t.Fatalf("unable to open input stream: %s", err)
+	}
+	defer stream.Close()
+
+	var once sync.Once
+	result := make(chan float64, 1)
+	stream.SetReadCallback(func(stream *InStream, frameCountMin int, frameCountMax int) {
+		if frameCountMax <= allocRuns {
+			return
+		}
+		once.Do(func() {
+			result <- testing.AllocsPerRun(allocRuns, func() {
+				frameCount := 1
+				areas, err := stream.BeginRead(&frameCount)
+				if err != nil {
+					return
+				}
+				if areas != nil {
+					for ch := 0; ch < stream.Layout().ChannelCount(); ch++ {
+						_ = areas.ReadFloat32(ch, 0)
+					}
+				}
+				_ = stream.EndRead()
+			})
+		})
+	})
+	if err := stream.Start(); err != nil {
+		t.Fatalf("unable to start input stream: %s", err)
+	}
+
+	select {
+	case allocs := <-result:
+		if allocs != 0 {
+			t.Errorf("BeginRead allocates %.1f times per callback, want 0", allocs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("read callback was not called")
+	}
+}
@@ -0,0 +1,308 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+/*
+#include "soundio.h"
+#include <stdlib.h>
+*/
+import "C"
+import "unsafe"
+
+// The parts of the value types that are implemented by libsoundio.
+// Builds without cgo use the fallbacks of native_nocgo.go instead.
+
+// The enumerations are declared in Go, so that they are available without cgo.
+// An "invalid array index" or "overflows" compiler error here means that they
+// differ from soundio.h.
+func _() {
+	var x [1]struct{}
+	_ = x[BackendNone-C.SoundIoBackendNone]
+	_ = x[BackendJack-C.SoundIoBackendJack]
+	_ = x[BackendPulseAudio-C.SoundIoBackendPulseAudio]
+	_ = x[BackendAlsa-C.SoundIoBackendAlsa]
+	_ = x[BackendCoreAudio-C.SoundIoBackendCoreAudio]
+	_ = x[BackendWasapi-C.SoundIoBackendWasapi]
+	_ = x[BackendDummy-C.SoundIoBackendDummy]
+	_ = x[DeviceAimInput-C.SoundIoDeviceAimInput]
+	_ = x[DeviceAimOutput-C.SoundIoDeviceAimOutput]
+	_ = x[ErrorNone-C.SoundIoErrorNone]
+	_ = x[ErrorNoMem-C.SoundIoErrorNoMem]
+	_ = x[ErrorInitAudioBackend-C.SoundIoErrorInitAudioBackend]
+	_ = x[ErrorSystemResources-C.SoundIoErrorSystemResources]
+	_ = x[ErrorOpeningDevice-C.SoundIoErrorOpeningDevice]
+	_ = x[ErrorNoSuchDevice-C.SoundIoErrorNoSuchDevice]
+	_ = x[ErrorInvalid-C.SoundIoErrorInvalid]
+	_ = x[ErrorBackendUnavailable-C.SoundIoErrorBackendUnavailable]
+	_ = x[ErrorStreaming-C.SoundIoErrorStreaming]
+	_ = x[ErrorIncompatibleDevice-C.SoundIoErrorIncompatibleDevice]
+	_ = x[ErrorNoSuchClient-C.SoundIoErrorNoSuchClient]
+	_ = x[ErrorIncompatibleBackend-C.SoundIoErrorIncompatibleBackend]
+	_ = x[ErrorBackendDisconnected-C.SoundIoErrorBackendDisconnected]
+	_ = x[ErrorInterrupted-C.SoundIoErrorInterrupted]
+	_ = x[ErrorUnderflow-C.SoundIoErrorUnderflow]
+	_ = x[ErrorEncodingString-C.SoundIoErrorEncodingString]
+	_ = x[FormatInvalid-C.SoundIoFormatInvalid]
+	_ = x[FormatS8-C.SoundIoFormatS8]
+	_ = x[FormatU8-C.SoundIoFormatU8]
+	_ = x[FormatS16LE-C.SoundIoFormatS16LE]
+	_ = x[FormatS16BE-C.SoundIoFormatS16BE]
+	_ = x[FormatU16LE-C.SoundIoFormatU16LE]
+	_ = x[FormatU16BE-C.SoundIoFormatU16BE]
+	_ = x[FormatS24LE-C.SoundIoFormatS24LE]
+	_ = x[FormatS24BE-C.SoundIoFormatS24BE]
+	_ = x[FormatU24LE-C.SoundIoFormatU24LE]
+	_ = x[FormatU24BE-C.SoundIoFormatU24BE]
+	_ = x[FormatS32LE-C.SoundIoFormatS32LE]
+	_ = x[FormatS32BE-C.SoundIoFormatS32BE]
+	_ = x[FormatU32LE-C.SoundIoFormatU32LE]
+	_ = x[FormatU32BE-C.SoundIoFormatU32BE]
+	_ = x[FormatFloat32LE-C.SoundIoFormatFloat32LE]
+	_ = x[FormatFloat32BE-C.SoundIoFormatFloat32BE]
+	_ = x[FormatFloat64LE-C.SoundIoFormatFloat64LE]
+	_ = x[FormatFloat64BE-C.SoundIoFormatFloat64BE]
+	_ = x[FormatS16NE-C.SoundIoFormatS16NE]
+	_ = x[FormatS16FE-C.SoundIoFormatS16FE]
+	_ = x[FormatU16NE-C.SoundIoFormatU16NE]
+	_ = x[FormatU16FE-C.SoundIoFormatU16FE]
+	_ = x[FormatS24NE-C.SoundIoFormatS24NE]
+	_ = x[FormatS24FE-C.SoundIoFormatS24FE]
+	_ = x[FormatU24NE-C.SoundIoFormatU24NE]
+	_ = x[FormatU24FE-C.SoundIoFormatU24FE]
+	_ = x[FormatS32NE-C.SoundIoFormatS32NE]
+	_ = x[FormatS32FE-C.SoundIoFormatS32FE]
+	_ = x[FormatU32NE-C.SoundIoFormatU32NE]
+	_ = x[FormatU32FE-C.SoundIoFormatU32FE]
+	_ = x[FormatFloat32NE-C.SoundIoFormatFloat32NE]
+	_ = x[FormatFloat32FE-C.SoundIoFormatFloat32FE]
+	_ = x[FormatFloat64NE-C.SoundIoFormatFloat64NE]
+	_ = x[FormatFloat64FE-C.SoundIoFormatFloat64FE]
+	_ = x[ChannelIDInvalid-C.SoundIoChannelIdInvalid]
+	_ = x[ChannelIDFrontLeft-C.SoundIoChannelIdFrontLeft]
+	_ = x[ChannelIDFrontRight-C.SoundIoChannelIdFrontRight]
+	_ = x[ChannelIDFrontCenter-C.SoundIoChannelIdFrontCenter]
+	_ = x[ChannelIDLfe-C.SoundIoChannelIdLfe]
+	_ = x[ChannelIDBackLeft-C.SoundIoChannelIdBackLeft]
+	_ = x[ChannelIDBackRight-C.SoundIoChannelIdBackRight]
+	_ = x[ChannelIDFrontLeftCenter-C.SoundIoChannelIdFrontLeftCenter]
+	_ = x[ChannelIDFrontRightCenter-C.SoundIoChannelIdFrontRightCenter]
+	_ = x[ChannelIDBackCenter-C.SoundIoChannelIdBackCenter]
+	_ = x[ChannelIDSideLeft-C.SoundIoChannelIdSideLeft]
+	_ = x[ChannelIDSideRight-C.SoundIoChannelIdSideRight]
+	_ = x[ChannelIDTopCenter-C.SoundIoChannelIdTopCenter]
+	_ = x[ChannelIDTopFrontLeft-C.SoundIoChannelIdTopFrontLeft]
+	_ = x[ChannelIDTopFrontCenter-C.SoundIoChannelIdTopFrontCenter]
+	_ = x[ChannelIDTopFrontRight-C.SoundIoChannelIdTopFrontRight]
+	_ = x[ChannelIDTopBackLeft-C.SoundIoChannelIdTopBackLeft]
+	_ = x[ChannelIDTopBackCenter-C.SoundIoChannelIdTopBackCenter]
+	_ = x[ChannelIDTopBackRight-C.SoundIoChannelIdTopBackRight]
+	_ = x[ChannelIDBackLeftCenter-C.SoundIoChannelIdBackLeftCenter]
+	_ = x[ChannelIDBackRightCenter-C.SoundIoChannelIdBackRightCenter]
+	_ = x[ChannelIDFrontLeftWide-C.SoundIoChannelIdFrontLeftWide]
+	_ = x[ChannelIDFrontRightWide-C.SoundIoChannelIdFrontRightWide]
+	_ = x[ChannelIDFrontLeftHigh-C.SoundIoChannelIdFrontLeftHigh]
+	_ = x[ChannelIDFrontCenterHigh-C.SoundIoChannelIdFrontCenterHigh]
+	_ = x[ChannelIDFrontRightHigh-C.SoundIoChannelIdFrontRightHigh]
+	_ = x[ChannelIDTopFrontLeftCenter-C.SoundIoChannelIdTopFrontLeftCenter]
+	_ = x[ChannelIDTopFrontRightCenter-C.SoundIoChannelIdTopFrontRightCenter]
+	_ = x[ChannelIDTopSideLeft-C.SoundIoChannelIdTopSideLeft]
+	_ = x[ChannelIDTopSideRight-C.SoundIoChannelIdTopSideRight]
+	_ = x[ChannelIDLeftLfe-C.SoundIoChannelIdLeftLfe]
+	_ = x[ChannelIDRightLfe-C.SoundIoChannelIdRightLfe]
+	_ = x[ChannelIDLfe2-C.SoundIoChannelIdLfe2]
+	_ = x[ChannelIDBottomCenter-C.SoundIoChannelIdBottomCenter]
+	_ = x[ChannelIDBottomLeftCenter-C.SoundIoChannelIdBottomLeftCenter]
+	_ = x[ChannelIDBottomRightCenter-C.SoundIoChannelIdBottomRightCenter]
+	_ = x[ChannelIDMsMid-C.SoundIoChannelIdMsMid]
+	_ = x[ChannelIDMsSide-C.SoundIoChannelIdMsSide]
+	_ = x[ChannelIDAmbisonicW-C.SoundIoChannelIdAmbisonicW]
+	_ = x[ChannelIDAmbisonicX-C.SoundIoChannelIdAmbisonicX]
+	_ = x[ChannelIDAmbisonicY-C.SoundIoChannelIdAmbisonicY]
+	_ = x[ChannelIDAmbisonicZ-C.SoundIoChannelIdAmbisonicZ]
+	_ = x[ChannelIDXyX-C.SoundIoChannelIdXyX]
+	_ = x[ChannelIDXyY-C.SoundIoChannelIdXyY]
+	_ = x[ChannelIDHeadphonesLeft-C.SoundIoChannelIdHeadphonesLeft]
+	_ = x[ChannelIDHeadphonesRight-C.SoundIoChannelIdHeadphonesRight]
+	_ = x[ChannelIDClickTrack-C.SoundIoChannelIdClickTrack]
+	_ = x[ChannelIDForeignLanguage-C.SoundIoChannelIdForeignLanguage]
+	_ = x[ChannelIDHearingImpaired-C.SoundIoChannelIdHearingImpaired]
+	_ = x[ChannelIDNarration-C.SoundIoChannelIdNarration]
+	_ = x[ChannelIDHaptic-C.SoundIoChannelIdHaptic]
+	_ = x[ChannelIDDialogCentricMix-C.SoundIoChannelIdDialogCentricMix]
+	_ = x[ChannelIDAux-C.SoundIoChannelIdAux]
+	_ = x[ChannelIDAux0-C.SoundIoChannelIdAux0]
+	_ = x[ChannelIDAux1-C.SoundIoChannelIdAux1]
+	_ = x[ChannelIDAux2-C.SoundIoChannelIdAux2]
+	_ = x[ChannelIDAux3-C.SoundIoChannelIdAux3]
+	_ = x[ChannelIDAux4-C.SoundIoChannelIdAux4]
+	_ = x[ChannelIDAux5-C.SoundIoChannelIdAux5]
+	_ = x[ChannelIDAux6-C.SoundIoChannelIdAux6]
+	_ = x[ChannelIDAux7-C.SoundIoChannelIdAux7]
+	_ = x[ChannelIDAux8-C.SoundIoChannelIdAux8]
+	_ = x[ChannelIDAux9-C.SoundIoChannelIdAux9]
+	_ = x[ChannelIDAux10-C.SoundIoChannelIdAux10]
+	_ = x[ChannelIDAux11-C.SoundIoChannelIdAux11]
+	_ = x[ChannelIDAux12-C.SoundIoChannelIdAux12]
+	_ = x[ChannelIDAux13-C.SoundIoChannelIdAux13]
+	_ = x[ChannelIDAux14-C.SoundIoChannelIdAux14]
+	_ = x[ChannelIDAux15-C.SoundIoChannelIdAux15]
+	_ = x[ChannelLayoutIDMono-C.SoundIoChannelLayoutIdMono]
+	_ = x[ChannelLayoutIDStereo-C.SoundIoChannelLayoutIdStereo]
+	_ = x[ChannelLayoutID2Point1-C.SoundIoChannelLayoutId2Point1]
+	_ = x[ChannelLayoutID3Point0-C.SoundIoChannelLayoutId3Point0]
+	_ = x[ChannelLayoutID3Point0Back-C.SoundIoChannelLayoutId3Point0Back]
+	_ = x[ChannelLayoutID3Point1-C.SoundIoChannelLayoutId3Point1]
+	_ = x[ChannelLayoutID4Point0-C.SoundIoChannelLayoutId4Point0]
+	_ = x[ChannelLayoutIDQuad-C.SoundIoChannelLayoutIdQuad]
+	_ = x[ChannelLayoutIDQuadSide-C.SoundIoChannelLayoutIdQuadSide]
+	_ = x[ChannelLayoutID4Point1-C.SoundIoChannelLayoutId4Point1]
+	_ = x[ChannelLayoutID5Point0Back-C.SoundIoChannelLayoutId5Point0Back]
+	_ = x[ChannelLayoutID5Point0Side-C.SoundIoChannelLayoutId5Point0Side]
+	_ = x[ChannelLayoutID5Point1-C.SoundIoChannelLayoutId5Point1]
+	_ = x[ChannelLayoutID5Point1Back-C.SoundIoChannelLayoutId5Point1Back]
+	_ = x[ChannelLayoutID6Point0Side-C.SoundIoChannelLayoutId6Point0Side]
+	_ = x[ChannelLayoutID6Point0Front-C.SoundIoChannelLayoutId6Point0Front]
+	_ = x[ChannelLayoutIDHexagonal-C.SoundIoChannelLayoutIdHexagonal]
+	_ = x[ChannelLayoutID6Point1-C.SoundIoChannelLayoutId6Point1]
+	_ = x[ChannelLayoutID6Point1Back-C.SoundIoChannelLayoutId6Point1Back]
+	_ = x[ChannelLayoutID6Point1Front-C.SoundIoChannelLayoutId6Point1Front]
+	_ = x[ChannelLayoutID7Point0-C.SoundIoChannelLayoutId7Point0]
+	_ = x[ChannelLayoutID7Point0Front-C.SoundIoChannelLayoutId7Point0Front]
+	_ = x[ChannelLayoutID7Point1-C.SoundIoChannelLayoutId7Point1]
+	_ = x[ChannelLayoutID7Point1Wide-C.SoundIoChannelLayoutId7Point1Wide]
+	_ = x[ChannelLayoutID7Point1WideBack-C.SoundIoChannelLayoutId7Point1WideBack]
+	_ = x[ChannelLayoutIDOctagonal-C.SoundIoChannelLayoutIdOctagonal]
+	_ = x[MaxChannels-C.SOUNDIO_MAX_CHANNELS]
+}
+
+func (b Backend) String() string {
+	return C.GoString(C.soundio_backend_name(uint32(b)))
+}
+
+// Have returns whether libsoundio was compiled with backend.
+func (b Backend) Have() bool {
+	return bool(C.soundio_have_backend(uint32(b)))
+}
+
+func (f Format) String() string {
+	return C.GoString(C.soundio_format_string(uint32(f)))
+}
+
+func (e Error) Error() string {
+	return C.GoString(C.soundio_strerror(C.int(e)))
+}
+
+func convertToError(err C.int) error {
+	if err == C.SoundIoErrorNone {
+		return nil
+	}
+	return Error(err)
+}
+
+func (c ChannelID) String() string {
+	return C.GoString(C.soundio_get_channel_name(uint32(c)))
+}
+
+// ParseChannelID returns ChannelID from string.
+func ParseChannelID(str string) ChannelID {
+	cstr := C.CString(str)
+	defer C.free(unsafe.Pointer(cstr))
+	return ChannelID(uint32(C.soundio_parse_channel_id(cstr, C.int(len(str)))))
+}
+
+// ChannelLayoutBuiltinCount returns the number of builtin channel layouts.
+func ChannelLayoutBuiltinCount() int {
+	return int(C.soundio_channel_layout_builtin_count())
+}
+
+// ChannelLayoutGetBuiltin returns a builtin channel layout.
+// 0 <= `index` < ChannelLayoutBuiltinCount
+func ChannelLayoutGetBuiltin(index ChannelLayoutID) *ChannelLayout {
+	return newChannelLayout(C.soundio_channel_layout_get_builtin(C.int(index)))
+}
+
+// ChannelLayoutGetDefault returns the default builtin channel layout for the given number of channels.
+func ChannelLayoutGetDefault(channelCount int) *ChannelLayout {
+	return newChannelLayout(C.soundio_channel_layout_get_default(C.int(channelCount)))
+}
+
+// BestMatchingLayout returns nil if none matches, or either device is released.
+// Iterates over preferredLayouts. Returns the first channel layout in
+// preferredLayouts which matches one of the channel layouts in availableLayouts.
+func BestMatchingLayout(device1 *Device, device2 *Device) *ChannelLayout {
+	d1p := device1.cptr()
+	d2p := device2.cptr()
+	if d1p == nil || d2p == nil {
+		return nil
+	}
+	return newChannelLayout(C.soundio_best_matching_channel_layout(d1p.layouts, d1p.layout_count, d2p.layouts, d2p.layout_count))
+}
+
+// newChannelLayout copies a C channel layout, or returns nil for NULL.
+func newChannelLayout(p *C.struct_SoundIoChannelLayout) *ChannelLayout {
+	if p == nil {
+		return nil
+	}
+	count := min(int(p.channel_count), MaxChannels)
+	l := &ChannelLayout{
+		channels: make([]ChannelID, count),
+	}
+	if p.name != nil {
+		l.name = C.GoString(p.name)
+	}
+	for i := 0; i < count; i++ {
+		l.channels[i] = ChannelID(uint32(p.channels[i]))
+	}
+	return l
+}
+
+// copyTo stores the layout into a C channel layout.
+// The name is taken from the matching builtin layout, if any, so no C memory is allocated.
+// The channel count must not exceed MaxChannels.
+func (l *ChannelLayout) copyTo(p *C.struct_SoundIoChannelLayout) {
+	p.name = nil
+	p.channel_count = C.int(len(l.channels))
+	for i, c := range l.channels {
+		p.channels[i] = uint32(c)
+	}
+	C.soundio_channel_layout_detect_builtin(p)
+}
+
+func newSampleRateRange(r *C.struct_SoundIoSampleRateRange) SampleRateRange {
+	return SampleRateRange{
+		min: int(r.min),
+		max: int(r.max),
+	}
+}
+
+// reset re-points the area at native sound data.
+func (a *ChannelArea) reset(area *C.struct_SoundIoChannelArea, format Format, bytesPerSample int, frameCount int) {
+	a.step = int(area.step)
+	a.bytesPerSample = bytesPerSample
+	a.codec = codecOf(format)
+
+	size := 0
+	if frameCount > 0 {
+		size = (frameCount-1)*a.step + bytesPerSample
+	}
+	a.buffer = unsafe.Slice((*byte)(unsafe.Pointer(area.ptr)), size)
+	a.valid = true
+}
+
+// reset re-points areas at the native channel areas without allocating.
+func (a *ChannelAreas) reset(native *C.struct_SoundIoChannelArea, format Format, bytesPerSample int, channelCount int, frameCount int) {
+	nativeAreas := unsafe.Slice(native, channelCount)
+	a.areas = a.pointers[:channelCount]
+	for ch := range nativeAreas {
+		a.storage[ch].reset(&nativeAreas[ch], format, bytesPerSample, frameCount)
+		a.areas[ch] = &a.storage[ch]
+	}
+	a.format = format
+	a.channelCount = channelCount
+	a.frameCount = frameCount
+}
@@ -0,0 +1,194 @@
+//go:build !cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Without cgo, only the value types are available, such as for the fake of
+// package sio/siotest. These replace the parts of them implemented by libsoundio.
+
+var backendNames = [...]string{
+	BackendNone:       "(none)",
+	BackendJack:       "JACK",
+	BackendPulseAudio: "PulseAudio",
+	BackendAlsa:       "ALSA",
+	BackendCoreAudio:  "CoreAudio",
+	BackendWasapi:     "WASAPI",
+	BackendDummy:      "Dummy",
+}
+
+func (b Backend) String() string {
+	if int(b) < len(backendNames) {
+		return backendNames[b]
+	}
+	return "(invalid back end)"
+}
+
+// Have returns false, as libsoundio is not linked without cgo.
+func (b Backend) Have() bool {
+	return false
+}
+
+func (f Format) String() string {
+	c := codecOf(f)
+	if c.size == 0 {
+		return "(invalid sample format)"
+	}
+	s := "signed "
+	if c.float {
+		s = "float "
+	} else if c.unsigned {
+		s = "unsigned "
+	}
+	s += strconv.Itoa(int(c.bits)) + "-bit"
+	if c.size == 1 {
+		return s
+	}
+	if c.bigEndian {
+		return s + " BE"
+	}
+	return s + " LE"
+}
+
+var errorStrings = [...]string{
+	ErrorNone:                "(no error)",
+	ErrorNoMem:               "out of memory",
+	ErrorInitAudioBackend:    "unable to initialize audio backend",
+	ErrorSystemResources:     "system resource not available",
+	ErrorOpeningDevice:       "unable to open device",
+	ErrorNoSuchDevice:        "no such device",
+	ErrorInvalid:             "invalid value",
+	ErrorBackendUnavailable:  "backend unavailable",
+	ErrorStreaming:           "unrecoverable streaming failure",
+	ErrorIncompatibleDevice:  "incompatible device",
+	ErrorNoSuchClient:        "no such client",
+	ErrorIncompatibleBackend: "incompatible backend",
+	ErrorBackendDisconnected: "backend disconnected",
+	ErrorInterrupted:         "interrupted; try again",
+	ErrorUnderflow:           "buffer underflow",
+	ErrorEncodingString:      "failed to encode string",
+}
+
+func (e Error) Error() string {
+	if e >= 0 && int(e) < len(errorStrings) {
+		return errorStrings[e]
+	}
+	return "(invalid error)"
+}
+
+var channelNames = [...]string{
+	ChannelIDInvalid:             "(Invalid Channel)",
+	ChannelIDFrontLeft:           "Front Left",
+	ChannelIDFrontRight:          "Front Right",
+	ChannelIDFrontCenter:         "Front Center",
+	ChannelIDLfe:                 "LFE",
+	ChannelIDBackLeft:            "Back Left",
+	ChannelIDBackRight:           "Back Right",
+	ChannelIDFrontLeftCenter:     "Front Left Center",
+	ChannelIDFrontRightCenter:    "Front Right Center",
+	ChannelIDBackCenter:          "Back Center",
+	ChannelIDSideLeft:            "Side Left",
+	ChannelIDSideRight:           "Side Right",
+	ChannelIDTopCenter:           "Top Center",
+	ChannelIDTopFrontLeft:        "Top Front Left",
+	ChannelIDTopFrontCenter:      "Top Front Center",
+	ChannelIDTopFrontRight:       "Top Front Right",
+	ChannelIDTopBackLeft:         "Top Back Left",
+	ChannelIDTopBackCenter:       "Top Back Center",
+	ChannelIDTopBackRight:        "Top Back Right",
+	ChannelIDBackLeftCenter:      "Back Left Center",
+	ChannelIDBackRightCenter:     "Back Right Center",
+	ChannelIDFrontLeftWide:       "Front Left Wide",
+	ChannelIDFrontRightWide:      "Front Right Wide",
+	ChannelIDFrontLeftHigh:       "Front Left High",
+	ChannelIDFrontCenterHigh:     "Front Center High",
+	ChannelIDFrontRightHigh:      "Front Right High",
+	ChannelIDTopFrontLeftCenter:  "Top Front Left Center",
+	ChannelIDTopFrontRightCenter: "Top Front Right Center",
+	ChannelIDTopSideLeft:         "Top Side Left",
+	ChannelIDTopSideRight:        "Top Side Right",
+	ChannelIDLeftLfe:             "Left LFE",
+	ChannelIDRightLfe:            "Right LFE",
+	ChannelIDLfe2:                "LFE 2",
+	ChannelIDBottomCenter:        "Bottom Center",
+	ChannelIDBottomLeftCenter:    "Bottom Left Center",
+	ChannelIDBottomRightCenter:   "Bottom Right Center",
+	ChannelIDMsMid:               "Mid/Side Mid",
+	ChannelIDMsSide:              "Mid/Side Side",
+	ChannelIDAmbisonicW:          "Ambisonic W",
+	ChannelIDAmbisonicX:          "Ambisonic X",
+	ChannelIDAmbisonicY:          "Ambisonic Y",
+	ChannelIDAmbisonicZ:          "Ambisonic Z",
+	ChannelIDXyX:                 "X-Y X",
+	ChannelIDXyY:                 "X-Y Y",
+	ChannelIDHeadphonesLeft:      "Headphones Left",
+	ChannelIDHeadphonesRight:     "Headphones Right",
+	ChannelIDClickTrack:          "Click Track",
+	ChannelIDForeignLanguage:     "Foreign Language",
+	ChannelIDHearingImpaired:     "Hearing Impaired",
+	ChannelIDNarration:           "Narration",
+	ChannelIDHaptic:              "Haptic",
+	ChannelIDDialogCentricMix:    "Dialog Centric Mix",
+	ChannelIDAux:                 "Aux",
+	ChannelIDAux0:                "Aux 0",
+	ChannelIDAux1:                "Aux 1",
+	ChannelIDAux2:                "Aux 2",
+	ChannelIDAux3:                "Aux 3",
+	ChannelIDAux4:                "Aux 4",
+	ChannelIDAux5:                "Aux 5",
+	ChannelIDAux6:                "Aux 6",
+	ChannelIDAux7:                "Aux 7",
+	ChannelIDAux8:                "Aux 8",
+	ChannelIDAux9:                "Aux 9",
+	ChannelIDAux10:               "Aux 10",
+	ChannelIDAux11:               "Aux 11",
+	ChannelIDAux12:               "Aux 12",
+	ChannelIDAux13:               "Aux 13",
+	ChannelIDAux14:               "Aux 14",
+	ChannelIDAux15:               "Aux 15",
+}
+
+func (c ChannelID) String() string {
+	if int(c) < len(channelNames) {
+		return channelNames[c]
+	}
+	return channelNames[ChannelIDInvalid]
+}
+
+// ParseChannelID returns ChannelID from string.
+func ParseChannelID(str string) ChannelID {
+	for c, name := range channelNames[1:] {
+		if strings.EqualFold(name, str) {
+			return ChannelID(c + 1)
+		}
+	}
+	return ChannelIDInvalid
+}
+
+// ChannelLayoutBuiltinCount returns 0, as the builtin channel layouts are
+// defined by libsoundio.
+func ChannelLayoutBuiltinCount() int {
+	return 0
+}
+
+// ChannelLayoutGetBuiltin returns nil, as the builtin channel layouts are
+// defined by libsoundio.
+func ChannelLayoutGetBuiltin(index ChannelLayoutID) *ChannelLayout {
+	return nil
+}
+
+// ChannelLayoutGetDefault returns nil, as the builtin channel layouts are
+// defined by libsoundio.
+func ChannelLayoutGetDefault(channelCount int) *ChannelLayout {
+	return nil
+}
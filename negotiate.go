@@ -0,0 +1,518 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import "slices"
+
+// StreamPreferences lists the stream parameters an application prefers.
+// Every list is ordered from the most preferred, and an empty list falls
+// back to the application format and then to common defaults.
+type StreamPreferences struct {
+	// Formats are the preferred device formats.
+	Formats []Format
+	// SampleRates are the preferred device sample rates.
+	SampleRates []int
+	// Layouts are the preferred device channel layouts.
+	// When neither these nor AppLayout are supported, the current layout
+	// of the device is used.
+	Layouts []*ChannelLayout
+	// SoftwareLatency is the desired latency in seconds, clamped to the
+	// range of the device. 0 leaves the choice to the backend.
+	SoftwareLatency float64
+	// Name is the stream name.
+	Name string
+
+	// AppFormat is the format the application reads or writes.
+	// FormatInvalid means the negotiated format.
+	AppFormat Format
+	// AppSampleRate is the sample rate the application works at.
+	// 0 means the negotiated sample rate.
+	AppSampleRate int
+	// AppLayout is the channel layout the application works with.
+	// nil means the negotiated layout.
+	AppLayout *ChannelLayout
+}
+
+// Plan describes negotiated stream parameters, and the conversions needed
+// between them and the application.
+type Plan struct {
+	Format          Format
+	SampleRate      int
+	Layout          *ChannelLayout
+	SoftwareLatency float64
+
+	AppFormat     Format
+	AppSampleRate int
+	AppLayout     *ChannelLayout
+
+	// ConvertFormat is whether samples are converted between Format and AppFormat.
+	ConvertFormat bool
+	// Resample is whether frames are resampled between SampleRate and AppSampleRate.
+	Resample bool
+	// Remix is whether channels are remixed between Layout and AppLayout.
+	Remix bool
+}
+
+// NeedsConversion returns whether any conversion is needed.
+func (p *Plan) NeedsConversion() bool {
+	return p.ConvertFormat || p.Resample || p.Remix
+}
+
+// DuplexConfig is the result of negotiating an input and an output device together.
+type DuplexConfig struct {
+	In      *InStreamConfig
+	InPlan  *Plan
+	Out     *OutStreamConfig
+	OutPlan *Plan
+}
+
+var defaultFormats = []Format{
+	FormatFloat32NE,
+	FormatFloat32FE,
+	FormatS32NE,
+	FormatS32FE,
+	FormatS24NE,
+	FormatS24FE,
+	FormatS16NE,
+	FormatS16FE,
+	FormatFloat64NE,
+	FormatFloat64FE,
+	FormatU32NE,
+	FormatU32FE,
+	FormatU24NE,
+	FormatU24FE,
+	FormatU16NE,
+	FormatU16FE,
+	FormatS8,
+	FormatU8,
+}
+
+var defaultSampleRates = []int{
+	48000,
+	44100,
+	96000,
+	24000,
+}
+
+// NegotiateIn picks the parameters of an input stream on the device according to prefs.
+// The plan describes the conversions from the stream to the application.
+//
+// Possible errors:
+//   - ErrorInvalid - the device is not an input device
+//   - ErrorIncompatibleDevice - the device has no formats, sample rates or layouts
+//   - the probe error of the device
+func (d *Device) NegotiateIn(prefs *StreamPreferences) (*InStreamConfig, *Plan, error) {
+	if d.Aim() != DeviceAimInput {
+		return nil, nil, ErrorInvalid
+	}
+	plan, err := negotiate(prefs, d)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plan.inStreamConfig(prefs.Name), plan, nil
+}
+
+// NegotiateOut picks the parameters of an output stream on the device according to prefs.
+// The plan describes the conversions from the application to the stream.
+//
+// Possible errors:
+//   - ErrorInvalid - the device is not an output device
+//   - ErrorIncompatibleDevice - the device has no formats, sample rates or layouts
+//   - the probe error of the device
+func (d *Device) NegotiateOut(prefs *StreamPreferences) (*OutStreamConfig, *Plan, error) {
+	if d.Aim() != DeviceAimOutput {
+		return nil, nil, ErrorInvalid
+	}
+	plan, err := negotiate(prefs, d)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plan.outStreamConfig(prefs.Name), plan, nil
+}
+
+// NegotiateDuplex picks the parameters of an input and an output stream,
+// preferring values both devices support so that audio passes from one to the
+// other without conversion.
+// Application values left unset in prefs are those of the input stream, so
+// the output plan describes the conversions from captured to played audio.
+func NegotiateDuplex(input *Device, output *Device, prefs *StreamPreferences) (*DuplexConfig, error) {
+	if input.Aim() != DeviceAimInput || output.Aim() != DeviceAimOutput {
+		return nil, ErrorInvalid
+	}
+	plans, err := negotiateJoint(prefs, input, output)
+	if err != nil {
+		return nil, err
+	}
+	return &DuplexConfig{
+		In:      plans[0].inStreamConfig(prefs.Name),
+		InPlan:  plans[0],
+		Out:     plans[1].outStreamConfig(prefs.Name),
+		OutPlan: plans[1],
+	}, nil
+}
+
+func (p *Plan) inStreamConfig(name string) *InStreamConfig {
+	return &InStreamConfig{
+		Format:          p.Format,
+		SampleRate:      p.SampleRate,
+		Layout:          p.Layout,
+		SoftwareLatency: p.SoftwareLatency,
+		Name:            name,
+	}
+}
+
+func (p *Plan) outStreamConfig(name string) *OutStreamConfig {
+	return &OutStreamConfig{
+		Format:          p.Format,
+		SampleRate:      p.SampleRate,
+		Layout:          p.Layout,
+		SoftwareLatency: p.SoftwareLatency,
+		Name:            name,
+	}
+}
+
+// deviceCaps is a copy of the capabilities of a device.
+type deviceCaps struct {
+	formats     []Format
+	sampleRates []SampleRateRange
+	layouts     [][]ChannelID
+	current     []ChannelID
+	latencyMin  float64
+	latencyMax  float64
+}
+
+func capsOf(d *Device) (*deviceCaps, []*ChannelLayout, error) {
+	if err := d.ProbeError(); err != nil {
+		return nil, nil, err
+	}
+	layouts := d.Layouts()
+	caps := &deviceCaps{
+		formats:     d.Formats(),
+		sampleRates: d.SampleRates(),
+		layouts:     make([][]ChannelID, len(layouts)),
+		latencyMin:  d.SoftwareLatencyMin(),
+		latencyMax:  d.SoftwareLatencyMax(),
+	}
+	for i, l := range layouts {
+		caps.layouts[i] = l.channels
+	}
+	if current := d.CurrentLayout(); current.ChannelCount() > 0 {
+		caps.current = current.channels
+	}
+	return caps, layouts, nil
+}
+
+// negotiation holds negotiated parameters, with the layout as an index into deviceCaps.layouts.
+type negotiation struct {
+	format     Format
+	sampleRate int
+	layout     int
+	latency    float64
+}
+
+// appPrefs is the part of StreamPreferences that does not depend on cgo.
+type appPrefs struct {
+	formats     []Format
+	sampleRates []int
+	layouts     [][]ChannelID
+	latency     float64
+	appFormat   Format
+	appRate     int
+	appLayout   []ChannelID
+}
+
+func appPrefsOf(prefs *StreamPreferences) *appPrefs {
+	p := &appPrefs{
+		formats:     prefs.Formats,
+		sampleRates: prefs.SampleRates,
+		latency:     prefs.SoftwareLatency,
+		appFormat:   prefs.AppFormat,
+		appRate:     prefs.AppSampleRate,
+	}
+	for _, l := range prefs.Layouts {
+		if l != nil {
+			p.layouts = append(p.layouts, l.channels)
+		}
+	}
+	if prefs.AppLayout != nil {
+		p.appLayout = prefs.AppLayout.channels
+	}
+	return p
+}
+
+func negotiate(prefs *StreamPreferences, d *Device) (*Plan, error) {
+	caps, layouts, err := capsOf(d)
+	if err != nil {
+		return nil, err
+	}
+	p := appPrefsOf(prefs)
+	n, err := p.negotiate(caps)
+	if err != nil {
+		return nil, err
+	}
+	return newPlan(p, n, layouts[n.layout], prefs.AppLayout), nil
+}
+
+func negotiateJoint(prefs *StreamPreferences, input *Device, output *Device) ([2]*Plan, error) {
+	var plans [2]*Plan
+	inCaps, inLayouts, err := capsOf(input)
+	if err != nil {
+		return plans, err
+	}
+	outCaps, outLayouts, err := capsOf(output)
+	if err != nil {
+		return plans, err
+	}
+	p := appPrefsOf(prefs)
+	ns, err := p.negotiateJoint(inCaps, outCaps)
+	if err != nil {
+		return plans, err
+	}
+
+	// the application side defaults to the input stream
+	app := *p
+	if app.appFormat == FormatInvalid {
+		app.appFormat = ns[0].format
+	}
+	if app.appRate == 0 {
+		app.appRate = ns[0].sampleRate
+	}
+	inLayout := inLayouts[ns[0].layout]
+	appLayout := prefs.AppLayout
+	if appLayout == nil {
+		appLayout = inLayout
+	}
+	plans[0] = newPlan(&app, ns[0], inLayout, appLayout)
+	plans[1] = newPlan(&app, ns[1], outLayouts[ns[1].layout], appLayout)
+	return plans, nil
+}
+
+// newPlan fills the application side of a plan, taking unset values from n.
+func newPlan(p *appPrefs, n *negotiation, layout *ChannelLayout, appLayout *ChannelLayout) *Plan {
+	plan := &Plan{
+		Format:          n.format,
+		SampleRate:      n.sampleRate,
+		Layout:          layout,
+		SoftwareLatency: n.latency,
+		AppFormat:       p.appFormat,
+		AppSampleRate:   p.appRate,
+		AppLayout:       appLayout,
+	}
+	if plan.AppFormat == FormatInvalid {
+		plan.AppFormat = plan.Format
+	}
+	if plan.AppSampleRate == 0 {
+		plan.AppSampleRate = plan.SampleRate
+	}
+	if plan.AppLayout == nil {
+		plan.AppLayout = layout
+	}
+	plan.ConvertFormat = plan.AppFormat != plan.Format
+	plan.Resample = plan.AppSampleRate != plan.SampleRate
+	plan.Remix = !plan.AppLayout.Equal(layout)
+	return plan
+}
+
+func (p *appPrefs) negotiate(caps *deviceCaps) (*negotiation, error) {
+	if len(caps.formats) == 0 || len(caps.sampleRates) == 0 || len(caps.layouts) == 0 {
+		return nil, ErrorIncompatibleDevice
+	}
+	n := &negotiation{
+		format:     p.pickFormat(caps),
+		sampleRate: p.pickSampleRate(caps),
+		layout:     p.pickLayout(caps),
+		latency:    p.pickLatency(caps),
+	}
+	if n.format == FormatInvalid {
+		n.format = caps.formats[0]
+	}
+	if n.sampleRate == 0 {
+		rate := p.appRate
+		if rate == 0 {
+			rate = defaultSampleRates[0]
+		}
+		n.sampleRate = nearestSampleRate(caps.sampleRates, rate)
+	}
+	if n.layout < 0 {
+		n.layout = findLayout(caps.layouts, caps.current)
+	}
+	if n.layout < 0 {
+		n.layout = largestLayout(caps.layouts)
+	}
+	return n, nil
+}
+
+// negotiateJoint negotiates each device alone, then replaces parameters with
+// ones both devices support where there are any.
+func (p *appPrefs) negotiateJoint(in *deviceCaps, out *deviceCaps) ([2]*negotiation, error) {
+	var ns [2]*negotiation
+	var err error
+	if ns[0], err = p.negotiate(in); err != nil {
+		return ns, err
+	}
+	if ns[1], err = p.negotiate(out); err != nil {
+		return ns, err
+	}
+
+	if f := p.pickFormat(in, out); f != FormatInvalid {
+		ns[0].format, ns[1].format = f, f
+	}
+	if rate := p.pickSampleRate(in, out); rate != 0 {
+		ns[0].sampleRate, ns[1].sampleRate = rate, rate
+	}
+	if i, o := p.pickCommonLayout(in, out); i >= 0 {
+		ns[0].layout, ns[1].layout = i, o
+	}
+	if latency := p.pickLatency(in, out); latency > 0 {
+		ns[0].latency, ns[1].latency = latency, latency
+	}
+	return ns, nil
+}
+
+// pickFormat returns the most preferred format all devices support, or FormatInvalid.
+func (p *appPrefs) pickFormat(caps ...*deviceCaps) Format {
+	candidates := slices.Concat(p.formats, []Format{p.appFormat}, defaultFormats)
+	for _, f := range candidates {
+		if f == FormatInvalid {
+			continue
+		}
+		supported := true
+		for _, c := range caps {
+			supported = supported && slices.Contains(c.formats, f)
+		}
+		if supported {
+			return f
+		}
+	}
+	return FormatInvalid
+}
+
+// pickSampleRate returns the most preferred sample rate all devices support, or 0.
+func (p *appPrefs) pickSampleRate(caps ...*deviceCaps) int {
+	candidates := slices.Concat(p.sampleRates, []int{p.appRate}, defaultSampleRates)
+	for _, rate := range candidates {
+		if rate <= 0 {
+			continue
+		}
+		supported := true
+		for _, c := range caps {
+			supported = supported && supportsSampleRate(c.sampleRates, rate)
+		}
+		if supported {
+			return rate
+		}
+	}
+	return 0
+}
+
+// pickLayout returns the index of the most preferred layout of the device, or -1.
+func (p *appPrefs) pickLayout(caps *deviceCaps) int {
+	candidates := p.layouts
+	if p.appLayout != nil {
+		candidates = append(slices.Clip(candidates), p.appLayout)
+	}
+	for _, l := range candidates {
+		if i := findLayout(caps.layouts, l); i >= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// pickCommonLayout returns the indexes of the most preferred layout both devices
+// support, falling back to the largest common layout, or -1.
+func (p *appPrefs) pickCommonLayout(in *deviceCaps, out *deviceCaps) (int, int) {
+	candidates := p.layouts
+	if p.appLayout != nil {
+		candidates = append(slices.Clip(candidates), p.appLayout)
+	}
+	for _, l := range candidates {
+		i, o := findLayout(in.layouts, l), findLayout(out.layouts, l)
+		if i >= 0 && o >= 0 {
+			return i, o
+		}
+	}
+	bestIn, bestOut := -1, -1
+	for i, l := range in.layouts {
+		o := findLayout(out.layouts, l)
+		if o >= 0 && (bestIn < 0 || len(l) > len(in.layouts[bestIn])) {
+			bestIn, bestOut = i, o
+		}
+	}
+	return bestIn, bestOut
+}
+
+// pickLatency returns the preferred latency clamped to the ranges of all devices.
+// Unknown bounds of 0.0 are ignored.
+func (p *appPrefs) pickLatency(caps ...*deviceCaps) float64 {
+	latency := p.latency
+	if latency <= 0 {
+		return 0
+	}
+	for _, c := range caps {
+		if c.latencyMin > 0 {
+			latency = max(latency, c.latencyMin)
+		}
+	}
+	for _, c := range caps {
+		if c.latencyMax > 0 {
+			latency = min(latency, c.latencyMax)
+		}
+	}
+	return latency
+}
+
+func supportsSampleRate(ranges []SampleRateRange, rate int) bool {
+	for _, r := range ranges {
+		if r.min <= rate && rate <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// nearestSampleRate returns the supported sample rate nearest to rate,
+// rounding up, or the highest one when rate is above every range.
+func nearestSampleRate(ranges []SampleRateRange, rate int) int {
+	best := 0
+	highest := 0
+	for _, r := range ranges {
+		highest = max(highest, r.max)
+		if rate <= r.max {
+			candidate := max(rate, r.min)
+			if best == 0 || candidate < best {
+				best = candidate
+			}
+		}
+	}
+	if best == 0 {
+		return highest
+	}
+	return best
+}
+
+func findLayout(layouts [][]ChannelID, channels []ChannelID) int {
+	for i, l := range layouts {
+		if slices.Equal(l, channels) {
+			return i
+		}
+	}
+	return -1
+}
+
+func largestLayout(layouts [][]ChannelID) int {
+	best := 0
+	for i, l := range layouts {
+		if len(l) > len(layouts[best]) {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,164 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"testing"
+)
+
+func testCaps() *deviceCaps {
+	return &deviceCaps{
+		formats:     []Format{FormatS16LE, FormatS32LE, FormatFloat32LE},
+		sampleRates: []SampleRateRange{{min: 44100, max: 44100}, {min: 48000, max: 96000}},
+		layouts:     [][]ChannelID{monoIDs, fiveOne, stereoIDs},
+		latencyMin:  0.01,
+		latencyMax:  0.5,
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name  string
+		prefs appPrefs
+		want  negotiation
+	}{
+		{"defaults", appPrefs{}, negotiation{FormatFloat32NE, 48000, 1, 0}},
+		{"preferred", appPrefs{
+			formats:     []Format{FormatU8, FormatS16LE},
+			sampleRates: []int{32000, 88200},
+			layouts:     [][]ChannelID{sevenOne, stereoIDs},
+		}, negotiation{FormatS16LE, 88200, 2, 0}},
+		{"application", appPrefs{
+			appFormat: FormatS32LE,
+			appRate:   44100,
+			appLayout: monoIDs,
+		}, negotiation{FormatS32LE, 44100, 0, 0}},
+		{"unsupported application rate", appPrefs{appRate: 22050}, negotiation{FormatFloat32NE, 48000, 1, 0}},
+		{"latency", appPrefs{latency: 0.2}, negotiation{FormatFloat32NE, 48000, 1, 0.2}},
+		{"latency below range", appPrefs{latency: 0.001}, negotiation{FormatFloat32NE, 48000, 1, 0.01}},
+		{"latency above range", appPrefs{latency: 2}, negotiation{FormatFloat32NE, 48000, 1, 0.5}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n, err := test.prefs.negotiate(testCaps())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *n != test.want {
+				t.Errorf("negotiate = %+v, want %+v", *n, test.want)
+			}
+		})
+	}
+}
+
+func TestNegotiateFallback(t *testing.T) {
+	caps := &deviceCaps{
+		formats:     []Format{FormatU24BE},
+		sampleRates: []SampleRateRange{{min: 8000, max: 16000}},
+		layouts:     [][]ChannelID{stereoIDs},
+	}
+	p := appPrefs{latency: 0.1}
+	n, err := p.negotiate(caps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := negotiation{FormatU24BE, 16000, 0, 0.1}
+	if *n != want {
+		t.Errorf("negotiate = %+v, want %+v", *n, want)
+	}
+
+	if _, err := p.negotiate(&deviceCaps{}); err != ErrorIncompatibleDevice {
+		t.Errorf("err = %v, want ErrorIncompatibleDevice", err)
+	}
+}
+
+func TestNegotiateCurrentLayout(t *testing.T) {
+	caps := testCaps()
+	caps.current = stereoIDs
+	n, err := (&appPrefs{}).negotiate(caps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.layout != 2 {
+		t.Errorf("layout = %d, want the current layout 2", n.layout)
+	}
+
+	// a current layout the device does not list falls back to the largest
+	caps.current = sevenOne
+	if n, _ = (&appPrefs{}).negotiate(caps); n.layout != 1 {
+		t.Errorf("layout = %d, want the largest layout 1", n.layout)
+	}
+
+	// preferences still come first
+	caps.current = stereoIDs
+	if n, _ = (&appPrefs{appLayout: monoIDs}).negotiate(caps); n.layout != 0 {
+		t.Errorf("layout = %d, want the application layout 0", n.layout)
+	}
+}
+
+func TestNegotiateJoint(t *testing.T) {
+	in := &deviceCaps{
+		formats:     []Format{FormatS16LE, FormatS24LE},
+		sampleRates: []SampleRateRange{{min: 44100, max: 48000}},
+		layouts:     [][]ChannelID{monoIDs, stereoIDs},
+		latencyMin:  0.05,
+	}
+	out := &deviceCaps{
+		formats:     []Format{FormatS24LE, FormatFloat32LE},
+		sampleRates: []SampleRateRange{{min: 44100, max: 44100}},
+		layouts:     [][]ChannelID{fiveOne, stereoIDs},
+		latencyMax:  0.3,
+	}
+	p := appPrefs{latency: 0.01}
+	ns, err := p.negotiateJoint(in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (negotiation{FormatS24LE, 44100, 1, 0.05}); *ns[0] != want {
+		t.Errorf("input = %+v, want %+v", *ns[0], want)
+	}
+	if want := (negotiation{FormatS24LE, 44100, 1, 0.05}); *ns[1] != want {
+		t.Errorf("output = %+v, want %+v", *ns[1], want)
+	}
+
+	// without common parameters each device keeps its own
+	out.formats = []Format{FormatFloat32LE}
+	out.sampleRates = []SampleRateRange{{min: 96000, max: 96000}}
+	out.layouts = [][]ChannelID{fiveOne}
+	ns, err = p.negotiateJoint(in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (negotiation{FormatS24LE, 48000, 1, 0.05}); *ns[0] != want {
+		t.Errorf("input = %+v, want %+v", *ns[0], want)
+	}
+	if want := (negotiation{FormatFloat32LE, 96000, 0, 0.05}); *ns[1] != want {
+		t.Errorf("output = %+v, want %+v", *ns[1], want)
+	}
+}
+
+func TestNearestSampleRate(t *testing.T) {
+	ranges := []SampleRateRange{{min: 44100, max: 44100}, {min: 48000, max: 96000}}
+	tests := []struct {
+		rate int
+		want int
+	}{
+		{8000, 44100},
+		{44100, 44100},
+		{46000, 48000},
+		{50000, 50000},
+		{192000, 96000},
+	}
+	for _, test := range tests {
+		if got := nearestSampleRate(ranges, test.rate); got != test.want {
+			t.Errorf("nearestSampleRate(%d) = %d, want %d", test.rate, got, test.want)
+		}
+	}
+}
@@ -1,3 +1,5 @@
+//go:build cgo
+
 package soundio
 
 // Option is SoundIo option function.
@@ -14,53 +14,60 @@ package soundio
 */
 import "C"
 import (
+	"context"
+	"runtime/cgo"
+	"sync/atomic"
 	"unsafe"
+	"weak"
 )
 
 // OutStream is Output Stream.
 type OutStream struct {
 	p                 uintptr
+	handle            cgo.Handle
 	d                 *Device
+	key               int
 	writeCallback     func(*OutStream, int, int)
 	underflowCallback func(*OutStream)
 	errorCallback     func(*OutStream, error)
-}
-
-// OutStreamConfig is config of output stream.
-type OutStreamConfig struct {
-	// Format
-	Format Format
-	// SampleRate
-	SampleRate int
-	// Layout
-	Layout *ChannelLayout
-	// SoftwareLatency
-	SoftwareLatency float64
-	// Name
-	Name string
+	endWriteHook      func(*ChannelAreas)
+	stopRun           atomic.Pointer[context.CancelCauseFunc]
+	layout            ChannelLayout
+	areas             ChannelAreas
+	// out parameters of begin write live in the stream, so that they do not escape to the heap.
+	nativeAreas      *C.struct_SoundIoChannelArea
+	nativeFrameCount C.int
+	// writeing is whether areas are in use between BeginWrite and EndWrite.
+	writeing atomic.Bool
 }
 
 //export outstreamWriteCallbackDelegate
 func outstreamWriteCallbackDelegate(nativeStream *C.struct_SoundIoOutStream, frameCountMin C.int, frameCountMax C.int) {
-	stream := (*OutStream)(nativeStream.userdata)
-	if stream.writeCallback != nil {
+	stream, ok := handleValue[*OutStream](nativeStream.userdata)
+	if ok && stream.writeCallback != nil {
 		stream.writeCallback(stream, int(frameCountMin), int(frameCountMax))
 	}
 }
 
 //export outstreamUnderflowCallbackDelegate
 func outstreamUnderflowCallbackDelegate(nativeStream *C.struct_SoundIoOutStream) {
-	stream := (*OutStream)(nativeStream.userdata)
-	if stream.underflowCallback != nil {
+	stream, ok := handleValue[*OutStream](nativeStream.userdata)
+	if ok && stream.underflowCallback != nil {
 		stream.underflowCallback(stream)
 	}
 }
 
 //export outstreamErrorCallbackDelegate
 func outstreamErrorCallbackDelegate(nativeStream *C.struct_SoundIoOutStream, err C.int) {
-	stream := (*OutStream)(nativeStream.userdata)
+	stream, ok := handleValue[*OutStream](nativeStream.userdata)
+	if !ok {
+		return
+	}
 	if stream.errorCallback != nil {
-		stream.errorCallback(stream, convertToError(err))
+		stream.errorCallback(stream, stream.opError("stream", convertToError(err)))
+	}
+	if stop := stream.stopRun.Load(); stop != nil {
+		(*stop)(stream.opError("stream", convertToError(err)))
 	}
 }
 
@@ -74,42 +81,59 @@ func (s *OutStream) Device() *Device {
 // Format returns format of stream.
 func (s *OutStream) Format() Format {
 	p := s.cptr()
+	if p == nil {
+		return FormatInvalid
+	}
 	return Format(p.format)
 }
 
 // SampleRate returns sample rate of stream.
 func (s *OutStream) SampleRate() int {
 	p := s.cptr()
+	if p == nil {
+		return 0
+	}
 	return int(p.sample_rate)
 }
 
 // Layout returns layout of stream.
 func (s *OutStream) Layout() *ChannelLayout {
-	p := s.cptr()
-	return newChannelLayout(&p.layout)
+	return &s.layout
 }
 
 // SoftwareLatency returns software latency of stream.
 func (s *OutStream) SoftwareLatency() float64 {
 	p := s.cptr()
+	if p == nil {
+		return 0
+	}
 	return float64(p.software_latency)
 }
 
 // Volume returns volume of stream.
 func (s *OutStream) Volume() float32 {
 	p := s.cptr()
+	if p == nil {
+		return 0
+	}
 	return float32(p.volume)
 }
 
 // SetVolume sets volume of stream.
 func (s *OutStream) SetVolume(volume float64) error {
 	p := s.cptr()
-	return convertToError(C.soundio_outstream_set_volume(p, C.double(volume)))
+	if p == nil {
+		return ErrClosed
+	}
+	return s.opError("set volume", convertToError(C.soundio_outstream_set_volume(p, C.double(volume))))
 }
 
 // Name returns name of stream.
 func (s *OutStream) Name() string {
 	p := s.cptr()
+	if p == nil {
+		return ""
+	}
 	return C.GoString(p.name)
 }
 
@@ -118,18 +142,27 @@ func (s *OutStream) Name() string {
 // stream. Defaults to `false`.
 func (s *OutStream) NonTerminalHint() bool {
 	p := s.cptr()
+	if p == nil {
+		return false
+	}
 	return bool(p.non_terminal_hint)
 }
 
 // BytesPerFrame returns bytes per frame.
 func (s *OutStream) BytesPerFrame() int {
 	p := s.cptr()
+	if p == nil {
+		return 0
+	}
 	return int(p.bytes_per_frame)
 }
 
 // BytesPerSample returns bytes per sample.
 func (s *OutStream) BytesPerSample() int {
 	p := s.cptr()
+	if p == nil {
+		return 0
+	}
 	return int(p.bytes_per_sample)
 }
 
@@ -138,6 +171,9 @@ func (s *OutStream) BytesPerSample() int {
 // * SoundIoErrorIncompatibleDevice
 func (s *OutStream) LayoutError() error {
 	p := s.cptr()
+	if p == nil {
+		return ErrClosed
+	}
 	return convertToError(p.layout_error)
 }
 
@@ -158,75 +194,141 @@ func (s *OutStream) SetErrorCallback(callback func(stream *OutStream, err error)
 
 // functions
 
+// Close stops the stream and releases resources.
+// It must not be called from the stream callbacks.
+// Calling Close more than once does nothing, and other methods return
+// ErrClosed or zero values after Close.
+func (s *OutStream) Close() error {
+	p := s.cptr()
+	if p == nil {
+		return nil
+	}
+	C.free(unsafe.Pointer(p.name))
+	C.soundio_outstream_destroy(p)
+	// the callbacks have returned, so areas are in use only when EndWrite was not called.
+	if s.writeing.Swap(false) {
+		s.areas.invalidate()
+	}
+	s.p = 0
+	deleteHandle(&s.handle)
+	s.d.io.tracker.untrack(s.key)
+	return nil
+}
+
 // Destroy releases resources.
+//
+// Deprecated: Use Close.
 func (s *OutStream) Destroy() {
-	p := s.cptr()
-	if p != nil {
-		C.free(unsafe.Pointer(p.name))
-		C.soundio_outstream_destroy(p)
-		s.p = 0
+	_ = s.Close()
+}
+
+// Run starts playback, and waits events of the SoundIo until ctx is done or
+// the stream reports an error. It returns the stream error, or the cause of
+// ctx being canceled, so a WriteCallback can stop Run by canceling ctx with
+// context.WithCancelCause. The error callback is still called.
+// The stream is paused when Run returns, and Close still releases it.
+// FlushEvents and WaitEvents must not be called while running.
+//
+// Possible errors:
+// * ErrClosed
+// * errors of Start
+func (s *OutStream) Run(ctx context.Context) error {
+	if s.cptr() == nil {
+		return ErrClosed
 	}
+	return s.d.io.runStream(ctx, s.Start, s.Pause, &s.stopRun)
 }
 
 // Start starts playback.
 // After you call this function, WriteCallback will be called.
 func (s *OutStream) Start() error {
 	p := s.cptr()
-	return convertToError(C.soundio_outstream_start(p))
+	if p == nil {
+		return ErrClosed
+	}
+	return s.opError("start", convertToError(C.soundio_outstream_start(p)))
 }
 
 // BeginWrite called when you are ready to begin writing to the device buffer.
 func (s *OutStream) BeginWrite(frameCount *int) (*ChannelAreas, error) {
 	p := s.cptr()
-	var ptrs *C.struct_SoundIoChannelArea
-	nativeFrameCount := C.int(*frameCount)
-	err := convertToError(C.soundio_outstream_begin_write(p, &ptrs, &nativeFrameCount))
-	*frameCount = int(nativeFrameCount)
+	if p == nil {
+		return nil, ErrClosed
+	}
+	s.nativeFrameCount = C.int(*frameCount)
+	err := convertToError(C.soundio_outstream_begin_write(p, &s.nativeAreas, &s.nativeFrameCount))
+	*frameCount = int(s.nativeFrameCount)
 	if err != nil {
-		return nil, err
+		return nil, s.opError("begin write", err)
 	}
-	if ptrs == nil {
+	if s.nativeAreas == nil {
 		return nil, nil
 	}
-	return newChannelAreas(ptrs, s.Format(), s.Layout().ChannelCount(), *frameCount), nil
+	s.areas.reset(s.nativeAreas, Format(p.format), int(p.bytes_per_sample), int(p.layout.channel_count), *frameCount)
+	s.writeing.Store(true)
+	return &s.areas, nil
 }
 
 // EndWrite commits the write that you began with BeginWrite.
 func (s *OutStream) EndWrite() error {
 	p := s.cptr()
-	return convertToError(C.soundio_outstream_end_write(p))
+	if p == nil {
+		return ErrClosed
+	}
+	if s.endWriteHook != nil && s.nativeAreas != nil {
+		s.endWriteHook(&s.areas)
+	}
+	s.areas.invalidate()
+	s.writeing.Store(false)
+	return s.opError("end write", convertToError(C.soundio_outstream_end_write(p)))
 }
 
 // ClearBuffer clears the output stream buffer.
 func (s *OutStream) ClearBuffer() error {
 	p := s.cptr()
-	return convertToError(C.soundio_outstream_clear_buffer(p))
+	if p == nil {
+		return ErrClosed
+	}
+	return s.opError("clear buffer", convertToError(C.soundio_outstream_clear_buffer(p)))
 }
 
 // Pause pauses the stream If the underlying backend and device support pausing.
 func (s *OutStream) Pause(pause bool) error {
 	p := s.cptr()
-	return convertToError(C.soundio_outstream_pause(p, C.bool(pause)))
+	if p == nil {
+		return ErrClosed
+	}
+	return s.opError("pause", convertToError(C.soundio_outstream_pause(p, C.bool(pause))))
 }
 
 // Latency returns the total number of seconds that the next frame written after the
 // last frame written with EndWrite will take to become audible.
 func (s *OutStream) Latency(outLatency float64) (float64, error) {
 	p := s.cptr()
+	if p == nil {
+		return 0, ErrClosed
+	}
 	latency := C.double(outLatency)
 	err := convertToError(C.soundio_outstream_get_latency(p, &latency))
-	return float64(latency), err
+	return float64(latency), s.opError("latency", err)
 }
 
 func newOutStream(d *Device, config *OutStreamConfig) (*OutStream, error) {
+	if d.released() {
+		return nil, ErrClosed
+	}
+	if config.Layout != nil && config.Layout.ChannelCount() > MaxChannels {
+		return nil, d.opError("open out stream", config, ErrorInvalid)
+	}
 	p := C.soundio_outstream_create(d.cptr())
 	s := &OutStream{
 		p: uintptr(unsafe.Pointer(p)),
 		d: d,
 	}
 
-	p.userdata = unsafe.Pointer(s)
-	C.setOutStreamCallback(p)
+	var userdata C.uintptr_t
+	s.handle, userdata = newHandle(s)
+	C.setOutStreamCallback(p, userdata)
 
 	// set config values
 	if config.Format != FormatInvalid {
@@ -236,7 +338,7 @@ func newOutStream(d *Device, config *OutStreamConfig) (*OutStream, error) {
 		p.sample_rate = C.int(config.SampleRate)
 	}
 	if config.Layout != nil {
-		C.memcpy(unsafe.Pointer(&p.layout), unsafe.Pointer(config.Layout.cptr()), C.sizeof_struct_SoundIoChannelLayout)
+		config.Layout.copyTo(&p.layout)
 	}
 	if config.SoftwareLatency > 0.0 {
 		p.software_latency = C.double(config.SoftwareLatency)
@@ -247,13 +349,38 @@ func newOutStream(d *Device, config *OutStreamConfig) (*OutStream, error) {
 
 	err := convertToError(C.soundio_outstream_open(p))
 	if err != nil {
+		C.free(unsafe.Pointer(p.name))
 		C.soundio_outstream_destroy(p)
-		return nil, err
+		deleteHandle(&s.handle)
+		return nil, d.opError("open out stream", config, err)
 	}
+	s.layout = *newChannelLayout(&p.layout)
+
+	w := weak.Make(s)
+	s.key = d.io.tracker.track("out stream", C.GoString(p.name), creationStack(), func() {
+		if s := w.Value(); s != nil {
+			_ = s.Close()
+		}
+	})
 
 	return s, nil
 }
 
+// opError wraps err with the device and the parameters of the stream.
+func (s *OutStream) opError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	layout := s.layout
+	return s.d.opError(op, &OutStreamConfig{
+		Format:          s.Format(),
+		SampleRate:      s.SampleRate(),
+		Layout:          &layout,
+		SoftwareLatency: s.SoftwareLatency(),
+		Name:            s.Name(),
+	}, err)
+}
+
 func (s *OutStream) cptr() *C.struct_SoundIoOutStream {
 	if s.p == 0 {
 		return nil
@@ -0,0 +1,115 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const allocRuns = 10
+
+func TestOutStreamBeginWriteAllocs(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	defer device.RemoveReference()
+
+	stream, err := device.NewOutStream(&OutStreamConfig{})
+	if err != nil {
+		t.Fatalf("unable to open output stream: %s", err)
+	}
+	defer stream.Close()
+
+	var once sync.Once
+	result := make(chan float64, 1)
+	stream.SetWriteCallback(func(stream *OutStream, frameCountMin int, frameCountMax int) {
+		if frameCountMax <= allocRuns {
+			return
+		}
+		once.Do(func() {
+			result <- testing.AllocsPerRun(allocRuns, func() {
+				frameCount := 1
+				areas, err := stream.BeginWrite(&frameCount)
+				if err != nil || areas == nil {
+					return
+				}
+				for ch := 0; ch < stream.Layout().ChannelCount(); ch++ {
+					areas.WriteFloat32(ch, 0, 0)
+				}
+				_ = stream.EndWrite()
+			})
+		})
+	})
+	if err := stream.Start(); err != nil {
+		t.Fatalf("unable to start output stream: %s", err)
+	}
+
+	select {
+	case allocs := <-result:
+		if allocs != 0 {
+			t.Errorf("BeginWrite allocates %.1f times per callback, want 0", allocs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("write callback was not called")
+	}
+}
+
+func TestOutStreamRun(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	defer device.RemoveReference()
+
+	stream, err := device.NewOutStream(&OutStreamConfig{})
+	if err != nil {
+		t.Fatalf("unable to open output stream: %s", err)
+	}
+	defer stream.Close()
+
+	errStop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	var calls atomic.Int64
+	stream.SetWriteCallback(func(stream *OutStream, frameCountMin int, frameCountMax int) {
+		calls.Add(1)
+		cancel(errStop)
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stream.Run(ctx)
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errStop) {
+			t.Errorf("Run() = %v, want %v", err, errStop)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+
+	// the stream is paused, after a callback that raced with pausing.
+	time.Sleep(20 * time.Millisecond)
+	paused := calls.Load()
+	time.Sleep(100 * time.Millisecond)
+	if n := calls.Load() - paused; n != 0 {
+		t.Errorf("write callback was called %d times after Run returned", n)
+	}
+
+	if err := stream.Close(); err != nil {
+		t.Errorf("unable to close stream: %s", err)
+	}
+	if err := stream.Run(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Run() after Close = %v, want ErrClosed", err)
+	}
+}
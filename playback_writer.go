@@ -0,0 +1,252 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PlaybackConfig is config of PlaybackWriter.
+type PlaybackConfig struct {
+	// Stream is used to open the output stream.
+	Stream OutStreamConfig
+	// Format of PCM passed to Write.
+	// Defaults to the format of the stream.
+	Format Format
+	// Capacity of the buffer in frames.
+	// Defaults to one second.
+	Capacity int
+}
+
+// PlaybackWriter plays interleaved PCM on an output device.
+// It is not safe for concurrent use by multiple goroutines.
+type PlaybackWriter struct {
+	stream     *OutStream
+	format     Format
+	codec      *sampleCodec
+	buffer     *ringBuffer
+	frame      []byte
+	notify     chan struct{}
+	done       chan struct{}
+	doneOnce   sync.Once
+	closeOnce  sync.Once
+	closed     atomic.Bool
+	underflows atomic.Int64
+	err        atomic.Pointer[error]
+}
+
+// NewPlaybackWriter opens an output stream on device and starts playing.
+// Silence is played until the first Write. Call Close when done.
+func NewPlaybackWriter(device *Device, config *PlaybackConfig) (*PlaybackWriter, error) {
+	stream, err := device.NewOutStream(&config.Stream)
+	if err != nil {
+		return nil, err
+	}
+
+	format := config.Format
+	if format == FormatInvalid {
+		format = stream.Format()
+	}
+	codec := codecOf(format)
+	if codec.size == 0 {
+		_ = stream.Close()
+		return nil, ErrorInvalid
+	}
+	capacity := config.Capacity
+	if capacity <= 0 {
+		capacity = stream.SampleRate()
+	}
+	frameBytes := codec.size * stream.Layout().ChannelCount()
+
+	w := &PlaybackWriter{
+		stream: stream,
+		format: format,
+		codec:  codec,
+		buffer: newRingBuffer(capacity * frameBytes),
+		frame:  make([]byte, frameBytes),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	stream.SetWriteCallback(w.writeCallback)
+	stream.SetUnderflowCallback(func(*OutStream) {
+		w.underflows.Add(1)
+	})
+	stream.SetErrorCallback(func(_ *OutStream, err error) {
+		w.fail(err)
+	})
+
+	if err := stream.Start(); err != nil {
+		_ = stream.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Stream returns the output stream being played.
+func (w *PlaybackWriter) Stream() *OutStream {
+	return w.stream
+}
+
+// Format returns format of PCM passed to Write.
+func (w *PlaybackWriter) Format() Format {
+	return w.format
+}
+
+// Underflows returns how many times the device ran out of audio.
+func (w *PlaybackWriter) Underflows() int {
+	return int(w.underflows.Load())
+}
+
+// Write buffers interleaved PCM, blocking while the buffer is full.
+func (w *PlaybackWriter) Write(p []byte) (int, error) {
+	if w.closed.Load() {
+		return 0, io.ErrClosedPipe
+	}
+	written := 0
+	for written < len(p) {
+		if err := w.streamErr(); err != nil {
+			return written, err
+		}
+		if n := w.buffer.Write(p[written:]); n > 0 {
+			written += n
+			continue
+		}
+
+		select {
+		case <-w.notify:
+		case <-w.done:
+			if err := w.streamErr(); err != nil {
+				return written, err
+			}
+			return written, io.ErrClosedPipe
+		}
+	}
+	return written, nil
+}
+
+// Close waits until all buffered audio has been played, then releases
+// the output stream. It blocks while the stream is paused.
+func (w *PlaybackWriter) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		w.closed.Store(true)
+		err = w.drain()
+		_ = w.stream.Close()
+		w.closeDone()
+	})
+	return err
+}
+
+// drain waits until the buffer is empty and the last frame is audible.
+func (w *PlaybackWriter) drain() error {
+	for w.buffer.Readable() >= len(w.frame) {
+		select {
+		case <-w.notify:
+		case <-w.done:
+			return w.streamErr()
+		}
+	}
+
+	latency, err := w.stream.Latency(0)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-time.After(time.Duration(latency * float64(time.Second))):
+	case <-w.done:
+	}
+	return w.streamErr()
+}
+
+func (w *PlaybackWriter) closeDone() {
+	w.doneOnce.Do(func() {
+		close(w.done)
+	})
+}
+
+// fail records the first stream error, and wakes up Write.
+func (w *PlaybackWriter) fail(err error) {
+	w.err.CompareAndSwap(nil, &err)
+	w.closeDone()
+}
+
+func (w *PlaybackWriter) streamErr() error {
+	if err := w.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// writeCallback plays buffered frames, and fills with silence when the
+// buffer cannot satisfy frameCountMin.
+func (w *PlaybackWriter) writeCallback(stream *OutStream, frameCountMin int, frameCountMax int) {
+	sameFormat := stream.Format() == w.format
+	channelCount := stream.Layout().ChannelCount()
+	available := w.buffer.Readable() / len(w.frame)
+	frameLeft := min(frameCountMax, max(frameCountMin, available))
+	consumed := false
+
+	for frameLeft > 0 {
+		frameCount := frameLeft
+		areas, err := stream.BeginWrite(&frameCount)
+		if err != nil {
+			w.fail(err)
+			return
+		}
+		if frameCount <= 0 {
+			break
+		}
+
+		for frame := 0; frame < frameCount; frame++ {
+			if available > 0 {
+				w.buffer.Read(w.frame)
+				w.decodeFrame(areas, frame, channelCount, sameFormat)
+				available--
+				consumed = true
+			} else {
+				for ch := 0; ch < channelCount; ch++ {
+					areas.Sample(ch, frame).SetFloat64(0)
+				}
+			}
+		}
+
+		if err := stream.EndWrite(); err != nil {
+			w.fail(err)
+			return
+		}
+		frameLeft -= frameCount
+	}
+
+	if consumed {
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *PlaybackWriter) decodeFrame(areas *ChannelAreas, frame int, channelCount int, sameFormat bool) {
+	offset := 0
+	for ch := 0; ch < channelCount; ch++ {
+		sample := areas.Sample(ch, frame)
+		if sameFormat {
+			copy(sample.Bytes(), w.frame[offset:])
+		} else {
+			convertSample(sample.Bytes(), sample.area.codec, w.frame[offset:], w.codec)
+		}
+		offset += w.codec.size
+	}
+}
+
+var _ io.WriteCloser = (*PlaybackWriter)(nil)
@@ -0,0 +1,148 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPlaybackWriterSilence(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	defer device.Close()
+
+	stream, err := device.NewOutStream(&OutStreamConfig{})
+	if err != nil {
+		t.Fatalf("unable to open output stream: %s", err)
+	}
+	defer stream.Close()
+	frameBytes := 4 * stream.Layout().ChannelCount()
+	w := &PlaybackWriter{
+		stream: stream,
+		format: FormatFloat32NE,
+		codec:  codecOf(FormatFloat32NE),
+		buffer: newRingBuffer(64 * frameBytes),
+		frame:  make([]byte, frameBytes),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	// the device buffer is filled with a tone first, so that frames the
+	// writer leaves alone are not silent.
+	filled := false
+	var silentFrames atomic.Int64
+	var loud atomic.Bool
+	stream.SetWriteCallback(func(stream *OutStream, frameCountMin int, frameCountMax int) {
+		if filled {
+			// the device needs every frame, which the empty writer does not have.
+			w.writeCallback(stream, frameCountMax, frameCountMax)
+			return
+		}
+		for frameLeft := frameCountMax; frameLeft > 0; {
+			frameCount := frameLeft
+			areas, err := stream.BeginWrite(&frameCount)
+			if err != nil || frameCount <= 0 {
+				break
+			}
+			for frame := 0; frame < frameCount; frame++ {
+				for ch := 0; ch < areas.ChannelCount(); ch++ {
+					areas.WriteFloat32(ch, frame, 0.5)
+				}
+			}
+			_ = stream.EndWrite()
+			frameLeft -= frameCount
+		}
+		filled = true
+	})
+	stream.endWriteHook = func(areas *ChannelAreas) {
+		if !filled {
+			return
+		}
+		for frame := 0; frame < areas.FrameCount(); frame++ {
+			for ch := 0; ch < areas.ChannelCount(); ch++ {
+				if areas.ReadFloat32(ch, frame) != 0 {
+					loud.Store(true)
+				}
+			}
+		}
+		silentFrames.Add(int64(areas.FrameCount()))
+	}
+
+	if err := stream.Start(); err != nil {
+		t.Fatalf("unable to start output stream: %s", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); silentFrames.Load() < int64(stream.SampleRate()/10); {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d silent frames were written", silentFrames.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if loud.Load() {
+		t.Error("writer did not write silence when starved")
+	}
+}
+
+func TestPlaybackWriterUnderflow(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	defer device.Close()
+
+	w, err := NewPlaybackWriter(device, &PlaybackConfig{Format: FormatS16LE})
+	if err != nil {
+		t.Fatalf("unable to open playback writer: %s", err)
+	}
+	defer w.Close()
+
+	frameBytes := 2 * w.Stream().Layout().ChannelCount()
+	if _, err := w.Write(make([]byte, w.Stream().SampleRate()/50*frameBytes)); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); w.Underflows() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("no underflow was counted after starving the device")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPlaybackWriterCloseDrains(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	defer device.Close()
+
+	w, err := NewPlaybackWriter(device, &PlaybackConfig{Format: FormatS16LE})
+	if err != nil {
+		t.Fatalf("unable to open playback writer: %s", err)
+	}
+
+	const played = 400 * time.Millisecond
+	frameBytes := 2 * w.Stream().Layout().ChannelCount()
+	frames := int(played.Seconds() * float64(w.Stream().SampleRate()))
+	start := time.Now()
+	if _, err := w.Write(make([]byte, frames*frameBytes)); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	// the device buffer plays the last frames while Close waits its latency.
+	if elapsed := time.Since(start); elapsed < played*3/4 {
+		t.Errorf("Close() returned after %v, before %v of audio was played", elapsed, played)
+	}
+	if readable := w.buffer.Readable(); readable != 0 {
+		t.Errorf("%d bytes were not played", readable)
+	}
+	if _, err := w.Write(make([]byte, frameBytes)); err != io.ErrClosedPipe {
+		t.Errorf("Write() after Close = %v, want io.ErrClosedPipe", err)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Leak is a device or stream that was still referenced when SoundIo was closed.
+type Leak struct {
+	// Kind is "device", "in stream" or "out stream".
+	Kind string
+	// Name is the name of the device or stream.
+	Name string
+	// Stack is the stack trace where it was created.
+	// It is only recorded when built with the soundio_debug tag.
+	Stack string
+}
+
+func (l Leak) String() string {
+	s := fmt.Sprintf("%s %q", l.Kind, l.Name)
+	if l.Stack != "" {
+		s += " created at\n" + l.Stack
+	}
+	return s
+}
+
+// LeakError is returned by SoundIo.Close when devices or streams were still referenced.
+type LeakError struct {
+	Leaks []Leak
+}
+
+func (e *LeakError) Error() string {
+	leaks := make([]string, len(e.Leaks))
+	for i, l := range e.Leaks {
+		leaks[i] = l.String()
+	}
+	return fmt.Sprintf("soundio: %d leaked references: %s", len(e.Leaks), strings.Join(leaks, ", "))
+}
+
+// trackedRef is an outstanding device or stream.
+type trackedRef struct {
+	leak    Leak
+	release func()
+}
+
+// refTracker records the outstanding devices and streams of a SoundIo.
+// Devices and streams keep the SoundIo alive, while the tracker refers to
+// them weakly, so that they can be finalized before the SoundIo.
+type refTracker struct {
+	mu   sync.Mutex
+	next int
+	refs map[int]trackedRef
+}
+
+// track records a reference, and returns the key to untrack it with.
+// release is called for a reference that is still outstanding on close.
+func (t *refTracker) track(kind string, name string, stack string, release func()) int {
+	ref := trackedRef{
+		leak:    Leak{Kind: kind, Name: name, Stack: stack},
+		release: release,
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.refs == nil {
+		t.refs = make(map[int]trackedRef)
+	}
+	t.next++
+	t.refs[t.next] = ref
+	return t.next
+}
+
+// setRelease replaces the release function of an outstanding reference.
+func (t *refTracker) setRelease(key int, release func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ref, ok := t.refs[key]; ok {
+		ref.release = release
+		t.refs[key] = ref
+	}
+}
+
+func (t *refTracker) untrack(key int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.refs, key)
+}
+
+// close releases the outstanding references, streams first,
+// and returns them in the order they were created.
+func (t *refTracker) close() []Leak {
+	t.mu.Lock()
+	keys := make([]int, 0, len(t.refs))
+	for key := range t.refs {
+		keys = append(keys, key)
+	}
+	refs := t.refs
+	t.refs = nil
+	t.mu.Unlock()
+
+	slices.Sort(keys)
+	leaks := make([]Leak, len(keys))
+	for i, key := range keys {
+		leaks[i] = refs[key].leak
+	}
+	for _, streams := range []bool{true, false} {
+		for _, key := range keys {
+			ref := refs[key]
+			if (ref.leak.Kind != "device") == streams && ref.release != nil {
+				ref.release()
+			}
+		}
+	}
+	return leaks
+}
@@ -0,0 +1,25 @@
+//go:build cgo && soundio_debug
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// creationStack returns the current stack trace.
+func creationStack() string {
+	return string(debug.Stack())
+}
+
+// releasedDeviceUsed reports a device that is referenced or unreferenced after it was released.
+func releasedDeviceUsed(d *Device, op string) {
+	panic(fmt.Sprintf("soundio: device %q %s after it was released, created at\n%s", d.name, op, d.stack))
+}
@@ -0,0 +1,18 @@
+//go:build cgo && !soundio_debug
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+// creationStack returns an empty stack trace, unless built with the soundio_debug tag.
+func creationStack() string {
+	return ""
+}
+
+// releasedDeviceUsed ignores a device that is referenced or unreferenced after it was released.
+func releasedDeviceUsed(*Device, string) {}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRefTrackerClose(t *testing.T) {
+	var tracker refTracker
+	var released []string
+	track := func(kind string, name string) int {
+		return tracker.track(kind, name, "", func() {
+			released = append(released, name)
+		})
+	}
+	track("device", "speaker")
+	mic := track("device", "mic")
+	track("out stream", "music")
+	tracker.untrack(mic)
+
+	leaks := tracker.close()
+	want := []Leak{{Kind: "device", Name: "speaker"}, {Kind: "out stream", Name: "music"}}
+	if !slices.Equal(leaks, want) {
+		t.Errorf("leaks = %v, want %v", leaks, want)
+	}
+	if want := []string{"music", "speaker"}; !slices.Equal(released, want) {
+		t.Errorf("released = %v, want %v", released, want)
+	}
+	if leaks := tracker.close(); len(leaks) != 0 {
+		t.Errorf("leaks after close = %v", leaks)
+	}
+}
+
+func TestLeakError(t *testing.T) {
+	err := &LeakError{Leaks: []Leak{
+		{Kind: "device", Name: "speaker"},
+		{Kind: "in stream", Name: "mic", Stack: "main.main()"},
+	}}
+	msg := err.Error()
+	for _, s := range []string{"2 leaked references", `device "speaker"`, `in stream "mic" created at`, "main.main()"} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("%q does not contain %q", msg, s)
+		}
+	}
+}
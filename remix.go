@@ -0,0 +1,247 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import "math"
+
+// RemixOption is an option of NewRemixer.
+type RemixOption func(*remixConfig)
+
+type remixConfig struct {
+	lfeGain   float32
+	normalize bool
+}
+
+// WithLFEGain mixes the LFE channel into the front channels with gain
+// when the destination layout has no LFE channel. By default it is dropped.
+func WithLFEGain(gain float32) RemixOption {
+	return func(c *remixConfig) {
+		c.lfeGain = gain
+	}
+}
+
+// WithNormalize scales the matrix so that no output channel sums more than
+// unit gain, which avoids clipping at the cost of a quieter mix.
+func WithNormalize() RemixOption {
+	return func(c *remixConfig) {
+		c.normalize = true
+	}
+}
+
+// Remixer maps interleaved float32 frames from one channel layout to another
+// through a matrix of gains. Process does not allocate, so it can be used in
+// stream callbacks.
+type Remixer struct {
+	inChannels  int
+	outChannels int
+	matrix      []float32 // outChannels rows of inChannels gains
+}
+
+// remixRoute sends a channel missing from the destination to all of to with gain.
+type remixRoute struct {
+	to   []ChannelID
+	gain float32
+}
+
+const minus3dB = float32(math.Sqrt2 / 2)
+
+// remixRoutes lists, in order of preference, where a source channel goes
+// when the destination layout does not have it. Gains follow ITU-R BS.775.
+var remixRoutes = map[ChannelID][]remixRoute{
+	ChannelIDFrontLeft:        {{[]ChannelID{ChannelIDFrontCenter}, minus3dB}},
+	ChannelIDFrontRight:       {{[]ChannelID{ChannelIDFrontCenter}, minus3dB}},
+	ChannelIDFrontCenter:      {{[]ChannelID{ChannelIDFrontLeft, ChannelIDFrontRight}, minus3dB}},
+	ChannelIDFrontLeftCenter:  {{[]ChannelID{ChannelIDFrontLeft}, 1}, {[]ChannelID{ChannelIDFrontCenter}, minus3dB}},
+	ChannelIDFrontRightCenter: {{[]ChannelID{ChannelIDFrontRight}, 1}, {[]ChannelID{ChannelIDFrontCenter}, minus3dB}},
+	ChannelIDFrontLeftWide:    {{[]ChannelID{ChannelIDFrontLeft}, 1}, {[]ChannelID{ChannelIDFrontCenter}, minus3dB}},
+	ChannelIDFrontRightWide:   {{[]ChannelID{ChannelIDFrontRight}, 1}, {[]ChannelID{ChannelIDFrontCenter}, minus3dB}},
+	ChannelIDFrontLeftHigh:    {{[]ChannelID{ChannelIDFrontLeft}, 1}, {[]ChannelID{ChannelIDFrontCenter}, minus3dB}},
+	ChannelIDFrontRightHigh:   {{[]ChannelID{ChannelIDFrontRight}, 1}, {[]ChannelID{ChannelIDFrontCenter}, minus3dB}},
+	ChannelIDFrontCenterHigh:  {{[]ChannelID{ChannelIDFrontCenter}, 1}, {[]ChannelID{ChannelIDFrontLeft, ChannelIDFrontRight}, minus3dB}},
+	ChannelIDSideLeft: {
+		{[]ChannelID{ChannelIDBackLeft}, 1},
+		{[]ChannelID{ChannelIDFrontLeft}, minus3dB},
+		{[]ChannelID{ChannelIDFrontCenter}, 0.5},
+	},
+	ChannelIDSideRight: {
+		{[]ChannelID{ChannelIDBackRight}, 1},
+		{[]ChannelID{ChannelIDFrontRight}, minus3dB},
+		{[]ChannelID{ChannelIDFrontCenter}, 0.5},
+	},
+	ChannelIDBackLeft: {
+		{[]ChannelID{ChannelIDSideLeft}, 1},
+		{[]ChannelID{ChannelIDFrontLeft}, minus3dB},
+		{[]ChannelID{ChannelIDFrontCenter}, 0.5},
+	},
+	ChannelIDBackRight: {
+		{[]ChannelID{ChannelIDSideRight}, 1},
+		{[]ChannelID{ChannelIDFrontRight}, minus3dB},
+		{[]ChannelID{ChannelIDFrontCenter}, 0.5},
+	},
+	ChannelIDBackCenter: {
+		{[]ChannelID{ChannelIDBackLeft, ChannelIDBackRight}, minus3dB},
+		{[]ChannelID{ChannelIDSideLeft, ChannelIDSideRight}, minus3dB},
+		{[]ChannelID{ChannelIDFrontLeft, ChannelIDFrontRight}, 0.5},
+		{[]ChannelID{ChannelIDFrontCenter}, minus3dB},
+	},
+	ChannelIDHeadphonesLeft:  {{[]ChannelID{ChannelIDFrontLeft}, 1}, {[]ChannelID{ChannelIDFrontCenter}, minus3dB}},
+	ChannelIDHeadphonesRight: {{[]ChannelID{ChannelIDFrontRight}, 1}, {[]ChannelID{ChannelIDFrontCenter}, minus3dB}},
+}
+
+// NewRemixer returns a Remixer from the src layout to the dst layout.
+// Channels present in both layouts pass through unchanged, and the others are
+// folded into their nearest neighbours: a 5.1 source becomes stereo as
+// L = FL + 0.707 FC + 0.707 SL, and a mono source is copied to both sides of
+// a stereo destination. Channels without a neighbour, such as Aux, are dropped.
+func NewRemixer(src *ChannelLayout, dst *ChannelLayout, opts ...RemixOption) (*Remixer, error) {
+	if src == nil || dst == nil {
+		return nil, ErrorInvalid
+	}
+	return newRemixer(src.channels, dst.channels, opts...)
+}
+
+// NewRemixerMatrix returns a Remixer with a custom matrix of outChannels rows
+// of inChannels gains, so matrix[out*inChannels+in] is the gain from input
+// channel in to output channel out.
+func NewRemixerMatrix(inChannels int, outChannels int, matrix []float32) (*Remixer, error) {
+	if inChannels <= 0 || outChannels <= 0 || len(matrix) != inChannels*outChannels {
+		return nil, ErrorInvalid
+	}
+	return &Remixer{
+		inChannels:  inChannels,
+		outChannels: outChannels,
+		matrix:      append([]float32(nil), matrix...),
+	}, nil
+}
+
+func newRemixer(src []ChannelID, dst []ChannelID, opts ...RemixOption) (*Remixer, error) {
+	if len(src) == 0 || len(dst) == 0 {
+		return nil, ErrorInvalid
+	}
+	var config remixConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	r := &Remixer{
+		inChannels:  len(src),
+		outChannels: len(dst),
+		matrix:      make([]float32, len(src)*len(dst)),
+	}
+	find := func(id ChannelID) int {
+		for i, c := range dst {
+			if c == id {
+				return i
+			}
+		}
+		return -1
+	}
+	// send adds gain from input channel in to every channel of to,
+	// and reports false when one of them is missing.
+	send := func(in int, to []ChannelID, gain float32) bool {
+		for _, id := range to {
+			if find(id) < 0 {
+				return false
+			}
+		}
+		for _, id := range to {
+			r.matrix[find(id)*r.inChannels+in] += gain
+		}
+		return true
+	}
+
+	mono := len(src) == 1
+	for in, id := range src {
+		if out := find(id); out >= 0 {
+			r.matrix[out*r.inChannels+in] = 1
+			continue
+		}
+		if mono && send(in, []ChannelID{ChannelIDFrontLeft, ChannelIDFrontRight}, 1) {
+			continue
+		}
+		if id == ChannelIDLfe {
+			if config.lfeGain != 0 && !send(in, []ChannelID{ChannelIDFrontLeft, ChannelIDFrontRight}, config.lfeGain) {
+				send(in, []ChannelID{ChannelIDFrontCenter}, config.lfeGain)
+			}
+			continue
+		}
+		for _, route := range remixRoutes[id] {
+			if send(in, route.to, route.gain) {
+				break
+			}
+		}
+	}
+
+	if config.normalize {
+		r.normalize()
+	}
+	return r, nil
+}
+
+func (r *Remixer) normalize() {
+	peak := float32(0)
+	for out := 0; out < r.outChannels; out++ {
+		sum := float32(0)
+		for _, gain := range r.matrix[out*r.inChannels : (out+1)*r.inChannels] {
+			sum += float32(math.Abs(float64(gain)))
+		}
+		peak = max(peak, sum)
+	}
+	if peak <= 1 {
+		return
+	}
+	for i := range r.matrix {
+		r.matrix[i] /= peak
+	}
+}
+
+// InChannels returns input channel count.
+func (r *Remixer) InChannels() int {
+	return r.inChannels
+}
+
+// OutChannels returns output channel count.
+func (r *Remixer) OutChannels() int {
+	return r.outChannels
+}
+
+// Matrix returns a copy of the matrix, outChannels rows of inChannels gains.
+func (r *Remixer) Matrix() []float32 {
+	return append([]float32(nil), r.matrix...)
+}
+
+// Gain returns the gain from input channel in to output channel out.
+func (r *Remixer) Gain(out int, in int) float32 {
+	return r.matrix[out*r.inChannels+in]
+}
+
+// SetGain sets the gain from input channel in to output channel out.
+func (r *Remixer) SetGain(out int, in int, gain float32) {
+	r.matrix[out*r.inChannels+in] = gain
+}
+
+// Process remixes interleaved frames of src into dst,
+// and returns the number of frames written, which is the smaller of the
+// frames in src and in dst.
+// dst must not overlap src.
+func (r *Remixer) Process(dst []float32, src []float32) int {
+	frames := min(len(src)/r.inChannels, len(dst)/r.outChannels)
+	for f := 0; f < frames; f++ {
+		in := src[f*r.inChannels : (f+1)*r.inChannels]
+		out := dst[f*r.outChannels : (f+1)*r.outChannels]
+		for o := range out {
+			row := r.matrix[o*r.inChannels : (o+1)*r.inChannels]
+			sum := float32(0)
+			for i, gain := range row {
+				sum += gain * in[i]
+			}
+			out[o] = sum
+		}
+	}
+	return frames
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"math"
+	"testing"
+)
+
+var (
+	monoIDs   = []ChannelID{ChannelIDFrontCenter}
+	stereoIDs = []ChannelID{ChannelIDFrontLeft, ChannelIDFrontRight}
+	fiveOne   = []ChannelID{
+		ChannelIDFrontLeft, ChannelIDFrontRight, ChannelIDFrontCenter,
+		ChannelIDLfe, ChannelIDSideLeft, ChannelIDSideRight,
+	}
+	sevenOne = []ChannelID{
+		ChannelIDFrontLeft, ChannelIDFrontRight, ChannelIDFrontCenter,
+		ChannelIDLfe, ChannelIDBackLeft, ChannelIDBackRight,
+		ChannelIDSideLeft, ChannelIDSideRight,
+	}
+)
+
+func equalMatrix(a []float32, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRemixerMatrix(t *testing.T) {
+	const h = minus3dB
+	tests := []struct {
+		name string
+		src  []ChannelID
+		dst  []ChannelID
+		opts []RemixOption
+		want []float32
+	}{
+		{"identity", stereoIDs, stereoIDs, nil, []float32{
+			1, 0,
+			0, 1,
+		}},
+		{"reordered", stereoIDs, []ChannelID{ChannelIDFrontRight, ChannelIDFrontLeft}, nil, []float32{
+			0, 1,
+			1, 0,
+		}},
+		{"mono to stereo", monoIDs, stereoIDs, nil, []float32{
+			1,
+			1,
+		}},
+		{"stereo to mono", stereoIDs, monoIDs, nil, []float32{
+			h, h,
+		}},
+		{"5.1 to stereo", fiveOne, stereoIDs, nil, []float32{
+			1, 0, h, 0, h, 0,
+			0, 1, h, 0, 0, h,
+		}},
+		{"5.1 to stereo with LFE", fiveOne, stereoIDs, []RemixOption{WithLFEGain(0.5)}, []float32{
+			1, 0, h, 0.5, h, 0,
+			0, 1, h, 0.5, 0, h,
+		}},
+		{"5.1 to mono", fiveOne, monoIDs, nil, []float32{
+			h, h, 1, 0, 0.5, 0.5,
+		}},
+		{"7.1 to 5.1", sevenOne, fiveOne, nil, []float32{
+			1, 0, 0, 0, 0, 0, 0, 0,
+			0, 1, 0, 0, 0, 0, 0, 0,
+			0, 0, 1, 0, 0, 0, 0, 0,
+			0, 0, 0, 1, 0, 0, 0, 0,
+			0, 0, 0, 0, 1, 0, 1, 0,
+			0, 0, 0, 0, 0, 1, 0, 1,
+		}},
+		{"stereo to 5.1", stereoIDs, fiveOne, nil, []float32{
+			1, 0,
+			0, 1,
+			0, 0,
+			0, 0,
+			0, 0,
+			0, 0,
+		}},
+		{"aux dropped", []ChannelID{ChannelIDFrontLeft, ChannelIDAux0}, stereoIDs, nil, []float32{
+			1, 0,
+			0, 0,
+		}},
+		{"normalized", stereoIDs, monoIDs, []RemixOption{WithNormalize()}, []float32{
+			0.5, 0.5,
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := newRemixer(test.src, test.dst, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.InChannels() != len(test.src) || r.OutChannels() != len(test.dst) {
+				t.Errorf("channels = %d -> %d", r.InChannels(), r.OutChannels())
+			}
+			if got := r.Matrix(); !equalMatrix(got, test.want) {
+				t.Errorf("matrix = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRemixerProcess(t *testing.T) {
+	r, err := newRemixer(fiveOne, stereoIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := []float32{
+		0.1, 0.2, 0.5, 0.9, 0.3, 0.4,
+		1, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0,
+	}
+	dst := make([]float32, 5)
+	if n := r.Process(dst, src); n != 2 {
+		t.Fatalf("Process = %d, want 2", n)
+	}
+	h := minus3dB
+	want := []float32{0.1 + h*0.5 + h*0.3, 0.2 + h*0.5 + h*0.4, 1, 0, 0}
+	if !equalMatrix(dst, want) {
+		t.Errorf("dst = %v, want %v", dst, want)
+	}
+}
+
+func TestRemixerCustom(t *testing.T) {
+	if _, err := NewRemixerMatrix(2, 1, []float32{1}); err != ErrorInvalid {
+		t.Errorf("err = %v, want ErrorInvalid", err)
+	}
+	if _, err := newRemixer(nil, stereoIDs); err != ErrorInvalid {
+		t.Errorf("err = %v, want ErrorInvalid", err)
+	}
+
+	matrix := []float32{0.5, -0.5}
+	r, err := NewRemixerMatrix(2, 1, matrix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matrix[0] = 0
+	if g := r.Gain(0, 0); g != 0.5 {
+		t.Errorf("Gain = %v, want the matrix to be copied", g)
+	}
+	r.SetGain(0, 1, 0.25)
+	dst := make([]float32, 1)
+	r.Process(dst, []float32{1, 1})
+	if dst[0] != 0.75 {
+		t.Errorf("dst = %v, want 0.75", dst[0])
+	}
+}
+
+func BenchmarkRemixer(b *testing.B) {
+	r, err := newRemixer(fiveOne, stereoIDs)
+	if err != nil {
+		b.Fatal(err)
+	}
+	src := make([]float32, 1024*6)
+	dst := make([]float32, 1024*2)
+	for b.Loop() {
+		r.Process(dst, src)
+	}
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+// Package resample converts the sample rate of interleaved float32 audio.
+// Converters do not allocate after creation, so they can be used in
+// stream callbacks.
+package resample
+
+import (
+	"errors"
+	"math"
+)
+
+// Quality selects the interpolation of a Resampler.
+type Quality int
+
+// Quality enumeration.
+const (
+	// Linear interpolates between neighbouring frames. It is cheap, but aliases.
+	Linear Quality = iota
+	// Low uses a 16 tap windowed sinc.
+	Low
+	// Medium uses a 32 tap windowed sinc.
+	Medium
+	// High uses a 64 tap windowed sinc.
+	High
+)
+
+// ErrInvalidParameter is returned for a channel count or sample rate that is not positive.
+var ErrInvalidParameter = errors.New("resample: invalid parameter")
+
+// phases is the number of precomputed filter phases between two input frames.
+const phases = 256
+
+type preset struct {
+	half   int     // taps on each side of the output frame
+	cutoff float64 // fraction of the Nyquist frequency that passes
+	beta   float64 // Kaiser window shape
+}
+
+var presets = map[Quality]preset{
+	Low:    {half: 8, cutoff: 0.88, beta: 6},
+	Medium: {half: 16, cutoff: 0.93, beta: 8},
+	High:   {half: 32, cutoff: 0.96, beta: 10},
+}
+
+// Resampler converts interleaved frames from one sample rate to another.
+// It is not safe for concurrent use by multiple goroutines.
+type Resampler struct {
+	channels int
+	inRate   int
+	outRate  int
+	half     int
+	taps     int
+	table    []float32 // (phases+1) rows of taps weights
+	history  []float32 // last taps frames, stored twice to keep a window contiguous
+	newest   int       // index of the newest frame in history
+	whole    int       // output position relative to the newest frame, integer part
+	phase    int       // output position, fraction in units of 1/outRate
+	stepInt  int
+	stepFrac int
+	weights  []float32
+}
+
+// New returns a Resampler of channels channels from inRate to outRate.
+func New(channels int, inRate int, outRate int, quality Quality) (*Resampler, error) {
+	if channels <= 0 || inRate <= 0 || outRate <= 0 {
+		return nil, ErrInvalidParameter
+	}
+	g := gcd(inRate, outRate)
+	r := &Resampler{
+		channels: channels,
+		inRate:   inRate / g,
+		outRate:  outRate / g,
+		half:     1,
+	}
+	r.stepInt = r.inRate / r.outRate
+	r.stepFrac = r.inRate % r.outRate
+
+	if p, ok := presets[quality]; ok {
+		r.half = p.half
+		// lower the cutoff below the output Nyquist frequency when downsampling
+		cutoff := p.cutoff * min(1, float64(outRate)/float64(inRate))
+		r.table = makeTable(p.half, cutoff, p.beta)
+		r.weights = make([]float32, 2*p.half)
+	} else if quality != Linear {
+		return nil, ErrInvalidParameter
+	}
+	r.taps = 2 * r.half
+	r.history = make([]float32, 2*r.taps*channels)
+	r.Reset()
+	return r, nil
+}
+
+// Channels returns channel count.
+func (r *Resampler) Channels() int {
+	return r.channels
+}
+
+// Ratio returns output frames per input frame.
+func (r *Resampler) Ratio() float64 {
+	return float64(r.outRate) / float64(r.inRate)
+}
+
+// Latency returns the delay of the output in input frames.
+func (r *Resampler) Latency() int {
+	return r.half
+}
+
+// MaxOutput returns the largest number of frames Process can produce from inFrames frames.
+func (r *Resampler) MaxOutput(inFrames int) int {
+	return (inFrames*r.outRate+r.inRate-1)/r.inRate + 1
+}
+
+// Reset clears the history, as if the input had been silent.
+func (r *Resampler) Reset() {
+	clear(r.history)
+	r.newest = r.taps - 1
+	// the first output frame is at the first input frame
+	r.whole = 1
+	r.phase = 0
+}
+
+// Process converts interleaved frames from src into dst, and returns
+// the number of frames consumed from src and produced into dst.
+// It stops when src is used up or dst is full; pass the rest of src
+// in the next call.
+func (r *Resampler) Process(dst []float32, src []float32) (consumed int, produced int) {
+	channels := r.channels
+	srcFrames := len(src) / channels
+	dstFrames := len(dst) / channels
+	for {
+		for r.whole <= -r.half {
+			if produced == dstFrames {
+				return
+			}
+			r.interpolate(dst[produced*channels : (produced+1)*channels])
+			produced++
+			r.whole += r.stepInt
+			r.phase += r.stepFrac
+			if r.phase >= r.outRate {
+				r.phase -= r.outRate
+				r.whole++
+			}
+		}
+		if consumed == srcFrames {
+			return
+		}
+		r.push(src[consumed*channels : (consumed+1)*channels])
+		consumed++
+		r.whole--
+	}
+}
+
+func (r *Resampler) push(frame []float32) {
+	r.newest++
+	if r.newest == r.taps {
+		r.newest = 0
+	}
+	copy(r.history[r.newest*r.channels:], frame)
+	copy(r.history[(r.newest+r.taps)*r.channels:], frame)
+}
+
+// interpolate computes the output frame at the current position. The
+// window of taps frames ending at the newest frame starts at the frame
+// before the position minus half plus one.
+func (r *Resampler) interpolate(out []float32) {
+	channels := r.channels
+	window := r.history[(r.newest+1)*channels : (r.newest+1+r.taps)*channels]
+	frac := float64(r.phase) / float64(r.outRate)
+
+	if r.table == nil {
+		f := float32(frac)
+		for ch := range out {
+			a := window[ch]
+			out[ch] = a + (window[channels+ch]-a)*f
+		}
+		return
+	}
+
+	pos := frac * phases
+	p := int(pos)
+	alpha := float32(pos - float64(p))
+	row0 := r.table[p*r.taps : (p+1)*r.taps]
+	row1 := r.table[(p+1)*r.taps : (p+2)*r.taps]
+	for k := range r.weights {
+		r.weights[k] = row0[k] + (row1[k]-row0[k])*alpha
+	}
+	for ch := range out {
+		var sum float32
+		for k, w := range r.weights {
+			sum += window[k*channels+ch] * w
+		}
+		out[ch] = sum
+	}
+}
+
+// makeTable returns Kaiser windowed sinc weights for phases+1 fractional
+// positions, each row normalized to unity gain.
+func makeTable(half int, cutoff float64, beta float64) []float32 {
+	taps := 2 * half
+	table := make([]float32, (phases+1)*taps)
+	norm := 1 / besselI0(beta)
+	for p := 0; p <= phases; p++ {
+		frac := float64(p) / phases
+		row := table[p*taps : (p+1)*taps]
+		sum := 0.0
+		weights := make([]float64, taps)
+		for k := range weights {
+			x := float64(k-half+1) - frac
+			t := x / float64(half)
+			if t <= -1 || t >= 1 {
+				continue
+			}
+			w := cutoff * sinc(cutoff*x) * besselI0(beta*math.Sqrt(1-t*t)) * norm
+			weights[k] = w
+			sum += w
+		}
+		for k, w := range weights {
+			row[k] = float32(w / sum)
+		}
+	}
+	return table
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// besselI0 returns the zeroth order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > sum*1e-12; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+	}
+	return sum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
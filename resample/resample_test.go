@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package resample
+
+import (
+	"math"
+	"testing"
+)
+
+var qualities = []Quality{Linear, Low, Medium, High}
+
+func sine(frames int, channels int, freq float64, rate int) []float32 {
+	src := make([]float32, frames*channels)
+	for i := 0; i < frames; i++ {
+		v := float32(math.Sin(2 * math.Pi * freq * float64(i) / float64(rate)))
+		for ch := 0; ch < channels; ch++ {
+			src[i*channels+ch] = v * float32(ch+1) / float32(channels)
+		}
+	}
+	return src
+}
+
+// run converts src in chunks of chunk frames.
+func run(t *testing.T, r *Resampler, src []float32, chunk int) []float32 {
+	t.Helper()
+	channels := r.Channels()
+	var out []float32
+	buf := make([]float32, 7*channels)
+	for len(src) > 0 {
+		in := src[:min(len(src), chunk*channels)]
+		for len(in) > 0 {
+			consumed, produced := r.Process(buf, in)
+			in = in[consumed*channels:]
+			src = src[consumed*channels:]
+			out = append(out, buf[:produced*channels]...)
+		}
+	}
+	for {
+		_, produced := r.Process(buf, nil)
+		if produced == 0 {
+			break
+		}
+		out = append(out, buf[:produced*channels]...)
+	}
+	return out
+}
+
+func TestChunkSizeInvariance(t *testing.T) {
+	src := sine(2000, 2, 440, 44100)
+	for _, q := range qualities {
+		r1, _ := New(2, 44100, 48000, q)
+		r2, _ := New(2, 44100, 48000, q)
+		want := run(t, r1, src, len(src))
+		got := run(t, r2, src, 13)
+		if len(got) != len(want) {
+			t.Fatalf("quality %d: %d samples, want %d", q, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("quality %d: sample %d = %v, want %v", q, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestSine(t *testing.T) {
+	rates := [][2]int{{44100, 48000}, {48000, 44100}, {8000, 48000}, {96000, 22050}}
+	tolerance := map[Quality]float64{Linear: 1e-1, Low: 1e-2, Medium: 5e-4, High: 5e-4}
+	const freq = 1000
+	for _, rate := range rates {
+		for _, q := range qualities {
+			const channels = 2
+			r, err := New(channels, rate[0], rate[1], q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			frames := rate[0] / 10
+			out := run(t, r, sine(frames, channels, freq, rate[0]), 64)
+
+			outFrames := len(out) / channels
+			// the last Latency input frames are held back
+			wantFrames := (frames - r.Latency()) * rate[1] / rate[0]
+			if outFrames < wantFrames-1 || outFrames > wantFrames+1 {
+				t.Errorf("%v quality %d: %d frames, want %d", rate, q, outFrames, wantFrames)
+			}
+
+			// skip the edges where the filter sees silence
+			margin := 2 * r.Latency() * rate[1] / rate[0]
+			worst := 0.0
+			for i := margin + 1; i < outFrames-margin-1; i++ {
+				want := math.Sin(2 * math.Pi * freq * float64(i) / float64(rate[1]))
+				for ch := 0; ch < channels; ch++ {
+					diff := math.Abs(float64(out[i*channels+ch]) - want*float64(ch+1)/channels)
+					worst = max(worst, diff)
+				}
+			}
+			if worst > tolerance[q] {
+				t.Errorf("%v quality %d: error %g, want <= %g", rate, q, worst, tolerance[q])
+			}
+		}
+	}
+}
+
+func TestAntiAliasing(t *testing.T) {
+	// 20 kHz cannot be represented at 22050 Hz and must be filtered out
+	r, _ := New(1, 48000, 22050, High)
+	out := run(t, r, sine(4800, 1, 20000, 48000), 100)
+	peak := 0.0
+	for _, v := range out[200 : len(out)-200] {
+		peak = max(peak, math.Abs(float64(v)))
+	}
+	if peak > 0.01 {
+		t.Errorf("peak = %g, want <= 0.01", peak)
+	}
+}
+
+func TestProcessDoesNotAllocate(t *testing.T) {
+	r, _ := New(2, 44100, 48000, High)
+	src := sine(441, 2, 440, 44100)
+	dst := make([]float32, r.MaxOutput(441)*2)
+	allocs := testing.AllocsPerRun(10, func() {
+		r.Process(dst, src)
+	})
+	if allocs != 0 {
+		t.Errorf("Process allocates %v times", allocs)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	if _, err := New(0, 44100, 48000, Low); err != ErrInvalidParameter {
+		t.Errorf("err = %v", err)
+	}
+	if _, err := New(1, 44100, 48000, Quality(99)); err != ErrInvalidParameter {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func BenchmarkProcess(b *testing.B) {
+	for _, q := range qualities {
+		r, _ := New(2, 44100, 48000, q)
+		src := sine(441, 2, 440, 44100)
+		dst := make([]float32, r.MaxOutput(441)*2)
+		b.Run([]string{"Linear", "Low", "Medium", "High"}[q], func(b *testing.B) {
+			for b.Loop() {
+				r.Process(dst, src)
+			}
+		})
+	}
+}
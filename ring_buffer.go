@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"sync/atomic"
+)
+
+// ringBuffer is a lock-free byte queue for one writer and one reader,
+// such as a stream callback and an application goroutine.
+type ringBuffer struct {
+	buffer []byte
+	read   atomic.Uint64 // total bytes read
+	write  atomic.Uint64 // total bytes written
+}
+
+func newRingBuffer(capacity int) *ringBuffer {
+	return &ringBuffer{
+		buffer: make([]byte, capacity),
+	}
+}
+
+// Capacity returns the size of the buffer.
+func (b *ringBuffer) Capacity() int {
+	return len(b.buffer)
+}
+
+// Readable returns how many bytes can be read.
+func (b *ringBuffer) Readable() int {
+	return int(b.write.Load() - b.read.Load())
+}
+
+// Writable returns how many bytes can be written.
+func (b *ringBuffer) Writable() int {
+	return len(b.buffer) - b.Readable()
+}
+
+// Write copies as much of p as fits, and returns the number of bytes copied.
+// Only the writer may call it.
+func (b *ringBuffer) Write(p []byte) int {
+	w := b.write.Load()
+	n := min(len(p), len(b.buffer)-int(w-b.read.Load()))
+	if n == 0 {
+		return 0
+	}
+	offset := int(w % uint64(len(b.buffer)))
+	copied := copy(b.buffer[offset:], p[:n])
+	copy(b.buffer, p[copied:n])
+	b.write.Store(w + uint64(n))
+	return n
+}
+
+// Read copies as many buffered bytes into p as fit, and returns the number of bytes copied.
+// Only the reader may call it.
+func (b *ringBuffer) Read(p []byte) int {
+	r := b.read.Load()
+	n := min(len(p), int(b.write.Load()-r))
+	if n == 0 {
+		return 0
+	}
+	offset := int(r % uint64(len(b.buffer)))
+	copied := copy(p[:n], b.buffer[offset:])
+	copy(p[copied:n], b.buffer)
+	b.read.Store(r + uint64(n))
+	return n
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRingBufferWrapAround(t *testing.T) {
+	b := newRingBuffer(8)
+	if n := b.Write([]byte("abcdef")); n != 6 {
+		t.Fatalf("Write = %d, want 6", n)
+	}
+	p := make([]byte, 4)
+	if n := b.Read(p); n != 4 || string(p) != "abcd" {
+		t.Fatalf("Read = %d %q, want 4 \"abcd\"", n, p)
+	}
+	if n := b.Write([]byte("ghijklmn")); n != 6 {
+		t.Fatalf("Write = %d, want 6 (capacity left)", n)
+	}
+	if b.Writable() != 0 || b.Readable() != 8 {
+		t.Fatalf("Readable %d Writable %d, want 8 0", b.Readable(), b.Writable())
+	}
+	p = make([]byte, 16)
+	n := b.Read(p)
+	if want := []byte("efghijkl"); !bytes.Equal(p[:n], want) {
+		t.Fatalf("Read = %q, want %q", p[:n], want)
+	}
+}
+
+func TestRingBufferConcurrent(t *testing.T) {
+	const total = 1 << 12
+	b := newRingBuffer(100)
+	go func() {
+		var v byte
+		chunk := make([]byte, 7)
+		for written := 0; written < total; {
+			for i := range chunk {
+				chunk[i] = v + byte(i)
+			}
+			n := b.Write(chunk[:min(len(chunk), total-written)])
+			v += byte(n)
+			written += n
+		}
+	}()
+
+	var want byte
+	p := make([]byte, 13)
+	for read := 0; read < total; {
+		n := b.Read(p)
+		for _, got := range p[:n] {
+			if got != want {
+				t.Fatalf("byte %d = %d, want %d", read, got, want)
+			}
+			want++
+			read++
+		}
+	}
+}
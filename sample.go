@@ -0,0 +1,203 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// sampleCodec describes the memory layout of a single sample of a Format.
+type sampleCodec struct {
+	size      int  // bytes in memory
+	bits      uint // significant bits
+	bigEndian bool
+	unsigned  bool
+	float     bool
+}
+
+var sampleCodecs = [...]sampleCodec{
+	FormatInvalid:   {},
+	FormatS8:        {size: 1, bits: 8},
+	FormatU8:        {size: 1, bits: 8, unsigned: true},
+	FormatS16LE:     {size: 2, bits: 16},
+	FormatS16BE:     {size: 2, bits: 16, bigEndian: true},
+	FormatU16LE:     {size: 2, bits: 16, unsigned: true},
+	FormatU16BE:     {size: 2, bits: 16, unsigned: true, bigEndian: true},
+	FormatS24LE:     {size: 4, bits: 24},
+	FormatS24BE:     {size: 4, bits: 24, bigEndian: true},
+	FormatU24LE:     {size: 4, bits: 24, unsigned: true},
+	FormatU24BE:     {size: 4, bits: 24, unsigned: true, bigEndian: true},
+	FormatS32LE:     {size: 4, bits: 32},
+	FormatS32BE:     {size: 4, bits: 32, bigEndian: true},
+	FormatU32LE:     {size: 4, bits: 32, unsigned: true},
+	FormatU32BE:     {size: 4, bits: 32, unsigned: true, bigEndian: true},
+	FormatFloat32LE: {size: 4, bits: 32, float: true},
+	FormatFloat32BE: {size: 4, bits: 32, float: true, bigEndian: true},
+	FormatFloat64LE: {size: 8, bits: 64, float: true},
+	FormatFloat64BE: {size: 8, bits: 64, float: true, bigEndian: true},
+}
+
+// codecOf returns the sample codec of format.
+// An invalid format yields a codec of size 0, which reads as silence and ignores writes.
+func codecOf(format Format) *sampleCodec {
+	if int(format) >= len(sampleCodecs) {
+		return &sampleCodecs[FormatInvalid]
+	}
+	return &sampleCodecs[format]
+}
+
+func (c *sampleCodec) byteOrder() binary.ByteOrder {
+	if c.bigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+func (c *sampleCodec) load(b []byte) uint64 {
+	switch c.size {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		if c.bigEndian {
+			return uint64(binary.BigEndian.Uint16(b))
+		}
+		return uint64(binary.LittleEndian.Uint16(b))
+	case 4:
+		if c.bigEndian {
+			return uint64(binary.BigEndian.Uint32(b))
+		}
+		return uint64(binary.LittleEndian.Uint32(b))
+	case 8:
+		if c.bigEndian {
+			return binary.BigEndian.Uint64(b)
+		}
+		return binary.LittleEndian.Uint64(b)
+	default:
+		return 0
+	}
+}
+
+func (c *sampleCodec) store(b []byte, raw uint64) {
+	switch c.size {
+	case 1:
+		b[0] = byte(raw)
+	case 2:
+		if c.bigEndian {
+			binary.BigEndian.PutUint16(b, uint16(raw))
+		} else {
+			binary.LittleEndian.PutUint16(b, uint16(raw))
+		}
+	case 4:
+		if c.bigEndian {
+			binary.BigEndian.PutUint32(b, uint32(raw))
+		} else {
+			binary.LittleEndian.PutUint32(b, uint32(raw))
+		}
+	case 8:
+		if c.bigEndian {
+			binary.BigEndian.PutUint64(b, raw)
+		} else {
+			binary.LittleEndian.PutUint64(b, raw)
+		}
+	}
+}
+
+// integer returns the signed value of an integer sample.
+func (c *sampleCodec) integer(raw uint64) int64 {
+	shift := 64 - c.bits
+	if c.unsigned {
+		return int64((raw<<shift)>>shift) - int64(1)<<(c.bits-1)
+	}
+	return int64(raw<<shift) >> shift
+}
+
+// raw returns the memory representation of a signed integer sample.
+// Signed 24 bit samples are sign extended into the unused byte.
+func (c *sampleCodec) raw(v int64) uint64 {
+	if c.unsigned {
+		return uint64(v + int64(1)<<(c.bits-1))
+	}
+	return uint64(v)
+}
+
+func (c *sampleCodec) decodeFloat64(b []byte) float64 {
+	if c.size == 0 {
+		return 0
+	}
+	raw := c.load(b)
+	if c.float {
+		if c.size == 4 {
+			return float64(math.Float32frombits(uint32(raw)))
+		}
+		return math.Float64frombits(raw)
+	}
+	return float64(c.integer(raw)) / float64(int64(1)<<(c.bits-1))
+}
+
+func (c *sampleCodec) encodeFloat64(b []byte, v float64) {
+	if c.size == 0 {
+		return
+	}
+	if c.float {
+		if c.size == 4 {
+			c.store(b, uint64(math.Float32bits(float32(v))))
+		} else {
+			c.store(b, math.Float64bits(v))
+		}
+		return
+	}
+	scale := float64(int64(1) << (c.bits - 1))
+	i := int64(math.Round(clampUnit(v) * scale))
+	if max := int64(scale) - 1; i > max {
+		i = max
+	}
+	c.store(b, c.raw(i))
+}
+
+func (c *sampleCodec) decodeInt32(b []byte) int32 {
+	if c.size == 0 {
+		return 0
+	}
+	if c.float {
+		return floatToInt32(c.decodeFloat64(b))
+	}
+	return int32(c.integer(c.load(b)) << (32 - c.bits))
+}
+
+func (c *sampleCodec) encodeInt32(b []byte, v int32) {
+	if c.size == 0 {
+		return
+	}
+	if c.float {
+		c.encodeFloat64(b, float64(v)/(1<<31))
+		return
+	}
+	c.store(b, c.raw(int64(v)>>(32-c.bits)))
+}
+
+func clampUnit(v float64) float64 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	if v > 1.0 {
+		return 1.0
+	}
+	if v < -1.0 {
+		return -1.0
+	}
+	return v
+}
+
+func floatToInt32(v float64) int32 {
+	i := math.Round(clampUnit(v) * (1 << 31))
+	if i > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(i)
+}
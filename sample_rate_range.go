@@ -7,9 +7,9 @@
 
 package soundio
 
-// #include "soundio.h"
-import "C"
-import "unsafe"
+import (
+	"encoding/json"
+)
 
 // SampleRateRange contains SampleRate Min, Max.
 type SampleRateRange struct {
@@ -17,6 +17,11 @@ type SampleRateRange struct {
 	max int
 }
 
+// NewSampleRateRange returns a range of sample rates from minRate to maxRate.
+func NewSampleRateRange(minRate int, maxRate int) SampleRateRange {
+	return SampleRateRange{min: minRate, max: maxRate}
+}
+
 // fields
 
 // Min returns sample rate minimal.
@@ -29,10 +34,22 @@ func (r SampleRateRange) Max() int {
 	return r.max
 }
 
-func newSampleRateRange(p uintptr) SampleRateRange {
-	r := (*C.struct_SoundIoSampleRateRange)(unsafe.Pointer(p))
-	return SampleRateRange{
-		min: int(r.min),
-		max: int(r.max),
+type sampleRateRangeJSON struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// MarshalJSON encodes the range as {"min":44100,"max":48000}.
+func (r SampleRateRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sampleRateRangeJSON{Min: r.min, Max: r.max})
+}
+
+// UnmarshalJSON decodes a range encoded by MarshalJSON.
+func (r *SampleRateRange) UnmarshalJSON(b []byte) error {
+	var v sampleRateRangeJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
 	}
+	r.min, r.max = v.Min, v.Max
+	return nil
 }
@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+// Package sio defines interfaces over a libsoundio context, its devices and
+// its streams. They are implemented by the cgo bindings through Wrap, and by
+// the in-memory fake of package siotest, so that code written against them can
+// be tested without audio devices. Without cgo, only the fake is available.
+package sio
+
+import (
+	"context"
+
+	soundio "github.com/crow-misia/go-libsoundio"
+)
+
+// Context is a connection to an audio backend, like soundio.SoundIo.
+type Context interface {
+	// Connect connects to the backend.
+	Connect() error
+	// Disconnect disconnects from the backend.
+	Disconnect()
+	// Close disconnects and releases resources.
+	Close() error
+	// CurrentBackend returns the connected backend, or soundio.BackendNone.
+	CurrentBackend() soundio.Backend
+
+	// FlushEvents updates the devices, and calls the listeners of changes.
+	FlushEvents()
+	// WaitEvents calls FlushEvents then blocks until ctx is done.
+	WaitEvents(ctx context.Context) error
+	// AddDevicesChangeListener registers listener to be called when the devices
+	// change, and returns a function that unregisters it.
+	AddDevicesChangeListener(listener func()) func()
+	// AddBackendDisconnectListener registers listener to be called when the
+	// backend disconnects, and returns a function that unregisters it.
+	AddBackendDisconnectListener(listener func(err error)) func()
+
+	InputDeviceCount() int
+	OutputDeviceCount() int
+	// InputDevice returns the input device at index, or nil.
+	InputDevice(index int) Device
+	// OutputDevice returns the output device at index, or nil.
+	OutputDevice(index int) Device
+	DefaultInputDeviceIndex() int
+	DefaultOutputDeviceIndex() int
+}
+
+// Device is an input or output device, like soundio.Device.
+type Device interface {
+	ID() string
+	Name() string
+	Aim() soundio.DeviceAim
+	Raw() bool
+	Formats() []soundio.Format
+	CurrentFormat() soundio.Format
+	Layouts() []*soundio.ChannelLayout
+	CurrentLayout() *soundio.ChannelLayout
+	SampleRates() []soundio.SampleRateRange
+	SampleRateCurrent() int
+	SoftwareLatencyMin() float64
+	SoftwareLatencyMax() float64
+	SoftwareLatencyCurrent() float64
+	ProbeError() error
+	SupportsFormat(format soundio.Format) bool
+	SupportsLayout(layout *soundio.ChannelLayout) bool
+	SupportsSampleRate(sampleRate int) bool
+
+	// NewInStream opens an input stream on the device.
+	NewInStream(config *soundio.InStreamConfig) (InStream, error)
+	// NewOutStream opens an output stream on the device.
+	NewOutStream(config *soundio.OutStreamConfig) (OutStream, error)
+	// Close releases the device.
+	Close() error
+}
+
+// stream is common to InStream and OutStream.
+type stream interface {
+	Format() soundio.Format
+	SampleRate() int
+	Layout() *soundio.ChannelLayout
+	SoftwareLatency() float64
+	Name() string
+	BytesPerFrame() int
+	BytesPerSample() int
+
+	// Start starts the stream, after which the callbacks are called.
+	Start() error
+	// Pause pauses or resumes the stream.
+	Pause(pause bool) error
+	// Run starts the stream, and waits events until ctx is done or the stream
+	// reports an error, which it returns.
+	Run(ctx context.Context) error
+	// Close stops the stream and releases resources.
+	Close() error
+}
+
+// InStream is an input stream, like soundio.InStream.
+type InStream interface {
+	stream
+	Device() Device
+	SetReadCallback(callback func(stream InStream, frameCountMin int, frameCountMax int))
+	SetOverflowCallback(callback func(stream InStream))
+	SetErrorCallback(callback func(stream InStream, err error))
+	// BeginRead returns the areas of up to frameCount captured frames,
+	// setting frameCount to the number of frames returned.
+	BeginRead(frameCount *int) (*soundio.ChannelAreas, error)
+	// EndRead drops the frames returned by BeginRead.
+	EndRead() error
+	// Latency returns the number of seconds captured audio takes to be read.
+	Latency() (float64, error)
+}
+
+// OutStream is an output stream, like soundio.OutStream.
+type OutStream interface {
+	stream
+	Device() Device
+	SetWriteCallback(callback func(stream OutStream, frameCountMin int, frameCountMax int))
+	SetUnderflowCallback(callback func(stream OutStream))
+	SetErrorCallback(callback func(stream OutStream, err error))
+	// BeginWrite returns the areas of up to frameCount frames to write,
+	// setting frameCount to the number of frames returned.
+	BeginWrite(frameCount *int) (*soundio.ChannelAreas, error)
+	// EndWrite commits the frames written to the areas returned by BeginWrite.
+	EndWrite() error
+	// ClearBuffer drops the frames that have not been played yet.
+	ClearBuffer() error
+	Volume() float32
+	SetVolume(volume float64) error
+	// Latency returns the number of seconds the next frame written takes to be audible.
+	Latency(outLatency float64) (float64, error)
+}
@@ -0,0 +1,293 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package siotest
+
+import (
+	"slices"
+
+	soundio "github.com/crow-misia/go-libsoundio"
+	"github.com/crow-misia/go-libsoundio/sio"
+)
+
+// DeviceConfig is config of a fake device.
+type DeviceConfig struct {
+	ID   string
+	Name string
+	Aim  soundio.DeviceAim
+	Raw  bool
+	// Formats defaults to soundio.FormatFloat32NE. The first one is the current format.
+	Formats []soundio.Format
+	// Layouts defaults to stereo. The first one is the current layout.
+	Layouts []*soundio.ChannelLayout
+	// SampleRates defaults to 48000. The current sample rate is the
+	// nearest to 48000.
+	SampleRates []soundio.SampleRateRange
+	// SoftwareLatencyMin defaults to 0.01 seconds.
+	SoftwareLatencyMin float64
+	// SoftwareLatencyMax defaults to 4 seconds.
+	SoftwareLatencyMax float64
+	// SoftwareLatencyCurrent defaults to 0.1 seconds.
+	SoftwareLatencyCurrent float64
+	// ProbeError is returned by ProbeError and when opening a stream.
+	ProbeError error
+	// Source returns the sample at frame of channel captured by input streams,
+	// from -1.0 to 1.0. Defaults to silence.
+	Source func(channel int, frame int64) float32
+}
+
+// Device is a fake sio.Device.
+type Device struct {
+	ctx        *Context
+	id         string
+	name       string
+	aim        soundio.DeviceAim
+	raw        bool
+	formats    []soundio.Format
+	layouts    []*soundio.ChannelLayout
+	rates      []soundio.SampleRateRange
+	latencyMin float64
+	latencyMax float64
+	latency    float64
+	probeError error
+	source     func(channel int, frame int64) float32
+	removed    bool
+}
+
+func newDevice(c *Context, config DeviceConfig) *Device {
+	d := &Device{
+		ctx:        c,
+		id:         config.ID,
+		name:       config.Name,
+		aim:        config.Aim,
+		raw:        config.Raw,
+		formats:    slices.Clone(config.Formats),
+		layouts:    slices.Clone(config.Layouts),
+		rates:      slices.Clone(config.SampleRates),
+		latencyMin: config.SoftwareLatencyMin,
+		latencyMax: config.SoftwareLatencyMax,
+		latency:    config.SoftwareLatencyCurrent,
+		probeError: config.ProbeError,
+		source:     config.Source,
+	}
+	if len(d.formats) == 0 {
+		d.formats = []soundio.Format{soundio.FormatFloat32NE}
+	}
+	if len(d.layouts) == 0 {
+		d.layouts = []*soundio.ChannelLayout{
+			soundio.NewChannelLayout("Stereo", soundio.ChannelIDFrontLeft, soundio.ChannelIDFrontRight),
+		}
+	}
+	if len(d.rates) == 0 {
+		d.rates = []soundio.SampleRateRange{soundio.NewSampleRateRange(48000, 48000)}
+	}
+	if d.latencyMin <= 0 {
+		d.latencyMin = 0.01
+	}
+	if d.latencyMax <= 0 {
+		d.latencyMax = 4
+	}
+	if d.latency <= 0 {
+		d.latency = 0.1
+	}
+	d.latency = min(max(d.latency, d.latencyMin), d.latencyMax)
+	return d
+}
+
+// ID returns device id.
+func (d *Device) ID() string {
+	return d.id
+}
+
+// Name returns device name.
+func (d *Device) Name() string {
+	return d.name
+}
+
+// Aim returns whether the device is an input or an output device.
+func (d *Device) Aim() soundio.DeviceAim {
+	return d.aim
+}
+
+// Raw returns whether the device is raw.
+func (d *Device) Raw() bool {
+	return d.raw
+}
+
+// Formats returns the formats of the device.
+func (d *Device) Formats() []soundio.Format {
+	return slices.Clone(d.formats)
+}
+
+// CurrentFormat returns the first format.
+func (d *Device) CurrentFormat() soundio.Format {
+	return d.formats[0]
+}
+
+// Layouts returns the channel layouts of the device.
+func (d *Device) Layouts() []*soundio.ChannelLayout {
+	return slices.Clone(d.layouts)
+}
+
+// CurrentLayout returns the first channel layout.
+func (d *Device) CurrentLayout() *soundio.ChannelLayout {
+	return d.layouts[0]
+}
+
+// SampleRates returns the sample rate ranges of the device.
+func (d *Device) SampleRates() []soundio.SampleRateRange {
+	return slices.Clone(d.rates)
+}
+
+// SampleRateCurrent returns the supported sample rate nearest to 48000.
+func (d *Device) SampleRateCurrent() int {
+	best := 0
+	for _, r := range d.rates {
+		rate := min(max(48000, r.Min()), r.Max())
+		if best == 0 || distance(rate, 48000) < distance(best, 48000) {
+			best = rate
+		}
+	}
+	return best
+}
+
+func distance(a int, b int) int {
+	if a < b {
+		return b - a
+	}
+	return a - b
+}
+
+// SoftwareLatencyMin returns the minimum software latency.
+func (d *Device) SoftwareLatencyMin() float64 {
+	return d.latencyMin
+}
+
+// SoftwareLatencyMax returns the maximum software latency.
+func (d *Device) SoftwareLatencyMax() float64 {
+	return d.latencyMax
+}
+
+// SoftwareLatencyCurrent returns the software latency of streams opened without one.
+func (d *Device) SoftwareLatencyCurrent() float64 {
+	return d.latency
+}
+
+// ProbeError returns DeviceConfig.ProbeError.
+func (d *Device) ProbeError() error {
+	return d.probeError
+}
+
+// SupportsFormat returns whether the device supports format.
+func (d *Device) SupportsFormat(format soundio.Format) bool {
+	return slices.Contains(d.formats, format)
+}
+
+// SupportsLayout returns whether the device supports layout.
+func (d *Device) SupportsLayout(layout *soundio.ChannelLayout) bool {
+	return slices.ContainsFunc(d.layouts, layout.Equal)
+}
+
+// SupportsSampleRate returns whether the device supports sampleRate.
+func (d *Device) SupportsSampleRate(sampleRate int) bool {
+	return slices.ContainsFunc(d.rates, func(r soundio.SampleRateRange) bool {
+		return r.Min() <= sampleRate && sampleRate <= r.Max()
+	})
+}
+
+// NewInStream opens a fake input stream.
+//
+// Possible errors:
+// * soundio.ErrorInvalid - the device is not an input device, or not connected
+// * soundio.ErrorNoSuchDevice - the device has been removed
+// * soundio.ErrorIncompatibleDevice - the device does not support the config
+// * the probe error of the device
+func (d *Device) NewInStream(config *soundio.InStreamConfig) (sio.InStream, error) {
+	if d.aim != soundio.DeviceAimInput {
+		return nil, soundio.ErrorInvalid
+	}
+	params, err := d.open(config.Format, config.SampleRate, config.Layout, config.SoftwareLatency, config.Name)
+	if err != nil {
+		return nil, err
+	}
+	s := &InStream{stream: params}
+	d.ctx.addStream(s)
+	return s, nil
+}
+
+// NewOutStream opens a fake output stream.
+//
+// Possible errors:
+// * soundio.ErrorInvalid - the device is not an output device, or not connected
+// * soundio.ErrorNoSuchDevice - the device has been removed
+// * soundio.ErrorIncompatibleDevice - the device does not support the config
+// * the probe error of the device
+func (d *Device) NewOutStream(config *soundio.OutStreamConfig) (sio.OutStream, error) {
+	if d.aim != soundio.DeviceAimOutput {
+		return nil, soundio.ErrorInvalid
+	}
+	params, err := d.open(config.Format, config.SampleRate, config.Layout, config.SoftwareLatency, config.Name)
+	if err != nil {
+		return nil, err
+	}
+	s := &OutStream{stream: params, volume: 1}
+	d.ctx.addStream(s)
+	return s, nil
+}
+
+// open resolves the parameters of a stream like libsoundio does, with zero
+// values replaced by the current values of the device.
+func (d *Device) open(format soundio.Format, sampleRate int, layout *soundio.ChannelLayout, latency float64, name string) (stream, error) {
+	d.ctx.mu.Lock()
+	connected, removed := d.ctx.backend != soundio.BackendNone, d.removed
+	d.ctx.mu.Unlock()
+	switch {
+	case !connected:
+		return stream{}, soundio.ErrorInvalid
+	case removed:
+		return stream{}, soundio.ErrorNoSuchDevice
+	case d.probeError != nil:
+		return stream{}, d.probeError
+	}
+
+	if format == soundio.FormatInvalid {
+		format = d.CurrentFormat()
+	}
+	if sampleRate <= 0 {
+		sampleRate = d.SampleRateCurrent()
+	}
+	if layout == nil {
+		layout = d.CurrentLayout()
+	}
+	if latency <= 0 {
+		latency = d.latency
+	}
+	if !d.SupportsFormat(format) || !d.SupportsSampleRate(sampleRate) || !d.SupportsLayout(layout) {
+		return stream{}, soundio.ErrorIncompatibleDevice
+	}
+	if name == "" {
+		name = "SoundIoStream"
+	}
+	latency = min(max(latency, d.latencyMin), d.latencyMax)
+	return stream{
+		ctx:        d.ctx,
+		dev:        d,
+		format:     format,
+		sampleRate: sampleRate,
+		layout:     *layout,
+		latency:    latency,
+		name:       name,
+		capacity:   max(int(latency*float64(sampleRate)), 1),
+	}, nil
+}
+
+// Close does nothing, as fake devices are owned by the Context.
+func (d *Device) Close() error {
+	return nil
+}
+
+var _ sio.Device = (*Device)(nil)
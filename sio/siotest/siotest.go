@@ -0,0 +1,396 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+// Package siotest provides an in-memory fake of the sio interfaces.
+// It simulates devices, formats, stream buffers, underflows, overflows and
+// disconnects, driven by a clock that only moves when Advance is called, so
+// that code using audio streams can be tested deterministically.
+package siotest
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	soundio "github.com/crow-misia/go-libsoundio"
+	"github.com/crow-misia/go-libsoundio/sio"
+)
+
+// Context is a fake sio.Context.
+// Stream callbacks are called on the goroutine calling Advance, or calling
+// RemoveDevice, DisconnectBackend or Fail for error callbacks.
+type Context struct {
+	mu                  sync.Mutex
+	backend             soundio.Backend
+	closed              bool
+	now                 time.Duration
+	inputs              []*Device
+	outputs             []*Device
+	defaults            map[soundio.DeviceAim]string
+	streams             []fakeStream
+	changed             bool
+	disconnectErr       error
+	wake                chan struct{}
+	listeners           map[int]func()
+	disconnectListeners map[int]func(error)
+	nextKey             int
+}
+
+// fakeStream is a stream opened on a fake device.
+type fakeStream interface {
+	device() *Device
+	advance(d time.Duration)
+	fail(err error)
+	Close() error
+}
+
+// NewContext returns a fake context that is not connected, and has no devices.
+func NewContext() *Context {
+	return &Context{
+		defaults:            make(map[soundio.DeviceAim]string),
+		wake:                make(chan struct{}, 1),
+		listeners:           make(map[int]func()),
+		disconnectListeners: make(map[int]func(error)),
+	}
+}
+
+// Now returns how long the clock has been advanced.
+func (c *Context) Now() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d. Running streams play or capture the
+// frames of d in steps of half their buffer, calling the write or read
+// callback before each step, and the underflow or overflow callback when the
+// buffer runs empty or full.
+func (c *Context) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now += d
+	streams := slices.Clone(c.streams)
+	c.mu.Unlock()
+	for _, s := range streams {
+		s.advance(d)
+	}
+}
+
+// AddDevice adds a device, and makes it the default device of its aim if
+// there is none. Listeners of device changes are called by the next FlushEvents.
+func (c *Context) AddDevice(config DeviceConfig) *Device {
+	d := newDevice(c, config)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d.aim == soundio.DeviceAimInput {
+		c.inputs = append(c.inputs, d)
+	} else {
+		c.outputs = append(c.outputs, d)
+	}
+	if _, ok := c.defaults[d.aim]; !ok {
+		c.defaults[d.aim] = d.id
+	}
+	c.notifyLocked()
+	return d
+}
+
+// RemoveDevice removes the device with id. Its streams fail with
+// soundio.ErrorStreaming, and the default device of its aim becomes the
+// first remaining device.
+func (c *Context) RemoveDevice(aim soundio.DeviceAim, id string) {
+	c.mu.Lock()
+	devices := c.devicesLocked(aim)
+	i := slices.IndexFunc(*devices, func(d *Device) bool { return d.id == id })
+	if i < 0 {
+		c.mu.Unlock()
+		return
+	}
+	removed := (*devices)[i]
+	removed.removed = true
+	*devices = slices.Delete(*devices, i, i+1)
+	if c.defaults[aim] == id {
+		delete(c.defaults, aim)
+		if len(*devices) > 0 {
+			c.defaults[aim] = (*devices)[0].id
+		}
+	}
+	var failed []fakeStream
+	for _, s := range c.streams {
+		if s.device() == removed {
+			failed = append(failed, s)
+		}
+	}
+	c.notifyLocked()
+	c.mu.Unlock()
+
+	for _, s := range failed {
+		s.fail(soundio.ErrorStreaming)
+	}
+}
+
+// SetDefaultDevice makes the device with id the default device of aim.
+func (c *Context) SetDefaultDevice(aim soundio.DeviceAim, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.defaults[aim] == id {
+		return
+	}
+	c.defaults[aim] = id
+	c.notifyLocked()
+}
+
+// DisconnectBackend simulates the backend going away: every stream fails with
+// err, and the next FlushEvents calls the backend disconnect listeners and
+// disconnects. err defaults to soundio.ErrorBackendDisconnected.
+func (c *Context) DisconnectBackend(err error) {
+	if err == nil {
+		err = soundio.ErrorBackendDisconnected
+	}
+	c.mu.Lock()
+	if c.backend == soundio.BackendNone {
+		c.mu.Unlock()
+		return
+	}
+	c.disconnectErr = err
+	streams := slices.Clone(c.streams)
+	c.wakeLocked()
+	c.mu.Unlock()
+
+	for _, s := range streams {
+		s.fail(err)
+	}
+}
+
+// notifyLocked records a device change for the next FlushEvents.
+func (c *Context) notifyLocked() {
+	if c.backend == soundio.BackendNone {
+		return
+	}
+	c.changed = true
+	c.wakeLocked()
+}
+
+func (c *Context) wakeLocked() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Context) devicesLocked(aim soundio.DeviceAim) *[]*Device {
+	if aim == soundio.DeviceAimInput {
+		return &c.inputs
+	}
+	return &c.outputs
+}
+
+// Connect connects to the fake backend, which is reported as soundio.BackendDummy.
+func (c *Context) Connect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return soundio.ErrClosed
+	}
+	if c.backend != soundio.BackendNone {
+		return soundio.ErrorInvalid
+	}
+	c.backend = soundio.BackendDummy
+	c.changed = true
+	return nil
+}
+
+// Disconnect disconnects from the fake backend.
+func (c *Context) Disconnect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.backend = soundio.BackendNone
+	c.changed, c.disconnectErr = false, nil
+}
+
+// Close closes every stream, and disconnects.
+func (c *Context) Close() error {
+	c.mu.Lock()
+	streams := slices.Clone(c.streams)
+	c.mu.Unlock()
+	for _, s := range streams {
+		_ = s.Close()
+	}
+	c.Disconnect()
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	return nil
+}
+
+// CurrentBackend returns soundio.BackendDummy while connected.
+func (c *Context) CurrentBackend() soundio.Backend {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.backend
+}
+
+// FlushEvents calls the listeners of the changes since the last call.
+func (c *Context) FlushEvents() {
+	c.mu.Lock()
+	if c.backend == soundio.BackendNone {
+		c.mu.Unlock()
+		return
+	}
+	changed, disconnectErr := c.changed, c.disconnectErr
+	c.changed, c.disconnectErr = false, nil
+	if disconnectErr != nil {
+		c.backend = soundio.BackendNone
+	}
+	listeners := make([]func(), 0, len(c.listeners))
+	for _, l := range c.listeners {
+		listeners = append(listeners, l)
+	}
+	disconnectListeners := make([]func(error), 0, len(c.disconnectListeners))
+	for _, l := range c.disconnectListeners {
+		disconnectListeners = append(disconnectListeners, l)
+	}
+	c.mu.Unlock()
+
+	if changed {
+		for _, l := range listeners {
+			l()
+		}
+	}
+	if disconnectErr != nil {
+		for _, l := range disconnectListeners {
+			l(disconnectErr)
+		}
+	}
+}
+
+// WaitEvents calls FlushEvents whenever a change is made, until ctx is done
+// or the backend disconnects.
+func (c *Context) WaitEvents(ctx context.Context) error {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return soundio.ErrClosed
+	}
+	for ctx.Err() == nil {
+		c.FlushEvents()
+		if c.CurrentBackend() == soundio.BackendNone {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-c.wake:
+		}
+	}
+	return ctx.Err()
+}
+
+// AddDevicesChangeListener registers listener to be called by FlushEvents
+// after devices change, and returns a function that unregisters it.
+func (c *Context) AddDevicesChangeListener(listener func()) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := c.nextKey
+	c.nextKey++
+	c.listeners[key] = listener
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.listeners, key)
+	}
+}
+
+// AddBackendDisconnectListener registers listener to be called by FlushEvents
+// after DisconnectBackend, and returns a function that unregisters it.
+func (c *Context) AddBackendDisconnectListener(listener func(err error)) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := c.nextKey
+	c.nextKey++
+	c.disconnectListeners[key] = listener
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.disconnectListeners, key)
+	}
+}
+
+// InputDeviceCount returns the number of input devices, or -1 when not connected.
+func (c *Context) InputDeviceCount() int {
+	return c.deviceCount(soundio.DeviceAimInput)
+}
+
+// OutputDeviceCount returns the number of output devices, or -1 when not connected.
+func (c *Context) OutputDeviceCount() int {
+	return c.deviceCount(soundio.DeviceAimOutput)
+}
+
+func (c *Context) deviceCount(aim soundio.DeviceAim) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.backend == soundio.BackendNone {
+		return -1
+	}
+	return len(*c.devicesLocked(aim))
+}
+
+// InputDevice returns the input device at index, or nil.
+func (c *Context) InputDevice(index int) sio.Device {
+	return c.deviceAt(soundio.DeviceAimInput, index)
+}
+
+// OutputDevice returns the output device at index, or nil.
+func (c *Context) OutputDevice(index int) sio.Device {
+	return c.deviceAt(soundio.DeviceAimOutput, index)
+}
+
+func (c *Context) deviceAt(aim soundio.DeviceAim, index int) sio.Device {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	devices := *c.devicesLocked(aim)
+	if c.backend == soundio.BackendNone || index < 0 || index >= len(devices) {
+		return nil
+	}
+	return devices[index]
+}
+
+// DefaultInputDeviceIndex returns the index of the default input device, or -1.
+func (c *Context) DefaultInputDeviceIndex() int {
+	return c.defaultIndex(soundio.DeviceAimInput)
+}
+
+// DefaultOutputDeviceIndex returns the index of the default output device, or -1.
+func (c *Context) DefaultOutputDeviceIndex() int {
+	return c.defaultIndex(soundio.DeviceAimOutput)
+}
+
+func (c *Context) defaultIndex(aim soundio.DeviceAim) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.backend == soundio.BackendNone {
+		return -1
+	}
+	id, ok := c.defaults[aim]
+	if !ok {
+		return -1
+	}
+	return slices.IndexFunc(*c.devicesLocked(aim), func(d *Device) bool { return d.id == id })
+}
+
+func (c *Context) addStream(s fakeStream) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.streams = append(c.streams, s)
+}
+
+func (c *Context) removeStream(s fakeStream) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.streams = slices.DeleteFunc(c.streams, func(o fakeStream) bool { return o == s })
+}
+
+var _ sio.Context = (*Context)(nil)
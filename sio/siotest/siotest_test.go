@@ -0,0 +1,249 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package siotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	soundio "github.com/crow-misia/go-libsoundio"
+	"github.com/crow-misia/go-libsoundio/sio"
+)
+
+// newTestContext returns a connected context with one input and one output device.
+func newTestContext(t *testing.T) *Context {
+	t.Helper()
+	c := NewContext()
+	c.AddDevice(DeviceConfig{ID: "speaker", Aim: soundio.DeviceAimOutput, SampleRates: []soundio.SampleRateRange{soundio.NewSampleRateRange(1000, 1000)}})
+	c.AddDevice(DeviceConfig{
+		ID:          "mic",
+		Aim:         soundio.DeviceAimInput,
+		SampleRates: []soundio.SampleRateRange{soundio.NewSampleRateRange(1000, 1000)},
+		Source: func(channel int, frame int64) float32 {
+			return float32(frame%10) / 10
+		},
+	})
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	return c
+}
+
+func TestContextDevices(t *testing.T) {
+	c := newTestContext(t)
+	if got := c.OutputDeviceCount(); got != 1 {
+		t.Errorf("OutputDeviceCount() = %d, want 1", got)
+	}
+	if d := c.OutputDevice(c.DefaultOutputDeviceIndex()); d == nil || d.ID() != "speaker" {
+		t.Errorf("default output device = %v", d)
+	}
+	if d := c.OutputDevice(1); d != nil {
+		t.Errorf("OutputDevice(1) = %v, want nil", d)
+	}
+
+	changes := 0
+	remove := c.AddDevicesChangeListener(func() { changes++ })
+	defer remove()
+	c.FlushEvents()
+	c.AddDevice(DeviceConfig{ID: "headphones", Aim: soundio.DeviceAimOutput})
+	c.SetDefaultDevice(soundio.DeviceAimOutput, "headphones")
+	if changes != 1 {
+		t.Errorf("changes before FlushEvents = %d, want 1", changes)
+	}
+	c.FlushEvents()
+	if changes != 2 {
+		t.Errorf("changes after FlushEvents = %d, want 2", changes)
+	}
+	if d := c.OutputDevice(c.DefaultOutputDeviceIndex()); d.ID() != "headphones" {
+		t.Errorf("default output device = %s, want headphones", d.ID())
+	}
+}
+
+func TestOutStreamPlayback(t *testing.T) {
+	c := newTestContext(t)
+	device := c.OutputDevice(c.DefaultOutputDeviceIndex())
+	stream, err := device.NewOutStream(&soundio.OutStreamConfig{SoftwareLatency: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	underflows := 0
+	stream.SetUnderflowCallback(func(sio.OutStream) { underflows++ })
+	write := true
+	stream.SetWriteCallback(func(stream sio.OutStream, frameCountMin int, frameCountMax int) {
+		if !write {
+			return
+		}
+		frameCount := frameCountMax
+		areas, err := stream.BeginWrite(&frameCount)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for frame := 0; frame < frameCount; frame++ {
+			areas.WriteFloat32(0, frame, 0.5)
+		}
+		if err := stream.EndWrite(); err != nil {
+			t.Error(err)
+		}
+	})
+	if err := stream.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Advance(time.Second)
+	written := stream.(*OutStream).Written()
+	// the buffer of 100 frames is filled before each step of 50 frames is played
+	if len(written) != 2*1050 || written[0] != 0.5 || written[1] != 0 {
+		t.Errorf("written %d samples starting with %v", len(written), written[:2])
+	}
+	if underflows != 0 {
+		t.Errorf("underflows = %d, want 0", underflows)
+	}
+	if latency, _ := stream.Latency(0); latency != 0.05 {
+		t.Errorf("Latency() = %v, want 0.05", latency)
+	}
+
+	write = false
+	c.Advance(200 * time.Millisecond)
+	if underflows != 3 {
+		t.Errorf("underflows after writing stopped = %d, want 3", underflows)
+	}
+
+	if err := stream.Pause(true); err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(time.Second)
+	if underflows != 3 {
+		t.Errorf("underflows while paused = %d, want 3", underflows)
+	}
+}
+
+func TestInStreamCapture(t *testing.T) {
+	c := newTestContext(t)
+	device := c.InputDevice(c.DefaultInputDeviceIndex())
+	stream, err := device.NewInStream(&soundio.InStreamConfig{SoftwareLatency: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	overflows := 0
+	stream.SetOverflowCallback(func(sio.InStream) { overflows++ })
+	var captured []float32
+	read := true
+	stream.SetReadCallback(func(stream sio.InStream, frameCountMin int, frameCountMax int) {
+		if !read {
+			return
+		}
+		frameCount := frameCountMax
+		areas, err := stream.BeginRead(&frameCount)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for frame := 0; frame < frameCount; frame++ {
+			captured = append(captured, areas.ReadFloat32(1, frame))
+		}
+		if err := stream.EndRead(); err != nil {
+			t.Error(err)
+		}
+	})
+	if err := stream.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Advance(time.Second)
+	if len(captured) != 1000 || captured[3] != 0.3 || captured[999] != 0.9 {
+		t.Errorf("captured %d frames", len(captured))
+	}
+
+	read = false
+	c.Advance(time.Second)
+	if overflows == 0 {
+		t.Error("overflow callback was not called")
+	}
+	if latency, _ := stream.Latency(); latency != 0.1 {
+		t.Errorf("Latency() = %v, want 0.1", latency)
+	}
+}
+
+func TestOpenIncompatible(t *testing.T) {
+	c := newTestContext(t)
+	device := c.OutputDevice(0)
+	if _, err := device.NewOutStream(&soundio.OutStreamConfig{SampleRate: 44100}); !errors.Is(err, soundio.ErrorIncompatibleDevice) {
+		t.Errorf("NewOutStream(44100) = %v, want ErrorIncompatibleDevice", err)
+	}
+	if _, err := device.NewInStream(&soundio.InStreamConfig{}); !errors.Is(err, soundio.ErrorInvalid) {
+		t.Errorf("NewInStream on output device = %v, want ErrorInvalid", err)
+	}
+}
+
+func TestRemoveDeviceFailsStream(t *testing.T) {
+	c := newTestContext(t)
+	stream, err := c.OutputDevice(0).NewOutStream(&soundio.OutStreamConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stream.Run(context.Background())
+	}()
+	waitRunning(t, stream.(*OutStream))
+	c.RemoveDevice(soundio.DeviceAimOutput, "speaker")
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, soundio.ErrorStreaming) {
+			t.Errorf("Run() = %v, want ErrorStreaming", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	if got := c.DefaultOutputDeviceIndex(); got != -1 {
+		t.Errorf("DefaultOutputDeviceIndex() = %d, want -1", got)
+	}
+}
+
+func TestDisconnectBackend(t *testing.T) {
+	c := newTestContext(t)
+	var disconnected error
+	remove := c.AddBackendDisconnectListener(func(err error) { disconnected = err })
+	defer remove()
+
+	c.DisconnectBackend(nil)
+	if err := c.WaitEvents(context.Background()); err != nil {
+		t.Errorf("WaitEvents() = %v, want nil after disconnect", err)
+	}
+	if !errors.Is(disconnected, soundio.ErrorBackendDisconnected) {
+		t.Errorf("disconnect error = %v, want ErrorBackendDisconnected", disconnected)
+	}
+	if c.CurrentBackend() != soundio.BackendNone {
+		t.Errorf("CurrentBackend() = %v, want BackendNone", c.CurrentBackend())
+	}
+}
+
+// waitRunning waits until Run has started stream.
+func waitRunning(t *testing.T, stream *OutStream) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !stream.running() {
+		if time.Now().After(deadline) {
+			t.Fatal("stream was not started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
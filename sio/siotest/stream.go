@@ -0,0 +1,478 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package siotest
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	soundio "github.com/crow-misia/go-libsoundio"
+	"github.com/crow-misia/go-libsoundio/sio"
+)
+
+// stream is common to InStream and OutStream. Its state is guarded by the
+// mutex of the Context, which is not held while calling the callbacks.
+type stream struct {
+	ctx        *Context
+	dev        *Device
+	format     soundio.Format
+	sampleRate int
+	layout     soundio.ChannelLayout
+	latency    float64
+	name       string
+	capacity   int
+
+	started bool
+	paused  bool
+	closed  bool
+	failed  bool
+	elapsed time.Duration
+	frames  int64
+	fill    int
+	pending int
+	stop    context.CancelCauseFunc
+}
+
+// Format returns format of stream.
+func (s *stream) Format() soundio.Format {
+	return s.format
+}
+
+// SampleRate returns sample rate of stream.
+func (s *stream) SampleRate() int {
+	return s.sampleRate
+}
+
+// Layout returns layout of stream.
+func (s *stream) Layout() *soundio.ChannelLayout {
+	return &s.layout
+}
+
+// SoftwareLatency returns software latency of stream, which is the size of its buffer.
+func (s *stream) SoftwareLatency() float64 {
+	return s.latency
+}
+
+// Name returns name of stream.
+func (s *stream) Name() string {
+	return s.name
+}
+
+// BytesPerFrame returns bytes per frame.
+func (s *stream) BytesPerFrame() int {
+	return soundio.BytesPerFrame(s.format, s.layout.ChannelCount())
+}
+
+// BytesPerSample returns bytes per sample.
+func (s *stream) BytesPerSample() int {
+	return soundio.BytesPerSample(s.format)
+}
+
+func (s *stream) device() *Device {
+	return s.dev
+}
+
+// Start starts the stream. The callbacks are called by the next Advance.
+func (s *stream) Start() error {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	if s.closed {
+		return soundio.ErrClosed
+	}
+	if s.started {
+		return soundio.ErrorInvalid
+	}
+	s.started = true
+	return nil
+}
+
+// Pause pauses or resumes the stream. The clock does not move for a paused stream.
+func (s *stream) Pause(pause bool) error {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	if s.closed {
+		return soundio.ErrClosed
+	}
+	s.paused = pause
+	return nil
+}
+
+// run starts the stream, and waits events until ctx is done or the stream fails.
+func (s *stream) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	s.ctx.mu.Lock()
+	s.stop = cancel
+	s.ctx.mu.Unlock()
+	defer func() {
+		s.ctx.mu.Lock()
+		s.stop = nil
+		s.ctx.mu.Unlock()
+	}()
+
+	if err := s.Start(); err != nil {
+		return err
+	}
+	if err := s.ctx.WaitEvents(ctx); errors.Is(err, soundio.ErrClosed) {
+		return err
+	}
+	return context.Cause(ctx)
+}
+
+// elapse moves the clock of the stream by d, and returns the number of frames
+// to play or capture, and the number of frames in each step.
+// Frames are counted as each step is played or captured.
+func (s *stream) elapse(d time.Duration) (int64, int64) {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	if !s.runningLocked() {
+		return 0, 0
+	}
+	s.elapsed += d
+	due := int64(s.elapsed)*int64(s.sampleRate)/int64(time.Second) - s.frames
+	return due, int64(max(s.capacity/2, 1))
+}
+
+func (s *stream) running() bool {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	return s.runningLocked()
+}
+
+func (s *stream) runningLocked() bool {
+	return s.started && !s.paused && !s.closed && !s.failed
+}
+
+// markFailed stops the stream, and returns the function stopping Run.
+func (s *stream) markFailed() (context.CancelCauseFunc, bool) {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	if s.closed || s.failed {
+		return nil, false
+	}
+	s.failed = true
+	return s.stop, true
+}
+
+// markClosed closes the stream, and returns whether it was open.
+func (s *stream) markClosed() bool {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.closed = true
+	return true
+}
+
+// bufferLatency returns the number of seconds of audio in the buffer.
+func (s *stream) bufferLatency() (float64, error) {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	if s.closed {
+		return 0, soundio.ErrClosed
+	}
+	return float64(s.fill) / float64(s.sampleRate), nil
+}
+
+// InStream is a fake sio.InStream, which captures the Source of its device.
+type InStream struct {
+	stream
+	readCallback     func(sio.InStream, int, int)
+	overflowCallback func(sio.InStream)
+	errorCallback    func(sio.InStream, error)
+}
+
+// Device returns device to which the stream belongs.
+func (s *InStream) Device() sio.Device {
+	return s.dev
+}
+
+// SetReadCallback sets ReadCallback.
+func (s *InStream) SetReadCallback(callback func(stream sio.InStream, frameCountMin int, frameCountMax int)) {
+	s.readCallback = callback
+}
+
+// SetOverflowCallback sets OverflowCallback.
+func (s *InStream) SetOverflowCallback(callback func(stream sio.InStream)) {
+	s.overflowCallback = callback
+}
+
+// SetErrorCallback sets ErrorCallback.
+func (s *InStream) SetErrorCallback(callback func(stream sio.InStream, err error)) {
+	s.errorCallback = callback
+}
+
+// Run starts capturing, and waits events of the Context until ctx is done or
+// the stream fails.
+func (s *InStream) Run(ctx context.Context) error {
+	return s.run(ctx)
+}
+
+// Fail makes the stream fail with err, as if the device reported it.
+func (s *InStream) Fail(err error) {
+	s.fail(err)
+}
+
+func (s *InStream) fail(err error) {
+	stop, ok := s.markFailed()
+	if !ok {
+		return
+	}
+	if s.errorCallback != nil {
+		s.errorCallback(s, err)
+	}
+	if stop != nil {
+		stop(err)
+	}
+}
+
+func (s *InStream) advance(d time.Duration) {
+	due, step := s.elapse(d)
+	for due > 0 && s.running() {
+		n := min(due, step)
+		due -= n
+
+		s.ctx.mu.Lock()
+		s.frames += n
+		s.fill += int(n)
+		overflow := s.fill > s.capacity
+		s.fill = min(s.fill, s.capacity)
+		readable := s.fill
+		s.ctx.mu.Unlock()
+
+		if overflow && s.overflowCallback != nil {
+			s.overflowCallback(s)
+		}
+		if s.readCallback != nil {
+			s.readCallback(s, 0, readable)
+		}
+	}
+}
+
+// BeginRead returns areas of up to frameCount captured frames.
+func (s *InStream) BeginRead(frameCount *int) (*soundio.ChannelAreas, error) {
+	s.ctx.mu.Lock()
+	if s.closed {
+		s.ctx.mu.Unlock()
+		return nil, soundio.ErrClosed
+	}
+	n := max(min(*frameCount, s.fill), 0)
+	first := s.frames - int64(s.fill)
+	s.pending = n
+	s.ctx.mu.Unlock()
+
+	*frameCount = n
+	areas := soundio.NewChannelAreas(s.format, s.layout.ChannelCount(), n)
+	if source := s.dev.source; source != nil {
+		for frame := 0; frame < n; frame++ {
+			for ch := 0; ch < s.layout.ChannelCount(); ch++ {
+				areas.WriteFloat32(ch, frame, source(ch, first+int64(frame)))
+			}
+		}
+	}
+	return areas, nil
+}
+
+// EndRead drops the frames returned by BeginRead.
+func (s *InStream) EndRead() error {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	if s.closed {
+		return soundio.ErrClosed
+	}
+	s.fill -= s.pending
+	s.pending = 0
+	return nil
+}
+
+// Latency returns the number of seconds of captured audio in the buffer.
+func (s *InStream) Latency() (float64, error) {
+	return s.bufferLatency()
+}
+
+// Close stops the stream.
+func (s *InStream) Close() error {
+	if s.markClosed() {
+		s.ctx.removeStream(s)
+	}
+	return nil
+}
+
+// OutStream is a fake sio.OutStream, which records the frames written to it.
+type OutStream struct {
+	stream
+	volume            float32
+	written           []float32
+	writeCallback     func(sio.OutStream, int, int)
+	underflowCallback func(sio.OutStream)
+	errorCallback     func(sio.OutStream, error)
+	areas             *soundio.ChannelAreas
+}
+
+// Device returns device to which the stream belongs.
+func (s *OutStream) Device() sio.Device {
+	return s.dev
+}
+
+// SetWriteCallback sets WriteCallback.
+func (s *OutStream) SetWriteCallback(callback func(stream sio.OutStream, frameCountMin int, frameCountMax int)) {
+	s.writeCallback = callback
+}
+
+// SetUnderflowCallback sets UnderflowCallback.
+func (s *OutStream) SetUnderflowCallback(callback func(stream sio.OutStream)) {
+	s.underflowCallback = callback
+}
+
+// SetErrorCallback sets ErrorCallback.
+func (s *OutStream) SetErrorCallback(callback func(stream sio.OutStream, err error)) {
+	s.errorCallback = callback
+}
+
+// Run starts playback, and waits events of the Context until ctx is done or
+// the stream fails.
+func (s *OutStream) Run(ctx context.Context) error {
+	return s.run(ctx)
+}
+
+// Fail makes the stream fail with err, as if the device reported it.
+func (s *OutStream) Fail(err error) {
+	s.fail(err)
+}
+
+func (s *OutStream) fail(err error) {
+	stop, ok := s.markFailed()
+	if !ok {
+		return
+	}
+	if s.errorCallback != nil {
+		s.errorCallback(s, err)
+	}
+	if stop != nil {
+		stop(err)
+	}
+}
+
+// Written returns the interleaved samples committed by EndWrite since the
+// last call, from -1.0 to 1.0.
+func (s *OutStream) Written() []float32 {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	written := s.written
+	s.written = nil
+	return written
+}
+
+func (s *OutStream) advance(d time.Duration) {
+	due, step := s.elapse(d)
+	for due > 0 && s.running() {
+		n := min(due, step)
+		due -= n
+
+		if s.writeCallback != nil {
+			s.ctx.mu.Lock()
+			writable := s.capacity - s.fill
+			s.ctx.mu.Unlock()
+			s.writeCallback(s, 0, writable)
+		}
+
+		s.ctx.mu.Lock()
+		s.frames += n
+		underflow := int64(s.fill) < n
+		s.fill = max(s.fill-int(n), 0)
+		s.ctx.mu.Unlock()
+		if underflow && s.underflowCallback != nil {
+			s.underflowCallback(s)
+		}
+	}
+}
+
+// BeginWrite returns areas of up to frameCount frames that fit in the buffer.
+func (s *OutStream) BeginWrite(frameCount *int) (*soundio.ChannelAreas, error) {
+	s.ctx.mu.Lock()
+	if s.closed {
+		s.ctx.mu.Unlock()
+		return nil, soundio.ErrClosed
+	}
+	n := max(min(*frameCount, s.capacity-s.fill), 0)
+	s.pending = n
+	s.ctx.mu.Unlock()
+
+	*frameCount = n
+	s.areas = soundio.NewChannelAreas(s.format, s.layout.ChannelCount(), n)
+	return s.areas, nil
+}
+
+// EndWrite commits the frames written to the areas returned by BeginWrite.
+func (s *OutStream) EndWrite() error {
+	if s.areas == nil {
+		return soundio.ErrorInvalid
+	}
+	samples := make([]float32, s.areas.FrameCount()*s.areas.ChannelCount())
+	s.areas.ReadInterleavedFloat32(samples)
+	s.areas = nil
+
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	if s.closed {
+		return soundio.ErrClosed
+	}
+	s.written = append(s.written, samples...)
+	s.fill += s.pending
+	s.pending = 0
+	return nil
+}
+
+// ClearBuffer drops the frames that have not been played yet.
+func (s *OutStream) ClearBuffer() error {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	if s.closed {
+		return soundio.ErrClosed
+	}
+	s.fill = 0
+	return nil
+}
+
+// Volume returns volume of stream.
+func (s *OutStream) Volume() float32 {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	return s.volume
+}
+
+// SetVolume sets volume of stream. It does not change the samples written.
+func (s *OutStream) SetVolume(volume float64) error {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	if s.closed {
+		return soundio.ErrClosed
+	}
+	s.volume = float32(volume)
+	return nil
+}
+
+// Latency returns the number of seconds of audio in the buffer.
+func (s *OutStream) Latency(outLatency float64) (float64, error) {
+	return s.bufferLatency()
+}
+
+// Close stops the stream.
+func (s *OutStream) Close() error {
+	if s.markClosed() {
+		s.ctx.removeStream(s)
+	}
+	return nil
+}
+
+var (
+	_ sio.InStream  = (*InStream)(nil)
+	_ sio.OutStream = (*OutStream)(nil)
+)
@@ -0,0 +1,155 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package sio
+
+import (
+	soundio "github.com/crow-misia/go-libsoundio"
+)
+
+// Wrap returns a Context backed by the cgo bindings.
+func Wrap(s *soundio.SoundIo) Context {
+	return &soundIo{SoundIo: s}
+}
+
+// Unwrap returns the SoundIo of a Context returned by Wrap, or nil.
+func Unwrap(c Context) *soundio.SoundIo {
+	if s, ok := c.(*soundIo); ok {
+		return s.SoundIo
+	}
+	return nil
+}
+
+type soundIo struct {
+	*soundio.SoundIo
+}
+
+func (s *soundIo) InputDevice(index int) Device {
+	return wrapDevice(s.SoundIo.InputDevice(index))
+}
+
+func (s *soundIo) OutputDevice(index int) Device {
+	return wrapDevice(s.SoundIo.OutputDevice(index))
+}
+
+type device struct {
+	*soundio.Device
+}
+
+func wrapDevice(d *soundio.Device) Device {
+	if d == nil {
+		return nil
+	}
+	return &device{Device: d}
+}
+
+func (d *device) NewInStream(config *soundio.InStreamConfig) (InStream, error) {
+	s, err := d.Device.NewInStream(config)
+	if err != nil {
+		return nil, err
+	}
+	return &inStream{InStream: s, device: d}, nil
+}
+
+func (d *device) NewOutStream(config *soundio.OutStreamConfig) (OutStream, error) {
+	s, err := d.Device.NewOutStream(config)
+	if err != nil {
+		return nil, err
+	}
+	return &outStream{OutStream: s, device: d}, nil
+}
+
+// inStream passes itself to the callbacks, which are wrapped once when set,
+// so that callbacks do not allocate.
+type inStream struct {
+	*soundio.InStream
+	device *device
+}
+
+func (s *inStream) Device() Device {
+	return s.device
+}
+
+func (s *inStream) SetReadCallback(callback func(stream InStream, frameCountMin int, frameCountMax int)) {
+	if callback == nil {
+		s.InStream.SetReadCallback(nil)
+		return
+	}
+	s.InStream.SetReadCallback(func(_ *soundio.InStream, frameCountMin int, frameCountMax int) {
+		callback(s, frameCountMin, frameCountMax)
+	})
+}
+
+func (s *inStream) SetOverflowCallback(callback func(stream InStream)) {
+	if callback == nil {
+		s.InStream.SetOverflowCallback(nil)
+		return
+	}
+	s.InStream.SetOverflowCallback(func(*soundio.InStream) {
+		callback(s)
+	})
+}
+
+func (s *inStream) SetErrorCallback(callback func(stream InStream, err error)) {
+	if callback == nil {
+		s.InStream.SetErrorCallback(nil)
+		return
+	}
+	s.InStream.SetErrorCallback(func(_ *soundio.InStream, err error) {
+		callback(s, err)
+	})
+}
+
+// outStream passes itself to the callbacks, which are wrapped once when set,
+// so that callbacks do not allocate.
+type outStream struct {
+	*soundio.OutStream
+	device *device
+}
+
+func (s *outStream) Device() Device {
+	return s.device
+}
+
+func (s *outStream) SetWriteCallback(callback func(stream OutStream, frameCountMin int, frameCountMax int)) {
+	if callback == nil {
+		s.OutStream.SetWriteCallback(nil)
+		return
+	}
+	s.OutStream.SetWriteCallback(func(_ *soundio.OutStream, frameCountMin int, frameCountMax int) {
+		callback(s, frameCountMin, frameCountMax)
+	})
+}
+
+func (s *outStream) SetUnderflowCallback(callback func(stream OutStream)) {
+	if callback == nil {
+		s.OutStream.SetUnderflowCallback(nil)
+		return
+	}
+	s.OutStream.SetUnderflowCallback(func(*soundio.OutStream) {
+		callback(s)
+	})
+}
+
+func (s *outStream) SetErrorCallback(callback func(stream OutStream, err error)) {
+	if callback == nil {
+		s.OutStream.SetErrorCallback(nil)
+		return
+	}
+	s.OutStream.SetErrorCallback(func(_ *soundio.OutStream, err error) {
+		callback(s, err)
+	})
+}
+
+var (
+	_ Context   = (*soundIo)(nil)
+	_ Device    = (*device)(nil)
+	_ InStream  = (*inStream)(nil)
+	_ OutStream = (*outStream)(nil)
+)
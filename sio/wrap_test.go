@@ -0,0 +1,75 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package sio
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	soundio "github.com/crow-misia/go-libsoundio"
+)
+
+func TestWrapOutStream(t *testing.T) {
+	if !soundio.BackendDummy.Have() {
+		t.Skip("libsoundio was compiled without the dummy backend")
+	}
+	s := soundio.Create(soundio.WithBackend(soundio.BackendDummy))
+	c := Wrap(s)
+	defer func() {
+		if err := c.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	if Unwrap(c) != s {
+		t.Error("Unwrap did not return the wrapped SoundIo")
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatalf("unable to connect to dummy backend: %s", err)
+	}
+
+	device := c.OutputDevice(c.DefaultOutputDeviceIndex())
+	if device == nil {
+		t.Fatal("no default output device")
+	}
+	defer device.Close()
+	stream, err := device.NewOutStream(&soundio.OutStreamConfig{})
+	if err != nil {
+		t.Fatalf("unable to open output stream: %s", err)
+	}
+	defer stream.Close()
+	if stream.Device() != device {
+		t.Error("Device() did not return the wrapped device")
+	}
+
+	errStop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	stream.SetWriteCallback(func(got OutStream, frameCountMin int, frameCountMax int) {
+		if got != stream {
+			t.Error("write callback was not passed the wrapped stream")
+		}
+		cancel(errStop)
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stream.Run(ctx)
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errStop) {
+			t.Errorf("Run() = %v, want %v", err, errStop)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+}
@@ -2,19 +2,22 @@
 
 #include "soundio.h"
 
-void setSoundIoCallback(struct SoundIo *io) {
+void setSoundIoCallback(struct SoundIo *io, uintptr_t handle) {
+	io->userdata = (void *) handle;
 	io->on_devices_change = soundioOnDevicesChange;
 	io->on_backend_disconnect = soundioOnBackendDisconnect;
 	io->on_events_signal = soundioOnEventsSignal;
 }
 
-void setInStreamCallback(struct SoundIoInStream *stream) {
+void setInStreamCallback(struct SoundIoInStream *stream, uintptr_t handle) {
+	stream->userdata = (void *) handle;
 	stream->read_callback = instreamReadCallbackDelegate;
 	stream->overflow_callback = instreamOverflowCallbackDelegate;
 	stream->error_callback = instreamErrorCallbackDelegate;
 }
 
-void setOutStreamCallback(struct SoundIoOutStream *stream) {
+void setOutStreamCallback(struct SoundIoOutStream *stream, uintptr_t handle) {
+	stream->userdata = (void *) handle;
 	stream->write_callback = outstreamWriteCallbackDelegate;
 	stream->underflow_callback = outstreamUnderflowCallbackDelegate;
 	stream->error_callback = outstreamErrorCallbackDelegate;
@@ -16,58 +16,153 @@ package soundio
 import "C"
 import (
 	"context"
+	"errors"
+	"log"
 	"runtime"
+	"runtime/cgo"
+	"sync"
+	"sync/atomic"
 	"unsafe"
-)
-
-const (
-	// MaxChannels is support channel max count.
-	MaxChannels int = C.SOUNDIO_MAX_CHANNELS
+	"weak"
 )
 
 // SoundIo is used for selecting and initializing the relevant backends.
 type SoundIo struct {
 	backend             Backend
 	ptr                 *C.struct_SoundIo
+	handle              cgo.Handle
 	appName             string
 	onDevicesChange     func(*SoundIo)
 	onBackendDisconnect func(*SoundIo, error)
 	onEventsSignal      func(*SoundIo)
+	tracker             *refTracker
+
+	listenersMu         sync.Mutex
+	listeners           map[int]func()
+	disconnectListeners map[int]func(error)
+	nextListenerKey     int
+}
+
+// soundIoFromUserdata returns the SoundIo registered for a native context.
+// It returns nil once the SoundIo has become unreachable.
+func soundIoFromUserdata(userdata unsafe.Pointer) *SoundIo {
+	p, ok := handleValue[weak.Pointer[SoundIo]](userdata)
+	if !ok {
+		return nil
+	}
+	return p.Value()
 }
 
 //export soundioOnDevicesChange
 func soundioOnDevicesChange(nativeIo *C.struct_SoundIo) {
-	io := (*SoundIo)(nativeIo.userdata)
+	io := soundIoFromUserdata(nativeIo.userdata)
+	if io == nil {
+		return
+	}
 	if io.onDevicesChange != nil {
 		io.onDevicesChange(io)
 	}
+	io.notifyDevicesChange()
 }
 
 //export soundioOnBackendDisconnect
 func soundioOnBackendDisconnect(nativeIo *C.struct_SoundIo, err C.int) {
-	io := (*SoundIo)(nativeIo.userdata)
+	io := soundIoFromUserdata(nativeIo.userdata)
+	if io == nil {
+		return
+	}
+	opErr := &OpError{Op: "backend", Backend: io.CurrentBackend(), Err: convertToError(err)}
 	if io.onBackendDisconnect != nil {
-		io.onBackendDisconnect(io, convertToError(err))
+		io.onBackendDisconnect(io, opErr)
 	}
+	io.notifyBackendDisconnect(opErr)
 }
 
 //export soundioOnEventsSignal
 func soundioOnEventsSignal(nativeIo *C.struct_SoundIo) {
-	io := (*SoundIo)(nativeIo.userdata)
-	if io.onEventsSignal != nil {
+	io := soundIoFromUserdata(nativeIo.userdata)
+	if io != nil && io.onEventsSignal != nil {
 		io.onEventsSignal(io)
 	}
 }
 
+// AddDevicesChangeListener registers listener to be called after OnDevicesChange,
+// and returns a function that unregisters it.
+// Unlike WithOnDevicesChange, any number of listeners can be registered
+// after the SoundIo is created.
+func (s *SoundIo) AddDevicesChangeListener(listener func()) func() {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	if s.listeners == nil {
+		s.listeners = make(map[int]func())
+	}
+	key := s.nextListenerKey
+	s.nextListenerKey++
+	s.listeners[key] = listener
+	return func() {
+		s.listenersMu.Lock()
+		defer s.listenersMu.Unlock()
+		delete(s.listeners, key)
+	}
+}
+
+// AddBackendDisconnectListener registers listener to be called after OnBackendDisconnect,
+// and returns a function that unregisters it.
+func (s *SoundIo) AddBackendDisconnectListener(listener func(error)) func() {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	if s.disconnectListeners == nil {
+		s.disconnectListeners = make(map[int]func(error))
+	}
+	key := s.nextListenerKey
+	s.nextListenerKey++
+	s.disconnectListeners[key] = listener
+	return func() {
+		s.listenersMu.Lock()
+		defer s.listenersMu.Unlock()
+		delete(s.disconnectListeners, key)
+	}
+}
+
+func (s *SoundIo) notifyBackendDisconnect(err error) {
+	s.listenersMu.Lock()
+	listeners := make([]func(error), 0, len(s.disconnectListeners))
+	for _, listener := range s.disconnectListeners {
+		listeners = append(listeners, listener)
+	}
+	s.listenersMu.Unlock()
+	for _, listener := range listeners {
+		listener(err)
+	}
+}
+
+func (s *SoundIo) notifyDevicesChange() {
+	s.listenersMu.Lock()
+	listeners := make([]func(), 0, len(s.listeners))
+	for _, listener := range s.listeners {
+		listeners = append(listeners, listener)
+	}
+	s.listenersMu.Unlock()
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
 // fields
 
 // CurrentBackend returns current backend.
 func (s *SoundIo) CurrentBackend() Backend {
+	if s.ptr == nil {
+		return BackendNone
+	}
 	return Backend(int(s.ptr.current_backend))
 }
 
 // AppName returns application name.
 func (s *SoundIo) AppName() string {
+	if s.ptr == nil {
+		return s.appName
+	}
 	return C.GoString(s.ptr.app_name)
 }
 
@@ -93,24 +188,6 @@ func VersionPatch() int {
 	return int(C.soundio_version_patch())
 }
 
-// BytesPerSample returns bytes per sample.
-// Returns -1 on invalid format.
-func BytesPerSample(format Format) int {
-	return int(C.soundio_get_bytes_per_sample(uint32(format)))
-}
-
-// BytesPerFrame returns bytes per frame.
-// A frame is one sample per channel.
-func BytesPerFrame(format Format, channelCount int) int {
-	return int(C.soundio_get_bytes_per_frame(uint32(format), C.int(channelCount)))
-}
-
-// BytesPerSecond returns bytes per second.
-// Sample rate is the number of frames per second.
-func BytesPerSecond(format Format, channelCount int, sampleRate int) int {
-	return int(C.soundio_get_bytes_per_second(uint32(format), C.int(channelCount), C.int(sampleRate)))
-}
-
 // Create a SoundIo context. You may create multiple instances of this to connect to multiple backends. Sets all fields to defaults.
 func Create(opts ...Option) *SoundIo {
 	ptr := C.soundio_create()
@@ -118,26 +195,55 @@ func Create(opts ...Option) *SoundIo {
 		backend: BackendNone,
 		ptr:     ptr,
 		appName: "SoundIo",
+		tracker: &refTracker{},
 	}
-	ptr.userdata = unsafe.Pointer(io)
-	C.setSoundIoCallback(ptr)
+	// the handle refers to io weakly, so that the finalizer can still run.
+	var userdata C.uintptr_t
+	io.handle, userdata = newHandle(weak.Make(io))
+	C.setSoundIoCallback(ptr, userdata)
 
 	for _, opt := range opts {
 		opt(io)
 	}
 	io.ptr.app_name = C.CString(io.appName)
 
-	runtime.SetFinalizer(io, destroySoundIo)
+	runtime.SetFinalizer(io, finalizeSoundIo)
 
 	return io
 }
 
-// destroySoundIo releases resources.
-func destroySoundIo(s *SoundIo) {
+// Close closes streams and releases devices that are still outstanding,
+// and then disconnects and releases resources.
+// It returns a *LeakError describing the streams and devices, if any.
+// Calling Close more than once does nothing, and other methods return
+// ErrClosed or zero values after Close.
+func (s *SoundIo) Close() error {
+	if s.ptr == nil {
+		return nil
+	}
+	runtime.SetFinalizer(s, nil)
+	return s.close()
+}
+
+func (s *SoundIo) close() error {
+	leaks := s.tracker.close()
+	C.free(unsafe.Pointer(s.ptr.app_name))
+	C.soundio_destroy(s.ptr)
+	s.ptr = nil
+	deleteHandle(&s.handle)
+	if len(leaks) > 0 {
+		return &LeakError{Leaks: leaks}
+	}
+	return nil
+}
+
+// finalizeSoundIo closes a SoundIo that was not closed.
+func finalizeSoundIo(s *SoundIo) {
 	if s.ptr != nil {
-		C.free(unsafe.Pointer(s.ptr.app_name))
-		C.soundio_destroy(s.ptr)
-		s.ptr = nil
+		log.Printf("soundio: SoundIo %q was not closed", s.appName)
+		if err := s.close(); err != nil {
+			log.Print(err)
+		}
 	}
 }
 
@@ -145,6 +251,9 @@ func destroySoundIo(s *SoundIo) {
 
 // Connect tries to connect on all available backends in order.
 func (s *SoundIo) Connect() error {
+	if s.ptr == nil {
+		return ErrClosed
+	}
 	var err error
 	if s.backend == BackendNone {
 		err = convertToError(C.soundio_connect(s.ptr))
@@ -152,72 +261,119 @@ func (s *SoundIo) Connect() error {
 		err = convertToError(C.soundio_connect_backend(s.ptr, uint32(s.backend)))
 	}
 
-	if err == nil {
-		s.FlushEvents()
+	if err != nil {
+		return &OpError{Op: "connect", Backend: s.backend, Err: err}
 	}
-
-	return err
+	s.FlushEvents()
+	return nil
 }
 
 // Disconnect disconnect from backend.
 func (s *SoundIo) Disconnect() {
+	if s.ptr == nil {
+		return
+	}
 	C.soundio_disconnect(s.ptr)
 }
 
 // BackendCount returns the number of available backends.
 func (s *SoundIo) BackendCount() int {
+	if s.ptr == nil {
+		return 0
+	}
 	return int(C.soundio_backend_count(s.ptr))
 }
 
 // Backend returns the available backend at the specified index (0 <= index < BackendCount)
 func (s *SoundIo) Backend(index int) Backend {
+	if s.ptr == nil {
+		return BackendNone
+	}
 	return Backend(C.soundio_get_backend(s.ptr, C.int(index)))
 }
 
 // FlushEvents atomically updates information for all connected devices.
 func (s *SoundIo) FlushEvents() {
+	if s.ptr == nil {
+		return
+	}
 	C.soundio_flush_events(s.ptr)
 }
 
 // WaitEvents calls FlushEvents then blocks until context canceled.
+// The SoundIo may be closed once it returns.
 func (s *SoundIo) WaitEvents(ctx context.Context) error {
-	go func() {
-		select {
-		case <-ctx.Done():
-			if s.CurrentBackend() != BackendNone {
-				C.soundio_wakeup(s.ptr)
-			}
+	if s.ptr == nil {
+		return ErrClosed
+	}
+	woken := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(woken)
+		if s.CurrentBackend() != BackendNone {
+			C.soundio_wakeup(s.ptr)
+		}
+	})
+	// the wakeup must be done before returning, as Close may follow.
+	defer func() {
+		if !stop() {
+			<-woken
 		}
 	}()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			if s.CurrentBackend() != BackendNone {
-				C.soundio_wait_events(s.ptr)
-			} else {
-				return ctx.Err()
-			}
+	for ctx.Err() == nil {
+		if s.CurrentBackend() == BackendNone {
+			break
 		}
+		C.soundio_wait_events(s.ptr)
+	}
+	return ctx.Err()
+}
+
+// runStream starts a stream and waits events until ctx is done,
+// or a stream error is passed to the function stored in stop.
+// The stream is paused before returning, if it was started.
+func (s *SoundIo) runStream(ctx context.Context, start func() error, pause func(bool) error, stop *atomic.Pointer[context.CancelCauseFunc]) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stop.Store(&cancel)
+	defer stop.Store(nil)
+
+	if err := start(); err != nil {
+		return err
+	}
+	// backends that cannot pause keep calling the callbacks until Close.
+	defer func() {
+		_ = pause(true)
+	}()
+	if err := s.WaitEvents(ctx); errors.Is(err, ErrClosed) {
+		return err
 	}
+	return context.Cause(ctx)
 }
 
 // ForceDeviceScan rescan device If necessary.
 func (s *SoundIo) ForceDeviceScan() {
+	if s.ptr == nil {
+		return
+	}
 	C.soundio_force_device_scan(s.ptr)
 }
 
 // InputDeviceCount returns the number of input devices.
 // Returns -1 if you never called FlushEvents.
 func (s *SoundIo) InputDeviceCount() int {
+	if s.ptr == nil {
+		return -1
+	}
 	return int(C.soundio_input_device_count(s.ptr))
 }
 
 // OutputDeviceCount returns the number of output devices.
 // Returns -1 if you never called FlushEvents.
 func (s *SoundIo) OutputDeviceCount() int {
+	if s.ptr == nil {
+		return -1
+	}
 	return int(C.soundio_output_device_count(s.ptr))
 }
 
@@ -225,24 +381,36 @@ func (s *SoundIo) OutputDeviceCount() int {
 // Call RemoveReference when done.
 // `index` must be 0 <= index < InputDeviceCount.
 func (s *SoundIo) InputDevice(index int) *Device {
-	return newDevice(C.soundio_get_input_device(s.ptr, C.int(index)))
+	if s.ptr == nil {
+		return nil
+	}
+	return newDevice(s, C.soundio_get_input_device(s.ptr, C.int(index)))
 }
 
 // OutputDevice returns a device.
 // Call RemoveReference when done.
 // `index` must be 0 <= index < OutputDeviceCount
 func (s *SoundIo) OutputDevice(index int) *Device {
-	return newDevice(C.soundio_get_output_device(s.ptr, C.int(index)))
+	if s.ptr == nil {
+		return nil
+	}
+	return newDevice(s, C.soundio_get_output_device(s.ptr, C.int(index)))
 }
 
 // DefaultInputDeviceIndex returns the index of the default input device
 // returns -1 if there are no devices or if you never called FlushEvents.
 func (s *SoundIo) DefaultInputDeviceIndex() int {
+	if s.ptr == nil {
+		return -1
+	}
 	return int(C.soundio_default_input_device_index(s.ptr))
 }
 
 // DefaultOutputDeviceIndex returns the index of the default output device
 // returns -1 if there are no devices or if you never called FlushEvents.
 func (s *SoundIo) DefaultOutputDeviceIndex() int {
+	if s.ptr == nil {
+		return -1
+	}
 	return int(C.soundio_default_output_device_index(s.ptr))
 }
@@ -1,10 +1,11 @@
 #ifndef _C_SOUNDIO_H_
 #define _C_SOUNDIO_H_
 
+#include <stdint.h>
 #include <soundio/soundio.h>
 
-extern void setSoundIoCallback(struct SoundIo *);
-extern void setInStreamCallback(struct SoundIoInStream *);
-extern void setOutStreamCallback(struct SoundIoOutStream *);
+extern void setSoundIoCallback(struct SoundIo *, uintptr_t);
+extern void setInStreamCallback(struct SoundIoInStream *, uintptr_t);
+extern void setOutStreamCallback(struct SoundIoOutStream *, uintptr_t);
 
 #endif // _C_SOUNDIO_H_
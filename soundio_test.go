@@ -0,0 +1,233 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// newDummySoundIo returns a SoundIo connected to the dummy backend.
+func newDummySoundIo(t testing.TB) *SoundIo {
+	t.Helper()
+	if !BackendDummy.Have() {
+		t.Skip("libsoundio was compiled without the dummy backend")
+	}
+	s := Create(WithBackend(BackendDummy))
+	if err := s.Connect(); err != nil {
+		t.Fatalf("unable to connect to dummy backend: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return s
+}
+
+func TestCallbacksUnderGC(t *testing.T) {
+	if !BackendDummy.Have() {
+		t.Skip("libsoundio was compiled without the dummy backend")
+	}
+	devicesChanged := make(chan struct{}, 1)
+	s := Create(WithBackend(BackendDummy), WithOnDevicesChange(func(io *SoundIo) {
+		select {
+		case devicesChanged <- struct{}{}:
+		default:
+		}
+	}))
+	if err := s.Connect(); err != nil {
+		t.Fatalf("unable to connect to dummy backend: %s", err)
+	}
+	defer s.Disconnect()
+
+	select {
+	case <-devicesChanged:
+	default:
+		t.Error("devices change callback was not called")
+	}
+
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	defer device.RemoveReference()
+	stream, err := device.NewOutStream(&OutStreamConfig{})
+	if err != nil {
+		t.Fatalf("unable to open output stream: %s", err)
+	}
+	defer stream.Close()
+
+	const wantCalls = 5
+	calls := make(chan struct{}, wantCalls)
+	stream.SetWriteCallback(func(stream *OutStream, frameCountMin int, frameCountMax int) {
+		// move and collect as much as possible while C holds the stream.
+		runtime.GC()
+		frameCount := frameCountMax
+		if frameCount > 0 {
+			if _, err := stream.BeginWrite(&frameCount); err == nil {
+				_ = stream.EndWrite()
+			}
+		}
+		select {
+		case calls <- struct{}{}:
+		default:
+		}
+	})
+	if err := stream.Start(); err != nil {
+		t.Fatalf("unable to start output stream: %s", err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < wantCalls; i++ {
+		select {
+		case <-calls:
+		case <-timeout:
+			t.Fatalf("write callback was called %d times, want %d", i, wantCalls)
+		}
+	}
+}
+
+func TestCloseReportsLeaks(t *testing.T) {
+	if !BackendDummy.Have() {
+		t.Skip("libsoundio was compiled without the dummy backend")
+	}
+	s := Create(WithBackend(BackendDummy))
+	if err := s.Connect(); err != nil {
+		t.Fatalf("unable to connect to dummy backend: %s", err)
+	}
+
+	closed := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	if err := closed.Close(); err != nil {
+		t.Errorf("unable to close device: %s", err)
+	}
+	if err := closed.Close(); err != nil {
+		t.Errorf("unable to close device twice: %s", err)
+	}
+
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	if _, err := device.NewOutStream(&OutStreamConfig{Name: "leaked"}); err != nil {
+		t.Fatalf("unable to open output stream: %s", err)
+	}
+
+	var leakErr *LeakError
+	if err := s.Close(); !errors.As(err, &leakErr) {
+		t.Fatalf("Close() = %v, want a LeakError", err)
+	}
+	if len(leakErr.Leaks) != 2 || leakErr.Leaks[0].Kind != "device" || leakErr.Leaks[1].Name != "leaked" {
+		t.Errorf("leaks = %v", leakErr.Leaks)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+	if layouts := device.Layouts(); layouts != nil || device.RefCount() != 0 {
+		t.Errorf("device released by Close() has layouts %v and %d references", layouts, device.RefCount())
+	}
+}
+
+func TestUseAfterClose(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	stream, err := device.NewOutStream(&OutStreamConfig{})
+	if err != nil {
+		t.Fatalf("unable to open output stream: %s", err)
+	}
+
+	if err := stream.Close(); err != nil {
+		t.Errorf("unable to close stream: %s", err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+	if err := stream.Start(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Start() = %v, want ErrClosed", err)
+	}
+	frameCount := 1
+	if _, err := stream.BeginWrite(&frameCount); !errors.Is(err, ErrClosed) {
+		t.Errorf("BeginWrite() = %v, want ErrClosed", err)
+	}
+	if format := stream.Format(); format != FormatInvalid {
+		t.Errorf("Format() = %v, want FormatInvalid", format)
+	}
+
+	_ = device.Close()
+	if _, err := device.NewOutStream(&OutStreamConfig{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("NewOutStream() on released device = %v, want ErrClosed", err)
+	}
+	if id, formats := device.ID(), device.Formats(); id != "" || formats != nil {
+		t.Errorf("released device has id %q and formats %v", id, formats)
+	}
+	if device.SupportsSampleRate(device.SampleRateCurrent()) || device.Equal(device) {
+		t.Error("released device supports its sample rate or equals itself")
+	}
+	if err := device.ProbeError(); !errors.Is(err, ErrClosed) {
+		t.Errorf("ProbeError() on released device = %v, want ErrClosed", err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Errorf("unable to close: %s", err)
+	}
+	if err := s.Connect(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Connect() = %v, want ErrClosed", err)
+	}
+	if err := s.WaitEvents(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("WaitEvents() = %v, want ErrClosed", err)
+	}
+	if count := s.OutputDeviceCount(); count != -1 {
+		t.Errorf("OutputDeviceCount() = %d, want -1", count)
+	}
+	if device := s.OutputDevice(0); device != nil {
+		t.Errorf("OutputDevice() = %v, want nil", device)
+	}
+	s.FlushEvents()
+	s.Disconnect()
+}
+
+func TestCloseAfterWaitEvents(t *testing.T) {
+	if !BackendDummy.Have() {
+		t.Skip("libsoundio was compiled without the dummy backend")
+	}
+	for i := 0; i < 20; i++ {
+		s := Create(WithBackend(BackendDummy))
+		if err := s.Connect(); err != nil {
+			t.Fatalf("unable to connect to dummy backend: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		if err := s.WaitEvents(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("WaitEvents() = %v, want %v", err, context.DeadlineExceeded)
+		}
+		cancel()
+		if err := s.Close(); err != nil {
+			t.Errorf("unable to close: %s", err)
+		}
+	}
+}
+
+func TestFinalizedDeviceReleasedByClose(t *testing.T) {
+	if !BackendDummy.Have() {
+		t.Skip("libsoundio was compiled without the dummy backend")
+	}
+	s := Create(WithBackend(BackendDummy))
+	if err := s.Connect(); err != nil {
+		t.Fatalf("unable to connect to dummy backend: %s", err)
+	}
+
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	refCount := device.RefCount()
+	finalizeDevice(device)
+	if device.released() || device.RefCount() != refCount {
+		t.Errorf("finalizer released the device, %d references left", device.RefCount())
+	}
+
+	var leakErr *LeakError
+	if err := s.Close(); !errors.As(err, &leakErr) || len(leakErr.Leaks) != 1 || leakErr.Leaks[0].Kind != "device" {
+		t.Errorf("Close() = %v, want the finalized device leaked", err)
+	}
+}
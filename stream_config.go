@@ -0,0 +1,36 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+// InStreamConfig is config of input stream.
+type InStreamConfig struct {
+	// Format
+	Format Format
+	// SampleRate
+	SampleRate int
+	// Layout
+	Layout *ChannelLayout
+	// SoftwareLatency
+	SoftwareLatency float64
+	// Name
+	Name string
+}
+
+// OutStreamConfig is config of output stream.
+type OutStreamConfig struct {
+	// Format
+	Format Format
+	// SampleRate
+	SampleRate int
+	// Layout
+	Layout *ChannelLayout
+	// SoftwareLatency
+	SoftwareLatency float64
+	// Name
+	Name string
+}
@@ -0,0 +1,338 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// StreamState is state of a supervised stream.
+type StreamState int
+
+// StreamState enumeration.
+const (
+	StreamOpening    StreamState = iota // the stream is being opened
+	StreamRunning                       // the stream is opened and started
+	StreamRecovering                    // waiting to open the stream again after an error
+	StreamStopped                       // Run returned
+)
+
+func (s StreamState) String() string {
+	switch s {
+	case StreamOpening:
+		return "Opening"
+	case StreamRunning:
+		return "Running"
+	case StreamRecovering:
+		return "Recovering"
+	case StreamStopped:
+		return "Stopped"
+	default:
+		return ""
+	}
+}
+
+// StreamStateEvent is a state change of a supervised stream.
+type StreamStateEvent struct {
+	State StreamState
+	// DeviceID is the id of the device the stream is opened on, when StreamRunning.
+	DeviceID string
+	// Attempt is the number of retries since the stream last ran.
+	Attempt int
+	// Err is the error that caused StreamRecovering or StreamStopped.
+	Err error
+}
+
+// SupervisorOption is option function of supervised streams.
+type SupervisorOption func(*supervisorConfig)
+
+type supervisorConfig struct {
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	maxRetries    int
+	onStateChange func(StreamStateEvent)
+}
+
+// WithBackoff sets the delay before the first retry, which doubles up to maxDelay.
+// Defaults to 100 milliseconds and 5 seconds.
+func WithBackoff(delay time.Duration, maxDelay time.Duration) SupervisorOption {
+	return func(c *supervisorConfig) {
+		c.minBackoff = delay
+		c.maxBackoff = max(delay, maxDelay)
+	}
+}
+
+// WithMaxRetries sets the number of retries before Run gives up.
+// Defaults to 0, which retries until the context is done.
+func WithMaxRetries(retries int) SupervisorOption {
+	return func(c *supervisorConfig) {
+		c.maxRetries = retries
+	}
+}
+
+// WithOnStateChange is state change callback setter.
+func WithOnStateChange(callback func(event StreamStateEvent)) SupervisorOption {
+	return func(c *supervisorConfig) {
+		c.onStateChange = callback
+	}
+}
+
+// supervisor opens a stream on a device again after errors,
+// until the context is done.
+type supervisor struct {
+	io       *SoundIo
+	aim      DeviceAim
+	deviceID string
+	raw      bool
+	config   supervisorConfig
+}
+
+// runCloser is a stream opened by a supervisor.
+type runCloser interface {
+	Run(ctx context.Context) error
+	Close() error
+}
+
+func newSupervisor(d *Device, opts []SupervisorOption) supervisor {
+	config := supervisorConfig{
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return supervisor{io: d.io, aim: d.Aim(), deviceID: d.ID(), raw: d.Raw(), config: config}
+}
+
+func (v *supervisor) run(ctx context.Context, open func(d *Device) (runCloser, error)) error {
+	attempt := 0
+	reconnect := false
+	for {
+		v.emit(StreamStateEvent{State: StreamOpening, Attempt: attempt})
+		started := time.Now()
+		err := v.runOnce(ctx, attempt, reconnect, open)
+		if ctx.Err() != nil {
+			err = context.Cause(ctx)
+			v.emit(StreamStateEvent{State: StreamStopped, Attempt: attempt, Err: err})
+			return err
+		}
+
+		// a stream that ran for a while starts over with the shortest delay.
+		if time.Since(started) > v.config.maxBackoff {
+			attempt = 0
+		}
+		attempt++
+		if !retryable(err) || (v.config.maxRetries > 0 && attempt > v.config.maxRetries) {
+			v.emit(StreamStateEvent{State: StreamStopped, Attempt: attempt, Err: err})
+			return err
+		}
+		reconnect = IsDisconnect(err) || v.io.CurrentBackend() == BackendNone
+		v.emit(StreamStateEvent{State: StreamRecovering, Attempt: attempt, Err: err})
+
+		timer := time.NewTimer(backoffDelay(attempt, v.config.minBackoff, v.config.maxBackoff))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = context.Cause(ctx)
+			v.emit(StreamStateEvent{State: StreamStopped, Attempt: attempt, Err: err})
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// runOnce opens the stream and runs it until it fails or ctx is done.
+func (v *supervisor) runOnce(ctx context.Context, attempt int, reconnect bool, open func(d *Device) (runCloser, error)) error {
+	if reconnect {
+		v.io.Disconnect()
+		if err := v.io.Connect(); err != nil {
+			return err
+		}
+	} else {
+		v.io.FlushEvents()
+	}
+
+	device, err := v.findDevice()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	stream, err := open(device)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	remove := v.io.AddBackendDisconnectListener(cancel)
+	defer remove()
+
+	v.emit(StreamStateEvent{State: StreamRunning, DeviceID: device.ID(), Attempt: attempt})
+	return stream.Run(ctx)
+}
+
+// findDevice returns the device with the same id, or the default device.
+func (v *supervisor) findDevice() (*Device, error) {
+	find := v.io.FindOutputDevice
+	if v.aim == DeviceAimInput {
+		find = v.io.FindInputDevice
+	}
+	if device, err := find(MatchAll(MatchID(v.deviceID), MatchRaw(v.raw))); err == nil {
+		return device, nil
+	}
+	return find(nil)
+}
+
+func (v *supervisor) emit(event StreamStateEvent) {
+	if v.config.onStateChange != nil {
+		v.config.onStateChange(event)
+	}
+}
+
+// retryable returns whether a supervised stream is opened again after err.
+func retryable(err error) bool {
+	return IsRecoverable(err) || errors.Is(err, ErrorNoSuchDevice) || errors.Is(err, ErrorInitAudioBackend)
+}
+
+// backoffDelay returns the delay before the retry attempt, starting from 1.
+func backoffDelay(attempt int, delay time.Duration, maxDelay time.Duration) time.Duration {
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+// SupervisedOutStream is an output stream that is opened again after
+// errors that require it, such as ErrorStreaming or a backend disconnect.
+// The callbacks are set on each stream it opens.
+type SupervisedOutStream struct {
+	supervisor
+	streamConfig      OutStreamConfig
+	stream            atomic.Pointer[OutStream]
+	writeCallback     func(*OutStream, int, int)
+	underflowCallback func(*OutStream)
+	errorCallback     func(*OutStream, error)
+}
+
+// NewSupervisedOutStream returns an output stream supervised by Run.
+// The stream is opened on the device with the same ID, or on the default
+// output device when it is gone. SoundIo is connected again after a
+// backend disconnect, so its devices must not be used while running.
+func (d *Device) NewSupervisedOutStream(config *OutStreamConfig, opts ...SupervisorOption) *SupervisedOutStream {
+	return &SupervisedOutStream{
+		supervisor:   newSupervisor(d, opts),
+		streamConfig: *config,
+	}
+}
+
+// Stream returns the stream opened last, or nil.
+func (s *SupervisedOutStream) Stream() *OutStream {
+	return s.stream.Load()
+}
+
+// SetWriteCallback sets WriteCallback.
+func (s *SupervisedOutStream) SetWriteCallback(callback func(stream *OutStream, frameCountMin int, frameCountMax int)) {
+	s.writeCallback = callback
+}
+
+// SetUnderflowCallback sets UnderflowCallback.
+func (s *SupervisedOutStream) SetUnderflowCallback(callback func(stream *OutStream)) {
+	s.underflowCallback = callback
+}
+
+// SetErrorCallback sets ErrorCallback.
+// It is called before the stream is opened again.
+func (s *SupervisedOutStream) SetErrorCallback(callback func(stream *OutStream, err error)) {
+	s.errorCallback = callback
+}
+
+// Run opens and runs the stream until ctx is done, opening it again with
+// exponential backoff after recoverable errors. It returns the cause of ctx
+// being canceled, or the error it gave up on.
+// FlushEvents and WaitEvents must not be called while running.
+func (s *SupervisedOutStream) Run(ctx context.Context) error {
+	return s.run(ctx, func(d *Device) (runCloser, error) {
+		stream, err := d.NewOutStream(&s.streamConfig)
+		if err != nil {
+			return nil, err
+		}
+		stream.SetWriteCallback(s.writeCallback)
+		stream.SetUnderflowCallback(s.underflowCallback)
+		stream.SetErrorCallback(s.errorCallback)
+		s.stream.Store(stream)
+		return stream, nil
+	})
+}
+
+// SupervisedInStream is an input stream that is opened again after
+// errors that require it, such as ErrorStreaming or a backend disconnect.
+// The callbacks are set on each stream it opens.
+type SupervisedInStream struct {
+	supervisor
+	streamConfig     InStreamConfig
+	stream           atomic.Pointer[InStream]
+	readCallback     func(*InStream, int, int)
+	overflowCallback func(*InStream)
+	errorCallback    func(*InStream, error)
+}
+
+// NewSupervisedInStream returns an input stream supervised by Run.
+// The stream is opened on the device with the same ID, or on the default
+// input device when it is gone. SoundIo is connected again after a
+// backend disconnect, so its devices must not be used while running.
+func (d *Device) NewSupervisedInStream(config *InStreamConfig, opts ...SupervisorOption) *SupervisedInStream {
+	return &SupervisedInStream{
+		supervisor:   newSupervisor(d, opts),
+		streamConfig: *config,
+	}
+}
+
+// Stream returns the stream opened last, or nil.
+func (s *SupervisedInStream) Stream() *InStream {
+	return s.stream.Load()
+}
+
+// SetReadCallback sets ReadCallback.
+func (s *SupervisedInStream) SetReadCallback(callback func(stream *InStream, frameCountMin int, frameCountMax int)) {
+	s.readCallback = callback
+}
+
+// SetOverflowCallback sets OverflowCallback.
+func (s *SupervisedInStream) SetOverflowCallback(callback func(stream *InStream)) {
+	s.overflowCallback = callback
+}
+
+// SetErrorCallback sets ErrorCallback.
+// It is called before the stream is opened again.
+func (s *SupervisedInStream) SetErrorCallback(callback func(stream *InStream, err error)) {
+	s.errorCallback = callback
+}
+
+// Run opens and runs the stream until ctx is done, opening it again with
+// exponential backoff after recoverable errors. It returns the cause of ctx
+// being canceled, or the error it gave up on.
+// FlushEvents and WaitEvents must not be called while running.
+func (s *SupervisedInStream) Run(ctx context.Context) error {
+	return s.run(ctx, func(d *Device) (runCloser, error) {
+		stream, err := d.NewInStream(&s.streamConfig)
+		if err != nil {
+			return nil, err
+		}
+		stream.SetReadCallback(s.readCallback)
+		stream.SetOverflowCallback(s.overflowCallback)
+		stream.SetErrorCallback(s.errorCallback)
+		s.stream.Store(stream)
+		return stream, nil
+	})
+}
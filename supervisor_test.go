@@ -0,0 +1,187 @@
+//go:build cgo
+
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package soundio
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	var got []time.Duration
+	for attempt := 1; attempt <= 6; attempt++ {
+		got = append(got, backoffDelay(attempt, 100*time.Millisecond, time.Second))
+	}
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("delays = %v, want %v", got, want)
+	}
+}
+
+func TestSupervisedOutStreamRecovers(t *testing.T) {
+	s := newDummySoundIo(t)
+	device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+	defer device.RemoveReference()
+
+	var mu sync.Mutex
+	var states []StreamState
+	supervised := device.NewSupervisedOutStream(&OutStreamConfig{},
+		WithBackoff(time.Millisecond, time.Millisecond),
+		WithOnStateChange(func(event StreamStateEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			states = append(states, event.State)
+			if event.State == StreamRecovering && !errors.Is(event.Err, ErrorStreaming) {
+				t.Errorf("recovering from %v, want ErrorStreaming", event.Err)
+			}
+		}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var streams []*OutStream
+	supervised.SetWriteCallback(func(stream *OutStream, frameCountMin int, frameCountMax int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if slices.Contains(streams, stream) {
+			return
+		}
+		streams = append(streams, stream)
+		if len(streams) == 1 {
+			// as the error callback does
+			(*stream.stopRun.Load())(stream.opError("stream", ErrorStreaming))
+		} else {
+			cancel()
+		}
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- supervised.Run(ctx)
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []StreamState{StreamOpening, StreamRunning, StreamRecovering, StreamOpening, StreamRunning, StreamStopped}
+	if !slices.Equal(states, want) {
+		t.Errorf("states = %v, want %v", states, want)
+	}
+	if len(streams) != 2 || streams[1] != supervised.Stream() {
+		t.Errorf("streams = %v", streams)
+	}
+}
+
+func TestSupervisedOutStreamReconnects(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		gone bool // the device is not found, so the default device is used
+	}{
+		{name: "same device"},
+		{name: "default device", gone: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newDummySoundIo(t)
+			device := s.OutputDevice(s.DefaultOutputDeviceIndex())
+			defer device.RemoveReference()
+			wantID := device.ID()
+
+			var mu sync.Mutex
+			var events []StreamStateEvent
+			supervised := device.NewSupervisedOutStream(&OutStreamConfig{},
+				WithBackoff(time.Millisecond, time.Millisecond),
+				WithOnStateChange(func(event StreamStateEvent) {
+					mu.Lock()
+					defer mu.Unlock()
+					events = append(events, event)
+				}))
+			if tt.gone {
+				supervised.deviceID = "gone"
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var streams []*OutStream
+			supervised.SetWriteCallback(func(stream *OutStream, frameCountMin int, frameCountMax int) {
+				mu.Lock()
+				defer mu.Unlock()
+				if slices.Contains(streams, stream) {
+					return
+				}
+				streams = append(streams, stream)
+				if len(streams) == 1 {
+					// as the backend disconnect callback does
+					s.notifyBackendDisconnect(&OpError{Op: "backend", Backend: BackendDummy, Err: ErrorBackendDisconnected})
+				} else {
+					cancel()
+				}
+			})
+
+			done := make(chan error, 1)
+			go func() {
+				done <- supervised.Run(ctx)
+			}()
+			timeout := time.After(5 * time.Second)
+		wait:
+			for {
+				select {
+				case err := <-done:
+					if !errors.Is(err, context.Canceled) {
+						t.Errorf("Run() = %v, want context.Canceled", err)
+					}
+					break wait
+				case <-timeout:
+					t.Fatal("Run did not return")
+				default:
+					// Stream is read while Run replaces it.
+					_ = supervised.Stream()
+					time.Sleep(time.Millisecond)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			var running []string
+			for _, event := range events {
+				switch event.State {
+				case StreamRunning:
+					running = append(running, event.DeviceID)
+				case StreamRecovering:
+					if !IsDisconnect(event.Err) {
+						t.Errorf("recovering from %v, want a disconnect", event.Err)
+					}
+				}
+			}
+			if !slices.Equal(running, []string{wantID, wantID}) {
+				t.Errorf("running on devices %q, want %q twice", running, wantID)
+			}
+			if len(streams) != 2 || streams[1] != supervised.Stream() {
+				t.Errorf("streams = %v", streams)
+			}
+		})
+	}
+}
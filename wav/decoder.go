@@ -0,0 +1,327 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	soundio "github.com/crow-misia/go-libsoundio"
+)
+
+// ErrInvalidFile is returned when the input is not a WAV file.
+var ErrInvalidFile = errors.New("wav: invalid file")
+
+// Decoder reads interleaved PCM from a WAV file.
+type Decoder struct {
+	r          io.Reader
+	format     soundio.Format
+	file       sampleFormat
+	sampleRate int
+	channels   []soundio.ChannelID
+	layout     *soundio.ChannelLayout
+	frames     int64
+	remaining  int64 // bytes left in the data chunk, or -1 until EOF
+	raw        []byte
+	expanded   []byte
+	pending    []byte
+}
+
+// NewDecoder reads the WAV header from r, leaving r at the first sample.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	var riff [riffHeaderSize]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, headerError(err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, ErrInvalidFile
+	}
+
+	d := &Decoder{r: r, frames: -1}
+	var fmtChunk []byte
+	for {
+		var chunk [chunkHeaderSize]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, headerError(err)
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 || size > 1024 {
+				return nil, ErrInvalidFile
+			}
+			fmtChunk = make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, fmtChunk); err != nil {
+				return nil, headerError(err)
+			}
+			if err := d.parseFormat(fmtChunk[:size]); err != nil {
+				return nil, err
+			}
+		case "data":
+			if fmtChunk == nil {
+				return nil, ErrInvalidFile
+			}
+			d.remaining = size
+			// streaming writers leave the size unset
+			if size == 0 || size == math.MaxUint32 {
+				d.remaining = -1
+			} else {
+				d.frames = size / int64(d.file.bytes*len(d.channels))
+			}
+			return d, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, headerError(err)
+			}
+		}
+	}
+}
+
+func headerError(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (d *Decoder) parseFormat(chunk []byte) error {
+	le := binary.LittleEndian
+	tag := le.Uint16(chunk[0:])
+	channelCount := int(le.Uint16(chunk[2:]))
+	d.sampleRate = int(le.Uint32(chunk[4:]))
+	blockAlign := int(le.Uint16(chunk[12:]))
+	bits := int(le.Uint16(chunk[14:]))
+
+	var mask uint32
+	if tag == formatExtensible {
+		if len(chunk) < 40 {
+			return ErrInvalidFile
+		}
+		mask = le.Uint32(chunk[20:])
+		guid := chunk[24:40]
+		tag = le.Uint16(guid)
+		if !bytes.Equal(guid, subFormatGUID(tag)) {
+			return ErrUnsupportedFormat
+		}
+	}
+	if channelCount == 0 || channelCount > soundio.MaxChannels || d.sampleRate == 0 {
+		return ErrInvalidFile
+	}
+
+	file := sampleFormat{tag: tag, bits: bits, bytes: (bits + 7) / 8}
+	switch {
+	case tag == formatPCM && file.bytes == 1:
+		d.format = soundio.FormatU8
+	case tag == formatPCM && file.bytes == 2:
+		d.format = soundio.FormatS16LE
+	case tag == formatPCM && file.bytes == 3:
+		d.format = soundio.FormatS24LE
+	case tag == formatPCM && file.bytes == 4:
+		d.format = soundio.FormatS32LE
+	case tag == formatIEEEFloat && bits == 32:
+		d.format = soundio.FormatFloat32LE
+	case tag == formatIEEEFloat && bits == 64:
+		d.format = soundio.FormatFloat64LE
+	default:
+		return ErrUnsupportedFormat
+	}
+	if blockAlign != file.bytes*channelCount {
+		return ErrInvalidFile
+	}
+	d.file = file
+
+	d.channels = maskChannels(mask, channelCount)
+	d.layout = findLayout(d.channels)
+	if d.layout != nil {
+		d.channels = d.layout.Channels()
+	}
+	return nil
+}
+
+// maskChannels returns the channels of mask, or invalid channels when
+// they do not match channelCount.
+func maskChannels(mask uint32, channelCount int) []soundio.ChannelID {
+	if mask == 0 {
+		mask = defaultChannelMask(channelCount)
+	}
+	channels := make([]soundio.ChannelID, 0, channelCount)
+	for bit := uint32(1); bit != 0 && bit <= mask; bit <<= 1 {
+		if mask&bit == 0 {
+			continue
+		}
+		for id, position := range speakerPositions {
+			if position == bit {
+				channels = append(channels, id)
+				break
+			}
+		}
+	}
+	if len(channels) != channelCount {
+		return make([]soundio.ChannelID, channelCount)
+	}
+	return channels
+}
+
+// findLayout returns the builtin layout with channels, falling back to
+// the default layout of the channel count.
+func findLayout(channels []soundio.ChannelID) *soundio.ChannelLayout {
+	for i := 0; i < soundio.ChannelLayoutBuiltinCount(); i++ {
+		layout := soundio.ChannelLayoutGetBuiltin(soundio.ChannelLayoutID(i))
+		if equalChannels(layout.Channels(), channels) {
+			return layout
+		}
+	}
+	if len(channels) <= 8 {
+		return soundio.ChannelLayoutGetDefault(len(channels))
+	}
+	return nil
+}
+
+func equalChannels(a, b []soundio.ChannelID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Format returns format of PCM returned by Read.
+// 24 bit samples are expanded to FormatS24LE, four bytes each.
+func (d *Decoder) Format() soundio.Format {
+	return d.format
+}
+
+// SampleRate returns sample rate of the file.
+func (d *Decoder) SampleRate() int {
+	return d.sampleRate
+}
+
+// ChannelCount returns channel count of the file.
+func (d *Decoder) ChannelCount() int {
+	return len(d.channels)
+}
+
+// Layout returns the builtin channel layout matching the channel mask,
+// or nil if there is none.
+func (d *Decoder) Layout() *soundio.ChannelLayout {
+	return d.layout
+}
+
+// Frames returns number of frames in the file, or -1 if unknown.
+func (d *Decoder) Frames() int64 {
+	return d.frames
+}
+
+// Read reads interleaved PCM in Format.
+func (d *Decoder) Read(p []byte) (int, error) {
+	if len(d.pending) == 0 {
+		if d.file.bytes != 3 {
+			return d.readData(p)
+		}
+		if err := d.fill(len(p)); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// fill decodes packed 24 bit samples into pending.
+func (d *Decoder) fill(size int) error {
+	samples := max(size/4, 1)
+	if cap(d.raw) < samples*3 {
+		d.raw = make([]byte, samples*3)
+		d.expanded = make([]byte, 0, samples*4)
+	}
+	n, err := io.ReadFull(dataReader{d}, d.raw[:samples*3])
+	if n < 3 {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+		return err
+	}
+	out := d.expanded[:0]
+	for i := 0; i+3 <= n; i += 3 {
+		v := int32(uint32(d.raw[i])<<8|uint32(d.raw[i+1])<<16|uint32(d.raw[i+2])<<24) >> 8
+		out = binary.LittleEndian.AppendUint32(out, uint32(v))
+	}
+	d.pending = out
+	return nil
+}
+
+// ReadFloat32 reads interleaved samples normalized to -1.0 to 1.0,
+// and returns the number of samples read. It must not be mixed with Read.
+func (d *Decoder) ReadFloat32(p []float32) (int, error) {
+	size := d.file.bytes
+	if cap(d.raw) < len(p)*size {
+		d.raw = make([]byte, len(p)*size)
+	}
+	n, err := io.ReadFull(dataReader{d}, d.raw[:len(p)*size])
+	samples := n / size
+	if samples > 0 {
+		err = nil
+	} else if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	le := binary.LittleEndian
+	for i := 0; i < samples; i++ {
+		b := d.raw[i*size:]
+		switch {
+		case d.file.tag == formatIEEEFloat && size == 4:
+			p[i] = math.Float32frombits(le.Uint32(b))
+		case d.file.tag == formatIEEEFloat:
+			p[i] = float32(math.Float64frombits(le.Uint64(b)))
+		case size == 1:
+			p[i] = float32(int(b[0])-0x80) / 0x80
+		case size == 2:
+			p[i] = float32(int16(le.Uint16(b))) / (1 << 15)
+		case size == 3:
+			p[i] = float32(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)) / (1 << 31)
+		default:
+			p[i] = float32(float64(int32(le.Uint32(b))) / (1 << 31))
+		}
+	}
+	return samples, err
+}
+
+// dataReader reads raw bytes of the data chunk.
+type dataReader struct {
+	d *Decoder
+}
+
+func (r dataReader) Read(p []byte) (int, error) {
+	return r.d.readData(p)
+}
+
+// readData reads raw bytes of the data chunk.
+func (d *Decoder) readData(p []byte) (int, error) {
+	if d.remaining == 0 {
+		return 0, io.EOF
+	}
+	if d.remaining > 0 && int64(len(p)) > d.remaining {
+		p = p[:d.remaining]
+	}
+	n, err := d.r.Read(p)
+	if d.remaining > 0 {
+		d.remaining -= int64(n)
+		if d.remaining > 0 && errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	return n, err
+}
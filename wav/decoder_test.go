@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	soundio "github.com/crow-misia/go-libsoundio"
+)
+
+func encode(t *testing.T, format soundio.Format, layout *soundio.ChannelLayout, samples []byte) []byte {
+	t.Helper()
+	f := &memFile{}
+	e, err := NewEncoder(f, format, 44100, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Write(samples); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return f.data
+}
+
+func TestDecoderRoundTrip(t *testing.T) {
+	tests := []struct {
+		format  soundio.Format
+		samples []byte
+	}{
+		{soundio.FormatU8, []byte{0x00, 0x80, 0xFF, 0x7F}},
+		{soundio.FormatS16LE, []byte{0x01, 0x80, 0xFF, 0x7F}},
+		{soundio.FormatS24LE, []byte{0x01, 0x02, 0x83, 0xFF, 0xFF, 0xFF, 0x7F, 0x00}},
+		{soundio.FormatS32LE, []byte{0x01, 0x02, 0x03, 0x84, 0xFF, 0xFF, 0xFF, 0x7F}},
+		{soundio.FormatFloat32LE, binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(nil, math.Float32bits(-0.5)), math.Float32bits(1))},
+		{soundio.FormatFloat64LE, binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint64(nil, math.Float64bits(0.25)), math.Float64bits(-1))},
+	}
+	for _, channelCount := range []int{1, 2} {
+		layout := soundio.ChannelLayoutGetDefault(channelCount)
+		for _, test := range tests {
+			if len(test.samples)%(channelCount*soundio.BytesPerSample(test.format)) != 0 {
+				continue
+			}
+			file := encode(t, test.format, layout, test.samples)
+			d, err := NewDecoder(bytes.NewReader(file))
+			if err != nil {
+				t.Fatalf("%s: %v", test.format, err)
+			}
+			if d.Format() != test.format || d.SampleRate() != 44100 || d.ChannelCount() != channelCount {
+				t.Errorf("%s: got %s %d Hz %d channels", test.format, d.Format(), d.SampleRate(), d.ChannelCount())
+			}
+			if !d.Layout().Equal(layout) {
+				t.Errorf("%s: layout = %s, want %s", test.format, d.Layout().Name(), layout.Name())
+			}
+			want := int64(len(test.samples) / (channelCount * soundio.BytesPerSample(test.format)))
+			if d.Frames() != want {
+				t.Errorf("%s: frames = %d, want %d", test.format, d.Frames(), want)
+			}
+
+			got, err := io.ReadAll(io.LimitReader(d, 1<<16))
+			if err != nil {
+				t.Fatalf("%s: %v", test.format, err)
+			}
+			if !bytes.Equal(got, test.samples) {
+				t.Errorf("%s: samples = % x, want % x", test.format, got, test.samples)
+			}
+		}
+	}
+}
+
+func TestDecoderExtensibleLayout(t *testing.T) {
+	layout := soundio.ChannelLayoutGetBuiltin(soundio.ChannelLayoutID5Point1Back)
+	file := encode(t, soundio.FormatS16LE, layout, make([]byte, 2*6))
+
+	d, err := NewDecoder(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Layout().Equal(layout) {
+		t.Errorf("layout = %s, want %s", d.Layout().Name(), layout.Name())
+	}
+}
+
+func TestDecoderReadFloat32(t *testing.T) {
+	samples := []byte{0x00, 0x00, 0xC0, 0xFF, 0xFF, 0xFF, 0x7F, 0x00}
+	file := encode(t, soundio.FormatS24LE, soundio.ChannelLayoutGetDefault(1), samples)
+	d, err := NewDecoder(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]float32, 4)
+	n, err := d.ReadFloat32(p)
+	if err != nil || n != 2 {
+		t.Fatalf("ReadFloat32 = %d, %v", n, err)
+	}
+	if p[0] != -0.5 || math.Abs(float64(p[1])-1) > 1e-6 {
+		t.Errorf("samples = %v", p[:n])
+	}
+	if _, err := d.ReadFloat32(p); !errors.Is(err, io.EOF) {
+		t.Errorf("err = %v, want EOF", err)
+	}
+}
+
+func TestDecoderInvalid(t *testing.T) {
+	if _, err := NewDecoder(bytes.NewReader([]byte("RIFX\x00\x00\x00\x00WAVE"))); !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("err = %v, want ErrInvalidFile", err)
+	}
+	if _, err := NewDecoder(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00WAVE"))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("err = %v, want ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	soundio "github.com/crow-misia/go-libsoundio"
+)
+
+const (
+	riffHeaderSize  = 12
+	chunkHeaderSize = 8
+)
+
+// Encoder writes interleaved PCM as a WAV file.
+// Sizes in the header are patched by Close, so the output must be seekable.
+type Encoder struct {
+	w          io.WriteSeeker
+	convert    func(dst []byte, src []byte)
+	srcSize    int
+	dstSize    int
+	channels   int
+	factOffset int64
+	dataOffset int64
+	dataBytes  int64
+	pending    []byte
+	buffer     []byte
+	closed     bool
+}
+
+// NewEncoder writes a WAV header for samples of format and starts a data chunk.
+// Samples are stored little endian, 8 bit samples unsigned and wider samples
+// signed, as WAV requires. 24 bit samples are packed into three bytes.
+func NewEncoder(w io.WriteSeeker, format soundio.Format, sampleRate int, layout *soundio.ChannelLayout) (*Encoder, error) {
+	file, ok := fileFormat(format)
+	convert := sampleConverter(format)
+	if !ok || convert == nil {
+		return nil, ErrUnsupportedFormat
+	}
+	channels := layout.Channels()
+	if len(channels) == 0 {
+		return nil, errors.New("wav: no channels")
+	}
+
+	e := &Encoder{
+		w:          w,
+		convert:    convert,
+		srcSize:    soundio.BytesPerSample(format),
+		dstSize:    file.bytes,
+		channels:   len(channels),
+		factOffset: -1,
+	}
+	if err := e.writeHeader(file, sampleRate, channels); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// NewStreamEncoder writes a WAV header for audio captured by stream.
+func NewStreamEncoder(w io.WriteSeeker, stream *soundio.InStream) (*Encoder, error) {
+	return NewEncoder(w, stream.Format(), stream.SampleRate(), stream.Layout())
+}
+
+func (e *Encoder) writeHeader(file sampleFormat, sampleRate int, channels []soundio.ChannelID) error {
+	mask := ChannelMask(channels)
+	extensible := len(channels) > 2 || file.bits > 16 && file.tag == formatPCM ||
+		mask != 0 && mask != defaultChannelMask(len(channels))
+	blockAlign := file.bytes * len(channels)
+
+	fmtSize := 16
+	if extensible {
+		fmtSize = 40
+	}
+	header := make([]byte, 0, riffHeaderSize+chunkHeaderSize+fmtSize+chunkHeaderSize+4+chunkHeaderSize)
+	header = append(header, "RIFF\x00\x00\x00\x00WAVE"...)
+	header = append(header, "fmt "...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(fmtSize))
+	if extensible {
+		header = binary.LittleEndian.AppendUint16(header, formatExtensible)
+	} else {
+		header = binary.LittleEndian.AppendUint16(header, file.tag)
+	}
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(channels)))
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate))
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate*blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(file.bytes*8))
+	if extensible {
+		header = binary.LittleEndian.AppendUint16(header, 22)
+		header = binary.LittleEndian.AppendUint16(header, uint16(file.bits))
+		header = binary.LittleEndian.AppendUint32(header, mask)
+		header = append(header, subFormatGUID(file.tag)...)
+	}
+	if file.tag != formatPCM {
+		e.factOffset = int64(len(header)) + chunkHeaderSize
+		header = append(header, "fact\x04\x00\x00\x00\x00\x00\x00\x00"...)
+	}
+	header = append(header, "data\x00\x00\x00\x00"...)
+	e.dataOffset = int64(len(header))
+
+	_, err := e.w.Write(header)
+	return err
+}
+
+// Write converts and appends interleaved samples in the format given to NewEncoder.
+// Partial samples are kept until the next Write. When writing fails, it
+// returns the number of bytes of p whose samples were written.
+func (e *Encoder) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("wav: write after close")
+	}
+	n := 0
+
+	if len(e.pending) > 0 {
+		fill := min(e.srcSize-len(e.pending), len(p))
+		e.pending = append(e.pending, p[:fill]...)
+		if len(e.pending) < e.srcSize {
+			return len(p), nil
+		}
+		if _, err := e.writeSamples(e.pending); err != nil {
+			e.pending = e.pending[:len(e.pending)-fill]
+			return 0, err
+		}
+		e.pending = e.pending[:0]
+		n = fill
+	}
+
+	whole := (len(p) - n) / e.srcSize * e.srcSize
+	samples, err := e.writeSamples(p[n : n+whole])
+	n += samples * e.srcSize
+	if err != nil {
+		return n, err
+	}
+	e.pending = append(e.pending, p[n:]...)
+	return len(p), nil
+}
+
+// writeSamples converts and writes whole samples, and returns the number of
+// samples written.
+func (e *Encoder) writeSamples(src []byte) (int, error) {
+	samples := len(src) / e.srcSize
+	if samples == 0 {
+		return 0, nil
+	}
+	if cap(e.buffer) < samples*e.dstSize {
+		e.buffer = make([]byte, samples*e.dstSize)
+	}
+	dst := e.buffer[:samples*e.dstSize]
+	for i := 0; i < samples; i++ {
+		e.convert(dst[i*e.dstSize:], src[i*e.srcSize:])
+	}
+	written, err := e.w.Write(dst)
+	e.dataBytes += int64(written)
+	return written / e.dstSize, err
+}
+
+// Close pads the data chunk and patches the sizes in the header.
+// It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	end := e.dataOffset + e.dataBytes
+	if e.dataBytes%2 == 1 {
+		if _, err := e.w.Write([]byte{0}); err != nil {
+			return err
+		}
+		end++
+	}
+
+	if err := e.patch(4, end-8); err != nil {
+		return err
+	}
+	if err := e.patch(e.dataOffset-4, e.dataBytes); err != nil {
+		return err
+	}
+	if e.factOffset >= 0 {
+		frames := e.dataBytes / int64(e.dstSize*e.channels)
+		if err := e.patch(e.factOffset, frames); err != nil {
+			return err
+		}
+	}
+	_, err := e.w.Seek(end, io.SeekStart)
+	return err
+}
+
+func (e *Encoder) patch(offset int64, value int64) error {
+	if _, err := e.w.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := e.w.Write(binary.LittleEndian.AppendUint32(nil, uint32(min(value, math.MaxUint32))))
+	return err
+}
+
+// defaultChannelMask returns the mask readers assume for plain PCM files.
+func defaultChannelMask(channelCount int) uint32 {
+	switch channelCount {
+	case 1:
+		return 0x4
+	case 2:
+		return 0x3
+	default:
+		return 0
+	}
+}
+
+// subFormatGUID returns the WAVE_FORMAT_EXTENSIBLE sub format of tag.
+func subFormatGUID(tag uint16) []byte {
+	guid := []byte{0, 0, 0, 0, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+	binary.LittleEndian.PutUint16(guid, tag)
+	return guid
+}
+
+// sampleConverter returns a function storing one sample of format as WAV.
+func sampleConverter(format soundio.Format) func(dst []byte, src []byte) {
+	le := binary.LittleEndian
+	be := binary.BigEndian
+	switch format {
+	case soundio.FormatU8:
+		return func(dst []byte, src []byte) { dst[0] = src[0] }
+	case soundio.FormatS8:
+		return func(dst []byte, src []byte) { dst[0] = src[0] ^ 0x80 }
+	case soundio.FormatS16LE:
+		return func(dst []byte, src []byte) { copy(dst[:2], src) }
+	case soundio.FormatS16BE:
+		return func(dst []byte, src []byte) { le.PutUint16(dst, be.Uint16(src)) }
+	case soundio.FormatU16LE:
+		return func(dst []byte, src []byte) { le.PutUint16(dst, le.Uint16(src)^0x8000) }
+	case soundio.FormatU16BE:
+		return func(dst []byte, src []byte) { le.PutUint16(dst, be.Uint16(src)^0x8000) }
+	case soundio.FormatS24LE:
+		return func(dst []byte, src []byte) { putUint24(dst, le.Uint32(src)) }
+	case soundio.FormatS24BE:
+		return func(dst []byte, src []byte) { putUint24(dst, be.Uint32(src)) }
+	case soundio.FormatU24LE:
+		return func(dst []byte, src []byte) { putUint24(dst, le.Uint32(src)^0x800000) }
+	case soundio.FormatU24BE:
+		return func(dst []byte, src []byte) { putUint24(dst, be.Uint32(src)^0x800000) }
+	case soundio.FormatS32LE, soundio.FormatFloat32LE:
+		return func(dst []byte, src []byte) { copy(dst[:4], src) }
+	case soundio.FormatS32BE, soundio.FormatFloat32BE:
+		return func(dst []byte, src []byte) { le.PutUint32(dst, be.Uint32(src)) }
+	case soundio.FormatU32LE:
+		return func(dst []byte, src []byte) { le.PutUint32(dst, le.Uint32(src)^0x80000000) }
+	case soundio.FormatU32BE:
+		return func(dst []byte, src []byte) { le.PutUint32(dst, be.Uint32(src)^0x80000000) }
+	case soundio.FormatFloat64LE:
+		return func(dst []byte, src []byte) { copy(dst[:8], src) }
+	case soundio.FormatFloat64BE:
+		return func(dst []byte, src []byte) { le.PutUint64(dst, be.Uint64(src)) }
+	default:
+		return nil
+	}
+}
+
+func putUint24(dst []byte, v uint32) {
+	dst[0] = byte(v)
+	dst[1] = byte(v >> 8)
+	dst[2] = byte(v >> 16)
+}
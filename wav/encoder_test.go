@@ -0,0 +1,177 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	soundio "github.com/crow-misia/go-libsoundio"
+)
+
+var errFull = errors.New("file full")
+
+// memFile is an in-memory io.WriteSeeker.
+type memFile struct {
+	data   []byte
+	offset int
+	// limit fails writes past limit bytes, when it is positive.
+	limit int
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if f.limit > 0 && f.offset+len(p) > f.limit {
+		n, _ := f.Write(p[:max(f.limit-f.offset, 0)])
+		return n, errFull
+	}
+	if end := f.offset + len(p); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	n := copy(f.data[f.offset:], p)
+	f.offset += n
+	return n, nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		f.offset = int(offset)
+	case io.SeekCurrent:
+		f.offset += int(offset)
+	case io.SeekEnd:
+		f.offset = len(f.data) + int(offset)
+	}
+	return int64(f.offset), nil
+}
+
+func TestEncoderStereoS16(t *testing.T) {
+	layout := soundio.ChannelLayoutGetDefault(2)
+	f := &memFile{}
+	e, err := NewEncoder(f, soundio.FormatS16BE, 48000, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one frame, split in the middle of a sample
+	for _, p := range [][]byte{{0x12}, {0x34, 0xAB}, {0xCD}} {
+		if _, err := e.Write(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	le := binary.LittleEndian
+	if len(f.data) != 48 {
+		t.Fatalf("file size = %d, want 48", len(f.data))
+	}
+	if got := le.Uint32(f.data[4:]); got != 40 {
+		t.Errorf("RIFF size = %d, want 40", got)
+	}
+	if got := le.Uint16(f.data[20:]); got != formatPCM {
+		t.Errorf("format tag = %#x, want PCM", got)
+	}
+	if got := le.Uint32(f.data[28:]); got != 48000*4 {
+		t.Errorf("byte rate = %d", got)
+	}
+	if string(f.data[36:40]) != "data" || le.Uint32(f.data[40:]) != 4 {
+		t.Errorf("data chunk header = %q", f.data[36:44])
+	}
+	if want := []byte{0x34, 0x12, 0xCD, 0xAB}; !bytes.Equal(f.data[44:], want) {
+		t.Errorf("samples = % x, want % x", f.data[44:], want)
+	}
+}
+
+func TestEncoderExtensible(t *testing.T) {
+	layout := soundio.ChannelLayoutGetDefault(6)
+	f := &memFile{}
+	e, err := NewEncoder(f, soundio.FormatFloat32LE, 44100, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]byte, 4*6)
+	for i := 0; i < 3; i++ {
+		if _, err := e.Write(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	le := binary.LittleEndian
+	if got := le.Uint16(f.data[20:]); got != formatExtensible {
+		t.Fatalf("format tag = %#x, want extensible", got)
+	}
+	if got := le.Uint32(f.data[40:]); got != ChannelMask(layout.Channels()) || got == 0 {
+		t.Errorf("channel mask = %#x", got)
+	}
+	if got := le.Uint16(f.data[44:]); got != formatIEEEFloat {
+		t.Errorf("sub format = %#x, want IEEE float", got)
+	}
+	if string(f.data[60:64]) != "fact" || le.Uint32(f.data[68:]) != 3 {
+		t.Errorf("fact chunk = % x", f.data[60:72])
+	}
+	if string(f.data[72:76]) != "data" || le.Uint32(f.data[76:]) != 3*24 {
+		t.Errorf("data chunk header = % x", f.data[72:80])
+	}
+	if got := le.Uint32(f.data[4:]); int(got) != len(f.data)-8 {
+		t.Errorf("RIFF size = %d, want %d", got, len(f.data)-8)
+	}
+}
+
+func TestEncoderS24Packed(t *testing.T) {
+	f := &memFile{}
+	e, err := NewEncoder(f, soundio.FormatS24LE, 8000, soundio.ChannelLayoutGetDefault(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Write([]byte{0x01, 0x02, 0x83, 0xFF}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := f.data[len(f.data)-4:]
+	if want := []byte{0x01, 0x02, 0x83, 0x00}; !bytes.Equal(data, want) {
+		t.Errorf("samples = % x, want % x with pad byte", data, want)
+	}
+	if got := binary.LittleEndian.Uint32(f.data[len(f.data)-8:]); got != 3 {
+		t.Errorf("data size = %d, want 3", got)
+	}
+}
+
+func TestEncoderWriteError(t *testing.T) {
+	f := &memFile{}
+	e, err := NewEncoder(f, soundio.FormatS16LE, 8000, soundio.ChannelLayoutGetDefault(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := len(f.data)
+	// room for two samples and a half
+	f.limit = header + 5
+
+	if n, err := e.Write([]byte{0x01}); n != 1 || err != nil {
+		t.Fatalf("Write() of a partial sample = %d, %v", n, err)
+	}
+	if n, err := e.Write([]byte{0x02, 0x03, 0x04, 0x05, 0x06, 0x07}); n != 3 || !errors.Is(err, errFull) {
+		t.Errorf("Write() = %d, %v, want 3 bytes of p and %v", n, err, errFull)
+	}
+
+	f.limit = 0
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := binary.LittleEndian.Uint32(f.data[header-4:]); got != 5 {
+		t.Errorf("data size = %d, want the 5 bytes written", got)
+	}
+}
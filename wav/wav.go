@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2019 Zenichi Amano
+ *
+ * This file is part of libsoundio, which is MIT licensed.
+ * See http://opensource.org/licenses/MIT
+ */
+
+// Package wav reads and writes RIFF WAVE files for audio of libsoundio streams.
+package wav
+
+import (
+	"errors"
+
+	soundio "github.com/crow-misia/go-libsoundio"
+)
+
+// WAVE format tags.
+const (
+	formatPCM        = 0x0001
+	formatIEEEFloat  = 0x0003
+	formatExtensible = 0xFFFE
+)
+
+// ErrUnsupportedFormat is returned for a sample format that cannot be stored in a WAV file.
+var ErrUnsupportedFormat = errors.New("wav: unsupported format")
+
+// speakerPositions maps channel ids to WAVE_FORMAT_EXTENSIBLE channel mask bits.
+var speakerPositions = map[soundio.ChannelID]uint32{
+	soundio.ChannelIDFrontLeft:        0x1,
+	soundio.ChannelIDFrontRight:       0x2,
+	soundio.ChannelIDFrontCenter:      0x4,
+	soundio.ChannelIDLfe:              0x8,
+	soundio.ChannelIDBackLeft:         0x10,
+	soundio.ChannelIDBackRight:        0x20,
+	soundio.ChannelIDFrontLeftCenter:  0x40,
+	soundio.ChannelIDFrontRightCenter: 0x80,
+	soundio.ChannelIDBackCenter:       0x100,
+	soundio.ChannelIDSideLeft:         0x200,
+	soundio.ChannelIDSideRight:        0x400,
+	soundio.ChannelIDTopCenter:        0x800,
+	soundio.ChannelIDTopFrontLeft:     0x1000,
+	soundio.ChannelIDTopFrontCenter:   0x2000,
+	soundio.ChannelIDTopFrontRight:    0x4000,
+	soundio.ChannelIDTopBackLeft:      0x8000,
+	soundio.ChannelIDTopBackCenter:    0x10000,
+	soundio.ChannelIDTopBackRight:     0x20000,
+}
+
+// ChannelMask returns the WAVE_FORMAT_EXTENSIBLE channel mask of channels.
+// WAV files store channels in ascending mask bit order, so it returns 0,
+// meaning no speaker assignment, when channels are in another order or
+// have no speaker position.
+func ChannelMask(channels []soundio.ChannelID) uint32 {
+	var mask uint32
+	for _, ch := range channels {
+		bit, ok := speakerPositions[ch]
+		if !ok || bit <= mask {
+			return 0
+		}
+		mask |= bit
+	}
+	return mask
+}
+
+// sampleFormat describes how samples are stored in a WAV file.
+type sampleFormat struct {
+	tag   uint16 // formatPCM or formatIEEEFloat
+	bits  int
+	bytes int
+}
+
+// fileFormat returns the WAV storage of samples in format.
+func fileFormat(format soundio.Format) (sampleFormat, bool) {
+	switch format {
+	case soundio.FormatS8, soundio.FormatU8:
+		return sampleFormat{tag: formatPCM, bits: 8, bytes: 1}, true
+	case soundio.FormatS16LE, soundio.FormatS16BE, soundio.FormatU16LE, soundio.FormatU16BE:
+		return sampleFormat{tag: formatPCM, bits: 16, bytes: 2}, true
+	case soundio.FormatS24LE, soundio.FormatS24BE, soundio.FormatU24LE, soundio.FormatU24BE:
+		return sampleFormat{tag: formatPCM, bits: 24, bytes: 3}, true
+	case soundio.FormatS32LE, soundio.FormatS32BE, soundio.FormatU32LE, soundio.FormatU32BE:
+		return sampleFormat{tag: formatPCM, bits: 32, bytes: 4}, true
+	case soundio.FormatFloat32LE, soundio.FormatFloat32BE:
+		return sampleFormat{tag: formatIEEEFloat, bits: 32, bytes: 4}, true
+	case soundio.FormatFloat64LE, soundio.FormatFloat64BE:
+		return sampleFormat{tag: formatIEEEFloat, bits: 64, bytes: 8}, true
+	default:
+		return sampleFormat{}, false
+	}
+}